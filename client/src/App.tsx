@@ -5,9 +5,13 @@ import Threads from './components/Threads/Threads';
 import { Chat } from './components/Chat';
 import { useLiveAPI } from './hooks/useLiveAPI';
 import { Widget } from './components/Widget';
+import { PushToTalkButton } from './components/PushToTalkButton';
+import { TextModeButton } from './components/TextModeButton';
+import { ScreenShareButton } from './components/ScreenShareButton';
+import { UploadButton } from './components/UploadButton';
 
 export default function App() {
-    const { messages, isLive, status, connect, disconnect, sendMessage } = useLiveAPI();
+    const { messages, isLive, status, connect, disconnect, sendMessage, pushToTalk, isTalking, startTalking, stopTalking, videoEnabled, videoSource, startVideo, stopVideo, uploadsEnabled, isUploading, uploadFile } = useLiveAPI();
     const [stageMode, setStageMode] = useState<'intro' | 'active'>('intro');
 
     // Reactively update stage based on connection status
@@ -23,7 +27,7 @@ export default function App() {
         if (isLive) {
             disconnect();
         } else {
-            connect();
+            connect('audio');
         }
     };
 
@@ -73,6 +77,32 @@ export default function App() {
                 )}
             </AnimatePresence>
 
+            {/* Push-to-talk (ambientes ruidosos) */}
+            {isLive && pushToTalk && (
+                <PushToTalkButton isTalking={isTalking} onStart={startTalking} onStop={stopTalking} />
+            )}
+
+            {/* Compartilhamento de tela/câmera (liberado por cliente) */}
+            <AnimatePresence>
+                {isLive && videoEnabled && (
+                    <ScreenShareButton source={videoSource} onStart={startVideo} onStop={stopVideo} />
+                )}
+            </AnimatePresence>
+
+            {/* Envio de imagem/PDF para o agente (liberado por cliente) */}
+            <AnimatePresence>
+                {isLive && uploadsEnabled && (
+                    <UploadButton isUploading={isUploading} onUpload={(file) => uploadFile(file)} />
+                )}
+            </AnimatePresence>
+
+            {/* Chat só por texto (sem microfone) */}
+            <AnimatePresence>
+                {!isLive && status !== 'connecting' && (
+                    <TextModeButton onClick={() => connect('text')} />
+                )}
+            </AnimatePresence>
+
             {/* Persistent Widget */}
             <Widget
                 onClick={handleWidgetClick}
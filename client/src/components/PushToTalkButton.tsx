@@ -0,0 +1,24 @@
+import React from 'react';
+import { motion } from 'framer-motion';
+import { Mic } from 'lucide-react';
+
+interface PushToTalkButtonProps {
+    isTalking: boolean;
+    onStart: () => void;
+    onStop: () => void;
+}
+
+// Botão "segure para falar" exibido quando o cliente usa push-to-talk em vez da detecção automática de voz.
+export const PushToTalkButton: React.FC<PushToTalkButtonProps> = ({ isTalking, onStart, onStop }) => (
+    <motion.button
+        onPointerDown={(e) => { e.currentTarget.setPointerCapture(e.pointerId); onStart(); }}
+        onPointerUp={onStop}
+        onPointerCancel={onStop}
+        onContextMenu={(e) => e.preventDefault()}
+        className={`fixed bottom-8 left-1/2 -translate-x-1/2 z-50 flex items-center gap-3 rounded-full h-16 px-8 border select-none touch-none transition-colors ${isTalking ? 'bg-green-500/20 border-green-400/60 text-green-300' : 'bg-black border-white/10 text-white/70 hover:text-white'}`}
+        animate={{ scale: isTalking ? 1.05 : 1 }}
+    >
+        <Mic size={18} strokeWidth={2.5} />
+        <span className="text-[14px] font-medium tracking-wide">{isTalking ? 'Solte para enviar' : 'Segure para falar'}</span>
+    </motion.button>
+);
@@ -0,0 +1,32 @@
+import React from 'react';
+import { motion } from 'framer-motion';
+import { Camera, MonitorUp, MonitorX } from 'lucide-react';
+import type { FrameSource } from '../lib/FrameCapture';
+
+interface ScreenShareButtonProps {
+    source: FrameSource | null;
+    onStart: (source: FrameSource) => void;
+    onStop: () => void;
+}
+
+// Compartilhamento de tela (ou câmera, no celular) para o agente ver o que o usuário vê.
+export const ScreenShareButton: React.FC<ScreenShareButtonProps> = ({ source, onStart, onStop }) => {
+    // Navegadores móveis não oferecem getDisplayMedia: usa a câmera traseira
+    const canShareScreen = typeof navigator.mediaDevices?.getDisplayMedia === 'function';
+    const Icon = source ? MonitorX : canShareScreen ? MonitorUp : Camera;
+    const label = source ? 'Parar de compartilhar' : canShareScreen ? 'Compartilhar tela' : 'Mostrar pela câmera';
+
+    return (
+        <motion.button
+            onClick={() => (source ? onStop() : onStart(canShareScreen ? 'screen' : 'camera'))}
+            className={`fixed bottom-28 right-8 z-50 flex items-center gap-2 rounded-full h-10 px-5 border text-[13px] font-medium tracking-wide transition-colors ${source ? 'bg-red-500/15 border-red-400/50 text-red-300' : 'bg-black border-white/10 text-white/60 hover:text-white'}`}
+            initial={{ opacity: 0, y: 10 }}
+            animate={{ opacity: 1, y: 0 }}
+            exit={{ opacity: 0, y: 10 }}
+            whileTap={{ scale: 0.97 }}
+        >
+            <Icon size={15} strokeWidth={2.5} />
+            {label}
+        </motion.button>
+    );
+};
@@ -0,0 +1,22 @@
+import React from 'react';
+import { motion } from 'framer-motion';
+import { MessageSquare } from 'lucide-react';
+
+interface TextModeButtonProps {
+    onClick: () => void;
+}
+
+// Alternativa ao atendimento por voz: abre uma sessão só por texto com o mesmo agente.
+export const TextModeButton: React.FC<TextModeButtonProps> = ({ onClick }) => (
+    <motion.button
+        onClick={onClick}
+        className="fixed bottom-28 right-8 z-50 flex items-center gap-2 rounded-full h-10 px-5 bg-black border border-white/10 text-white/60 hover:text-white text-[13px] font-medium tracking-wide transition-colors"
+        initial={{ opacity: 0, y: 10 }}
+        animate={{ opacity: 1, y: 0 }}
+        exit={{ opacity: 0, y: 10 }}
+        whileTap={{ scale: 0.97 }}
+    >
+        <MessageSquare size={15} strokeWidth={2.5} />
+        Prefiro digitar
+    </motion.button>
+);
@@ -0,0 +1,47 @@
+import React, { useRef, useState } from 'react';
+import { motion } from 'framer-motion';
+import { Loader2, Paperclip } from 'lucide-react';
+import { UPLOAD_ACCEPT } from '../hooks/useLiveAPI';
+
+interface UploadButtonProps {
+    isUploading: boolean;
+    onUpload: (file: File) => Promise<string | null>;
+}
+
+// Envio de imagem ou PDF (boleto, comprovante, print de erro) para o agente durante a sessão.
+export const UploadButton: React.FC<UploadButtonProps> = ({ isUploading, onUpload }) => {
+    const inputRef = useRef<HTMLInputElement>(null);
+    const [error, setError] = useState<string | null>(null);
+
+    const handleChange = async (e: React.ChangeEvent<HTMLInputElement>) => {
+        const file = e.target.files?.[0];
+        e.target.value = ''; // Permite reenviar o mesmo arquivo
+        if (!file) return;
+        const err = await onUpload(file);
+        setError(err);
+        if (err) setTimeout(() => setError(null), 4000);
+    };
+
+    return (
+        <>
+            <input ref={inputRef} type="file" accept={UPLOAD_ACCEPT} className="hidden" onChange={handleChange} />
+            <motion.button
+                onClick={() => inputRef.current?.click()}
+                disabled={isUploading}
+                className="fixed bottom-40 right-8 z-50 flex items-center gap-2 rounded-full h-10 px-5 bg-black border border-white/10 text-white/60 hover:text-white disabled:opacity-60 text-[13px] font-medium tracking-wide transition-colors"
+                initial={{ opacity: 0, y: 10 }}
+                animate={{ opacity: 1, y: 0 }}
+                exit={{ opacity: 0, y: 10 }}
+                whileTap={{ scale: 0.97 }}
+            >
+                {isUploading ? <Loader2 size={15} strokeWidth={2.5} className="animate-spin" /> : <Paperclip size={15} strokeWidth={2.5} />}
+                {isUploading ? 'Enviando...' : 'Enviar arquivo'}
+            </motion.button>
+            {error && (
+                <div className="fixed bottom-52 right-8 z-50 max-w-[260px] rounded-xl px-4 py-2 bg-red-500/15 border border-red-400/40 text-red-300 text-[12px]">
+                    {error}
+                </div>
+            )}
+        </>
+    );
+};
@@ -1,6 +1,7 @@
 import { useState, useRef, useEffect, useCallback } from 'react';
 import { useTranscriptionManager } from './useTranscriptionManager';
 import { AudioStreamer } from '../lib/AudioStreamer';
+import { FrameCapture, FrameSource, VideoLimits } from '../lib/FrameCapture';
 
 // API Configuration
 const AGENT_API_URL = import.meta.env.VITE_AGENT_API_URL || 'http://localhost:8080';
@@ -36,12 +37,28 @@ registerProcessor('audio-processor', AudioProcessor);
 `;
 
 export type LiveStatus = 'idle' | 'connecting' | 'connected' | 'error';
+// Modalidade da sessão: voz (padrão) ou chat só por texto, com o mesmo agente
+export type LiveModality = 'audio' | 'text';
+
+// Arquivos aceitos pelo /upload do orquestrador (o servidor confere o tipo pelo conteúdo)
+export const UPLOAD_ACCEPT = 'image/jpeg,image/png,image/webp,application/pdf';
+const MAX_UPLOAD_BYTES = 5 * 1024 * 1024;
 
 export function useLiveAPI() {
     const { messages, addMessage, processServerContent, reset } = useTranscriptionManager();
     const [status, setStatus] = useState<LiveStatus>('idle');
     const [isLive, setIsLive] = useState(false);
     const [isThinking, setIsThinking] = useState(false);
+    // Push-to-talk (definido pelo orquestrador por cliente): o áudio só é enviado com o botão pressionado
+    const [pushToTalk, setPushToTalk] = useState(false);
+    const [isTalking, setIsTalking] = useState(false);
+    const [modality, setModality] = useState<LiveModality>('audio');
+    // Compartilhamento de tela/câmera (liberado por cliente; limites de taxa e tamanho vêm do orquestrador)
+    const [videoEnabled, setVideoEnabled] = useState(false);
+    const [videoSource, setVideoSource] = useState<FrameSource | null>(null);
+    // Envio de imagens/PDF durante a sessão (liberado por cliente)
+    const [uploadsEnabled, setUploadsEnabled] = useState(false);
+    const [isUploading, setIsUploading] = useState(false);
 
     // Refs para gerenciamento de hardware e sessão
     const liveSessionRef = useRef<any>(null);
@@ -53,6 +70,11 @@ export function useLiveAPI() {
     const isThinkingRef = useRef(false);
     const callIdRef = useRef<string>('');
     const sessionStartTimeRef = useRef<number>(0);
+    const pushToTalkRef = useRef(false);
+    const isTalkingRef = useRef(false);
+    const modalityRef = useRef<LiveModality>('audio');
+    const videoLimitsRef = useRef<VideoLimits>({ enabled: false, maxFps: 1, maxFrameBytes: 256 * 1024 });
+    const frameCaptureRef = useRef<FrameCapture | null>(null);
 
 
 
@@ -88,6 +110,12 @@ export function useLiveAPI() {
         }
     }, []);
 
+    const stopVideo = useCallback(() => {
+        frameCaptureRef.current?.stop();
+        frameCaptureRef.current = null;
+        setVideoSource(null);
+    }, []);
+
     const disconnect = useCallback(async (isGraceful = true) => {
         console.log('[useLiveAPI] Disconnecting...');
 
@@ -98,6 +126,8 @@ export function useLiveAPI() {
         setStatus('idle');
         setIsThinking(false);
         isThinkingRef.current = false;
+        setIsTalking(false);
+        isTalkingRef.current = false;
         reconnectAttemptsRef.current = 0; // Reset definitivo
 
         if (liveSessionRef.current) {
@@ -118,7 +148,8 @@ export function useLiveAPI() {
         }
 
         stopAudioCapture();
-    }, [stopAudioCapture]);
+        stopVideo();
+    }, [stopAudioCapture, stopVideo]);
 
     // Handle incoming audio
     const playAudioChunk = useCallback((base64: string) => {
@@ -171,7 +202,8 @@ export function useLiveAPI() {
 
                 const buffer = event.data.buffer;
                 const session = liveSessionRef.current;
-                if (session && isLiveRef.current && buffer) {
+                const muted = pushToTalkRef.current && !isTalkingRef.current;
+                if (session && isLiveRef.current && buffer && !muted) {
                     const base64 = btoa(String.fromCharCode(...new Uint8Array(buffer)));
                     session.sendRealtimeInput({
                         audio: { data: base64, mimeType: "audio/pcm;rate=16000" }
@@ -196,8 +228,13 @@ export function useLiveAPI() {
     const reconnectAttemptsRef = useRef(0);
     const MAX_RECONNECT_ATTEMPTS = 3;
 
-    const connect = useCallback(async () => {
+    // mode só é informado na conexão manual; as reconexões automáticas mantêm a modalidade atual
+    const connect = useCallback(async (mode?: LiveModality) => {
         try {
+            if (mode) {
+                modalityRef.current = mode;
+                setModality(mode);
+            }
             setStatus('connecting');
 
             // Se for tentativa 0 (conexão manual), gera NOVO ID. 
@@ -212,8 +249,9 @@ export function useLiveAPI() {
 
             isLiveRef.current = true;
 
-            const clientName = import.meta.env.VITE_INSTANCE_CLIENT_NAME || 'aiVoice';
-            const wsUrl = AGENT_API_URL.replace('http', 'ws') + '/ws?callId=' + newCallId + '&client=' + clientName;
+            // A chave do widget identifica o cliente no orquestrador (sem chave = cliente padrão da instância)
+            const clientKey = import.meta.env.VITE_CLIENT_KEY || '';
+            const wsUrl = AGENT_API_URL.replace('http', 'ws') + '/ws?callId=' + newCallId + (clientKey ? '&key=' + encodeURIComponent(clientKey) : '');
             const socket = new WebSocket(wsUrl);
             liveSessionRef.current = {
                 sendRealtimeInput: (data: any) => {
@@ -226,6 +264,11 @@ export function useLiveAPI() {
                         socket.send(JSON.stringify({ type: 'client_content', payload: data }));
                     }
                 },
+                sendActivity: (type: 'activity_start' | 'activity_end') => {
+                    if (socket.readyState === WebSocket.OPEN) {
+                        socket.send(JSON.stringify({ type }));
+                    }
+                },
                 sendToolResponse: (data: any) => {
                     if (socket.readyState === WebSocket.OPEN) {
                         socket.send(JSON.stringify({ type: 'tool_response', payload: data }));
@@ -243,11 +286,22 @@ export function useLiveAPI() {
                 setStatus('connected');
                 setIsLive(true);
                 reconnectAttemptsRef.current = 0; // Reset ao conectar com sucesso
-                startAudioCapture();
+                if (modalityRef.current === 'audio') startAudioCapture();
 
                 socket.send(JSON.stringify({
                     type: 'setup',
-                    payload: {}
+                    payload: {
+                        agent: import.meta.env.VITE_AGENT_NAME || '',
+                        modality: modalityRef.current,
+                        // Variáveis do template do prompt ({{.Page.URL}}, {{.Page.Title}})
+                        page: { url: window.location.href, title: document.title },
+                        // Contexto do visitante; o servidor descarta o que não estiver na allowlist do cliente
+                        context: {
+                            pageUrl: window.location.href,
+                            referrer: document.referrer,
+                            locale: navigator.language
+                        }
+                    }
                 }));
             };
 
@@ -269,6 +323,29 @@ export function useLiveAPI() {
                     return;
                 }
 
+                // Opções da sessão definidas pelo cliente no dashboard
+                if (data.type === 'session_config') {
+                    pushToTalkRef.current = !!data.payload?.pushToTalk;
+                    setPushToTalk(pushToTalkRef.current);
+                    if (data.payload?.modality) {
+                        modalityRef.current = data.payload.modality;
+                        setModality(data.payload.modality);
+                    }
+                    if (data.payload?.video) {
+                        videoLimitsRef.current = data.payload.video;
+                        setVideoEnabled(!!data.payload.video.enabled);
+                    }
+                    setUploadsEnabled(!!data.payload?.uploads);
+                    return;
+                }
+
+                // Conexão recusada pelo orquestrador (chave inválida, cliente suspenso...)
+                if (data.type === 'error') {
+                    console.warn('[useLiveAPI] Connection refused:', data.payload?.code, data.payload?.message);
+                    setStatus('error');
+                    return;
+                }
+
                 const serverContent = data.server_content || data.serverContent || data;
 
                 // Prioridade Total: Áudio
@@ -339,6 +416,80 @@ export function useLiveAPI() {
         addMessage('user', text);
     }, [isLive, addMessage]);
 
+    // Início/fim da fala no modo push-to-talk (viram activityStart/activityEnd no Gemini)
+    const startTalking = useCallback(() => {
+        if (!pushToTalkRef.current || isTalkingRef.current || !liveSessionRef.current) return;
+        isTalkingRef.current = true;
+        setIsTalking(true);
+        audioStreamerRef.current?.stop(); // Interrompe a fala do agente
+        audioStreamerRef.current = null;
+        liveSessionRef.current.sendActivity('activity_start');
+    }, []);
+
+    const stopTalking = useCallback(() => {
+        if (!isTalkingRef.current || !liveSessionRef.current) return;
+        isTalkingRef.current = false;
+        setIsTalking(false);
+        liveSessionRef.current.sendActivity('activity_end');
+    }, []);
+
+    // Envia quadros JPEG da tela ou da câmera como realtime_input.video
+    const startVideo = useCallback(async (source: FrameSource) => {
+        if (!videoLimitsRef.current.enabled || !liveSessionRef.current) return;
+        stopVideo();
+        try {
+            const stream = source === 'screen'
+                ? await navigator.mediaDevices.getDisplayMedia({ video: true, audio: false })
+                : await navigator.mediaDevices.getUserMedia({ video: { facingMode: 'environment' } });
+            const capture = new FrameCapture(stream, videoLimitsRef.current, (data) => {
+                if (isLiveRef.current) {
+                    liveSessionRef.current?.sendRealtimeInput({ video: { data, mimeType: 'image/jpeg', source } });
+                }
+            });
+            // O usuário pode encerrar pelo próprio navegador ("Parar compartilhamento")
+            stream.getVideoTracks()[0]?.addEventListener('ended', () => {
+                if (frameCaptureRef.current === capture) stopVideo();
+            });
+            frameCaptureRef.current = capture;
+            setVideoSource(source);
+            await capture.start();
+        } catch (err) {
+            console.error('[useLiveAPI] Video error:', err);
+            stopVideo();
+        }
+    }, [stopVideo]);
+
+    // Envia um arquivo (boleto, comprovante, print) para o agente na sessão atual.
+    // Retorna a mensagem de erro para o usuário, ou null quando o arquivo foi entregue.
+    const uploadFile = useCallback(async (file: File, caption = ''): Promise<string | null> => {
+        if (!isLiveRef.current || !callIdRef.current) return 'A sessão não está ativa.';
+        if (file.size > MAX_UPLOAD_BYTES) return 'Arquivo grande demais (máximo 5 MB).';
+        setIsUploading(true);
+        try {
+            const clientKey = import.meta.env.VITE_CLIENT_KEY || '';
+            const url = `${AGENT_API_URL}/upload?sessionId=${callIdRef.current}` + (clientKey ? '&key=' + encodeURIComponent(clientKey) : '');
+            const form = new FormData();
+            form.append('file', file);
+            if (caption.trim()) form.append('caption', caption.trim());
+            const res = await fetch(url, { method: 'POST', body: form });
+            if (!res.ok) {
+                console.warn('[useLiveAPI] Upload refused:', res.status, await res.text());
+                if (res.status === 415) return 'Envie uma imagem (JPG, PNG, WEBP) ou um PDF.';
+                if (res.status === 413) return 'Arquivo grande demais (máximo 5 MB).';
+                return 'Não foi possível enviar o arquivo.';
+            }
+            isThinkingRef.current = true;
+            setIsThinking(true);
+            addMessage('user', `📎 ${file.name}` + (caption.trim() ? ` — ${caption.trim()}` : ''));
+            return null;
+        } catch (err) {
+            console.error('[useLiveAPI] Upload error:', err);
+            return 'Não foi possível enviar o arquivo.';
+        } finally {
+            setIsUploading(false);
+        }
+    }, [addMessage]);
+
     useEffect(() => {
         const handleBeforeUnload = () => {
             if (isLiveRef.current && callIdRef.current) {
@@ -362,6 +513,18 @@ export function useLiveAPI() {
         connect,
         disconnect,
         sendMessage,
-        isThinking
+        isThinking,
+        pushToTalk,
+        isTalking,
+        startTalking,
+        stopTalking,
+        modality,
+        videoEnabled,
+        videoSource,
+        startVideo,
+        stopVideo,
+        uploadsEnabled,
+        isUploading,
+        uploadFile
     };
 }
@@ -0,0 +1,86 @@
+/**
+ * Captura quadros JPEG de um MediaStream de vídeo (tela ou câmera) em uma taxa fixa.
+ * O orquestrador descarta quadros acima do limite do cliente, então a resolução e a
+ * qualidade são reduzidas até o quadro caber em maxFrameBytes.
+ */
+export type FrameSource = 'screen' | 'camera';
+
+export interface VideoLimits {
+    enabled: boolean;
+    maxFps: number;
+    maxFrameBytes: number;
+}
+
+const MAX_DIMENSION = 1280;
+const MIN_DIMENSION = 320;
+
+export class FrameCapture {
+    private video: HTMLVideoElement;
+    private canvas: HTMLCanvasElement;
+    private timer: number | null = null;
+    private busy = false;
+
+    constructor(
+        public stream: MediaStream,
+        private limits: VideoLimits,
+        private onFrame: (base64: string) => void
+    ) {
+        this.video = document.createElement('video');
+        this.video.muted = true;
+        this.video.playsInline = true;
+        this.video.srcObject = stream;
+        this.canvas = document.createElement('canvas');
+    }
+
+    async start() {
+        await this.video.play();
+        const intervalMs = 1000 / Math.max(0.1, this.limits.maxFps);
+        this.timer = window.setInterval(() => this.capture(), intervalMs);
+    }
+
+    stop() {
+        if (this.timer !== null) {
+            clearInterval(this.timer);
+            this.timer = null;
+        }
+        this.stream.getTracks().forEach((track) => track.stop());
+        this.video.srcObject = null;
+    }
+
+    private async capture() {
+        if (this.busy || !this.video.videoWidth) return;
+        this.busy = true;
+        try {
+            let scale = Math.min(1, MAX_DIMENSION / Math.max(this.video.videoWidth, this.video.videoHeight));
+            let quality = 0.7;
+            for (let attempt = 0; attempt < 4; attempt++) {
+                const blob = await this.encode(scale, quality);
+                if (blob && blob.size <= this.limits.maxFrameBytes) {
+                    this.onFrame(await toBase64(blob));
+                    return;
+                }
+                // Não coube: reduz qualidade e resolução e tenta de novo
+                quality = Math.max(0.4, quality - 0.15);
+                scale = Math.max(MIN_DIMENSION / Math.max(this.video.videoWidth, this.video.videoHeight), scale * 0.75);
+            }
+        } finally {
+            this.busy = false;
+        }
+    }
+
+    private encode(scale: number, quality: number): Promise<Blob | null> {
+        this.canvas.width = Math.round(this.video.videoWidth * scale);
+        this.canvas.height = Math.round(this.video.videoHeight * scale);
+        this.canvas.getContext('2d')?.drawImage(this.video, 0, 0, this.canvas.width, this.canvas.height);
+        return new Promise((resolve) => this.canvas.toBlob(resolve, 'image/jpeg', quality));
+    }
+}
+
+function toBase64(blob: Blob): Promise<string> {
+    return new Promise((resolve, reject) => {
+        const reader = new FileReader();
+        reader.onload = () => resolve(String(reader.result).split(',')[1] || '');
+        reader.onerror = () => reject(reader.error);
+        reader.readAsDataURL(blob);
+    });
+}
@@ -3,6 +3,8 @@
 interface ImportMetaEnv {
     readonly VITE_AGENT_API_URL: string
     readonly VITE_DASHBOARD_API_URL: string
+    readonly VITE_AGENT_NAME?: string
+    readonly VITE_CLIENT_KEY?: string
 }
 
 interface ImportMeta {
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+)
+
+// -- Structs --
+
+type AgentSummary struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	IsDefault   bool   `json:"isDefault"`
+}
+
+var agentNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// -- Handlers --
+
+// handleAgents lista, cria e remove agentes do cliente.
+// Um novo agente nasce como cópia do agente padrão e é ajustado via /api/dashboard/config?agent=.
+func handleAgents(w http.ResponseWriter, r *http.Request) {
+	clientName := currentTenant(r).Name
+	ctx := context.Background()
+
+	switch r.Method {
+	case "GET":
+		rows, err := db.Query(ctx, `
+			SELECT c.agent_name, COALESCE(c.agent_description, ''), COALESCE(c.is_default, false)
+			FROM aiVoice_config c
+			JOIN aiVoice_clients cl ON c.client_id = cl.id
+			WHERE cl.name = $1
+			ORDER BY c.is_default DESC, c.agent_name ASC`, clientName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		agents := []AgentSummary{}
+		for rows.Next() {
+			var a AgentSummary
+			if err := rows.Scan(&a.Name, &a.Description, &a.IsDefault); err != nil {
+				continue
+			}
+			agents = append(agents, a)
+		}
+		json.NewEncoder(w).Encode(agents)
+
+	case "POST":
+		var req AgentSummary
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !agentNamePattern.MatchString(req.Name) {
+			http.Error(w, "Invalid agent name (use lowercase letters, digits, '-' or '_')", http.StatusBadRequest)
+			return
+		}
+
+		res, err := db.Exec(ctx, `
+			INSERT INTO aiVoice_config (
+				client_id, agent_name, agent_description, is_default,
+				voice_name, language_code, temperature, thinking_budget, enable_affective_dialog, proactive_audio,
+				system_prompt, docstring_tool_knowledge, docstring_tool_terminate, docstring_tool_send_link,
+				proactive_alert_instruction, duration_limit, termination_alert_time,
+				docstring_tool_escalate, escalation_webhook_url, lead_schema, docstring_tool_lead, scheduling_enabled,
+				timezone, business_hours,
+				top_p, top_k, max_output_tokens, vad_start_sensitivity, vad_end_sensitivity, vad_prefix_padding_ms, vad_silence_duration_ms,
+				context_compression, compression_trigger_tokens, compression_target_tokens
+			)
+			SELECT
+				c.client_id, $2, $3, false,
+				c.voice_name, c.language_code, c.temperature, c.thinking_budget, c.enable_affective_dialog, c.proactive_audio,
+				c.system_prompt, c.docstring_tool_knowledge, c.docstring_tool_terminate, c.docstring_tool_send_link,
+				c.proactive_alert_instruction, c.duration_limit, c.termination_alert_time,
+				c.docstring_tool_escalate, c.escalation_webhook_url, c.lead_schema, c.docstring_tool_lead, c.scheduling_enabled,
+				c.timezone, c.business_hours,
+				c.top_p, c.top_k, c.max_output_tokens, c.vad_start_sensitivity, c.vad_end_sensitivity, c.vad_prefix_padding_ms, c.vad_silence_duration_ms,
+				c.context_compression, c.compression_trigger_tokens, c.compression_target_tokens
+			FROM aiVoice_config c
+			JOIN aiVoice_clients cl ON c.client_id = cl.id
+			WHERE cl.name = $1 AND c.is_default
+			ON CONFLICT (client_id, agent_name) DO NOTHING`, clientName, req.Name, req.Description)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if res.RowsAffected() == 0 {
+			http.Error(w, "Agent already exists", http.StatusConflict)
+			return
+		}
+		notifyConfigChange(clientName)
+		log.Printf("🤖 Agente '%s' criado para o cliente %s", req.Name, clientName)
+		w.WriteHeader(http.StatusCreated)
+
+	case "DELETE":
+		name := r.URL.Query().Get("name")
+		res, err := db.Exec(ctx, `
+			DELETE FROM aiVoice_config
+			WHERE client_id = (SELECT id FROM aiVoice_clients WHERE name = $1)
+			  AND agent_name = $2 AND NOT is_default`, clientName, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if res.RowsAffected() == 0 {
+			http.Error(w, "Agent not found or is the default agent", http.StatusNotFound)
+			return
+		}
+		notifyConfigChange(clientName)
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
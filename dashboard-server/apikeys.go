@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Chaves da API Gemini: o orquestrador usa o pool da plataforma ou a chave própria do cliente (BYOK)
+// e informa no sync o consumo de cada chave usada na chamada, para cobrança de quem usa as nossas.
+
+var geminiKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{20,100}$`)
+
+// -- Structs --
+
+// KeyUsage espelha o KeyUsage do orquestrador (consumo da chamada com uma chave).
+type KeyUsage struct {
+	KeyID        string `json:"keyId"`
+	Owner        string `json:"owner"` // "platform" ou "client"
+	InputTokens  int    `json:"inputTokens"`
+	OutputTokens int    `json:"outputTokens"`
+}
+
+type GeminiKeyStatus struct {
+	Configured bool   `json:"configured"`
+	KeyID      string `json:"keyId,omitempty"`  // ID usado pelo orquestrador nos logs e no consumo
+	Masked     string `json:"masked,omitempty"` // só o final da chave; a chave nunca é devolvida
+}
+
+type GeminiKeyRequest struct {
+	APIKey string `json:"apiKey"`
+}
+
+type KeyUsageReport struct {
+	ClientName   string `json:"clientName"`
+	KeyID        string `json:"keyId"`
+	Owner        string `json:"owner"`
+	Calls        int    `json:"calls"`
+	InputTokens  int64  `json:"inputTokens"`
+	OutputTokens int64  `json:"outputTokens"`
+}
+
+// clientKeyID reproduz keypool.ClientKey do orquestrador.
+func clientKeyID(clientName, secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return "client:" + clientName + ":" + hex.EncodeToString(sum[:4])
+}
+
+// saveKeyUsage grava o consumo por chave da chamada (substitui o do sync anterior).
+func saveKeyUsage(ctx context.Context, callID string, usage []KeyUsage) error {
+	for _, u := range usage {
+		if u.KeyID == "" {
+			continue
+		}
+		_, err := db.Exec(ctx, `
+			INSERT INTO aiVoice_key_usage (call_id, client_id, key_id, owner, input_tokens, output_tokens)
+			SELECT $1, c.client_id, $2, $3, $4, $5 FROM aiVoice_calls c WHERE c.call_id = $1
+			ON CONFLICT (call_id, key_id) DO UPDATE SET
+				input_tokens = EXCLUDED.input_tokens,
+				output_tokens = EXCLUDED.output_tokens,
+				updated_at = NOW()`,
+			callID, u.KeyID, u.Owner, u.InputTokens, u.OutputTokens)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// -- Handlers --
+
+// handleGeminiKey consulta (GET), grava (PUT) e remove (DELETE) a chave Gemini própria do cliente.
+// A chave é gravada cifrada (secrets.go) e nunca é devolvida pela API, só mascarada.
+func handleGeminiKey(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	tenant := currentTenant(r)
+
+	switch r.Method {
+	case "GET":
+		var enc string
+		if err := db.QueryRow(ctx, "SELECT COALESCE(gemini_api_key, '') FROM aiVoice_clients WHERE id = $1", tenant.ID).Scan(&enc); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		status := GeminiKeyStatus{Configured: enc != ""}
+		if secret, err := decryptSecret(enc); err == nil {
+			status.KeyID = clientKeyID(tenant.Name, secret)
+			status.Masked = maskSecret(secret)
+		}
+		json.NewEncoder(w).Encode(status)
+
+	case "PUT":
+		var req GeminiKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		key := strings.TrimSpace(req.APIKey)
+		if !geminiKeyPattern.MatchString(key) {
+			http.Error(w, "invalid Gemini API key", http.StatusBadRequest)
+			return
+		}
+		enc, err := encryptSecret(key)
+		if err != nil {
+			log.Printf("❌ Erro ao cifrar a chave Gemini de %s: %v", tenant.Name, err)
+			http.Error(w, "Secret storage unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if _, err := db.Exec(ctx, "UPDATE aiVoice_clients SET gemini_api_key = $1 WHERE id = $2", enc, tenant.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		notifyConfigChange(tenant.Name)
+		status := GeminiKeyStatus{Configured: true, KeyID: clientKeyID(tenant.Name, key), Masked: maskSecret(key)}
+		log.Printf("🔑 Chave Gemini própria de %s configurada por %s (%s)", tenant.Name, currentUserEmail(r), status.KeyID)
+		json.NewEncoder(w).Encode(status)
+
+	case "DELETE":
+		if _, err := db.Exec(ctx, "UPDATE aiVoice_clients SET gemini_api_key = NULL WHERE id = $1", tenant.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		notifyConfigChange(tenant.Name)
+		log.Printf("🔑 Chave Gemini própria de %s removida por %s; voltando ao pool da plataforma", tenant.Name, currentUserEmail(r))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminKeyUsage soma o consumo por cliente e chave no período (GET ?from=&to=, datas YYYY-MM-DD;
+// padrão: mês corrente). ?owner=platform restringe às chaves da plataforma (cobrança).
+func handleAdminKeyUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	to := from.AddDate(0, 1, 0)
+	if v := q.Get("from"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, now.Location())
+		if err != nil {
+			http.Error(w, "invalid from (use YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, now.Location())
+		if err != nil {
+			http.Error(w, "invalid to (use YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		to = t.AddDate(0, 0, 1) // inclusivo
+	}
+
+	rows, err := db.Query(context.Background(), `
+		SELECT cl.name, u.key_id, u.owner, COUNT(DISTINCT u.call_id), SUM(u.input_tokens), SUM(u.output_tokens)
+		FROM aiVoice_key_usage u
+		JOIN aiVoice_clients cl ON u.client_id = cl.id
+		JOIN aiVoice_calls c ON c.call_id = u.call_id
+		WHERE c.created_at >= $1 AND c.created_at < $2 AND ($3 = '' OR u.owner = $3)
+		GROUP BY cl.name, u.key_id, u.owner
+		ORDER BY cl.name, u.key_id`, from, to, q.Get("owner"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	report := []KeyUsageReport{}
+	for rows.Next() {
+		var u KeyUsageReport
+		if err := rows.Scan(&u.ClientName, &u.KeyID, &u.Owner, &u.Calls, &u.InputTokens, &u.OutputTokens); err != nil {
+			continue
+		}
+		report = append(report, u)
+	}
+	json.NewEncoder(w).Encode(report)
+}
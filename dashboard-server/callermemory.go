@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Memória do cliente recorrente: o orquestrador resume cada atendimento de um chamador identificado
+// ("user:<userId do site>" ou "caller:<caller ID>") e injeta a memória no próximo setup.
+
+// -- Structs --
+
+type CallerMemory struct {
+	Identity   string    `json:"identity"`
+	KeyFacts   []string  `json:"keyFacts"`
+	LastTopic  string    `json:"lastTopic"`
+	OpenIssues []string  `json:"openIssues"`
+	CallCount  int       `json:"callCount"`
+	LastCallID string    `json:"lastCallId"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type CallerMemorySettings struct {
+	Enabled       bool `json:"enabled"`
+	RetentionDays int  `json:"retentionDays"`
+}
+
+const maxMemoryRetentionDays = 3650
+
+// -- Handlers --
+
+// handleCallerMemory consulta (GET ?identity=, ou lista as mais recentes sem identity) e apaga
+// (DELETE ?identity=) a memória de um chamador, para atender pedidos de privacidade.
+func handleCallerMemory(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	tenant := currentTenant(r)
+	identity := r.URL.Query().Get("identity")
+
+	switch r.Method {
+	case "GET":
+		if identity != "" {
+			var m CallerMemory
+			err := db.QueryRow(ctx, `
+				SELECT identity, key_facts, COALESCE(last_topic, ''), open_issues, call_count, COALESCE(last_call_id, ''), updated_at
+				FROM aiVoice_caller_memory WHERE client_id = $1 AND identity = $2`, tenant.ID, identity,
+			).Scan(&m.Identity, &m.KeyFacts, &m.LastTopic, &m.OpenIssues, &m.CallCount, &m.LastCallID, &m.UpdatedAt)
+			if errors.Is(err, pgx.ErrNoRows) {
+				http.Error(w, "Caller memory not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(m)
+			return
+		}
+
+		rows, err := db.Query(ctx, `
+			SELECT identity, key_facts, COALESCE(last_topic, ''), open_issues, call_count, COALESCE(last_call_id, ''), updated_at
+			FROM aiVoice_caller_memory WHERE client_id = $1
+			ORDER BY updated_at DESC
+			LIMIT 100`, tenant.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		memories := []CallerMemory{}
+		for rows.Next() {
+			var m CallerMemory
+			if err := rows.Scan(&m.Identity, &m.KeyFacts, &m.LastTopic, &m.OpenIssues, &m.CallCount, &m.LastCallID, &m.UpdatedAt); err != nil {
+				continue
+			}
+			memories = append(memories, m)
+		}
+		json.NewEncoder(w).Encode(memories)
+
+	case "DELETE":
+		if identity == "" {
+			http.Error(w, "identity is required", http.StatusBadRequest)
+			return
+		}
+		res, err := db.Exec(ctx, "DELETE FROM aiVoice_caller_memory WHERE client_id = $1 AND identity = $2", tenant.ID, identity)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if res.RowsAffected() == 0 {
+			http.Error(w, "Caller memory not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("🧹 Memória de %s (%s) apagada por %s", identity, tenant.Name, currentUserEmail(r))
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleCallerMemorySettings lê (GET) e altera (PUT) a ativação e a retenção da memória do cliente.
+func handleCallerMemorySettings(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	tenant := currentTenant(r)
+
+	switch r.Method {
+	case "GET":
+		var s CallerMemorySettings
+		err := db.QueryRow(ctx, `
+			SELECT COALESCE(caller_memory_enabled, false), COALESCE(caller_memory_retention_days, 90)
+			FROM aiVoice_clients WHERE id = $1`, tenant.ID).Scan(&s.Enabled, &s.RetentionDays)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(s)
+
+	case "PUT":
+		var s CallerMemorySettings
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if s.RetentionDays < 1 || s.RetentionDays > maxMemoryRetentionDays {
+			http.Error(w, "retentionDays must be between 1 and 3650", http.StatusBadRequest)
+			return
+		}
+		if _, err := db.Exec(ctx, `
+			UPDATE aiVoice_clients SET caller_memory_enabled = $1, caller_memory_retention_days = $2 WHERE id = $3`,
+			s.Enabled, s.RetentionDays, tenant.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Retenção menor vale imediatamente para o que já foi guardado
+		if _, err := db.Exec(ctx, `
+			DELETE FROM aiVoice_caller_memory
+			WHERE client_id = $1 AND updated_at < NOW() - make_interval(days => $2)`, tenant.ID, s.RetentionDays); err != nil {
+			log.Printf("⚠️ Erro ao aplicar retenção da memória de %s: %v", tenant.Name, err)
+		}
+		notifyConfigChange(tenant.Name)
+		log.Printf("🧠 Memória de clientes recorrentes de %s: ativa=%v, retenção=%d dias (%s)", tenant.Name, s.Enabled, s.RetentionDays, currentUserEmail(r))
+		json.NewEncoder(w).Encode(s)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
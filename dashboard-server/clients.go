@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// -- Structs --
+
+type ClientInfo struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status"` // active, suspended
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type ProvisionRequest struct {
+	Name       string `json:"name"`
+	Template   string `json:"template"`
+	OwnerEmail string `json:"ownerEmail"`
+}
+
+type ConfigTemplate struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+var (
+	errClientExists     = errors.New("client already exists")
+	errTemplateNotFound = errors.New("template not found")
+	clientNamePattern   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _.-]{0,99}$`)
+)
+
+// -- Provisionamento --
+
+// provisionClient cria o cliente e o agente padrão a partir de um template de configuração
+// (aiVoice_config_templates). {{.ClientName}} nos textos do template é substituído pelo nome do cliente.
+func provisionClient(ctx context.Context, name, template string) (Tenant, error) {
+	if template == "" {
+		template = "default"
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return Tenant{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	t := Tenant{Name: name}
+	err = tx.QueryRow(ctx, "INSERT INTO aiVoice_clients (name, status) VALUES ($1, 'active') RETURNING id", name).Scan(&t.ID)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return Tenant{}, errClientExists
+	}
+	if err != nil {
+		return Tenant{}, err
+	}
+
+	res, err := tx.Exec(ctx, `
+		INSERT INTO aiVoice_config (
+			client_id, agent_name, is_default, voice_name, language_code, temperature,
+			enable_affective_dialog, proactive_audio, system_prompt,
+			docstring_tool_knowledge, docstring_tool_terminate, docstring_tool_send_link
+		)
+		SELECT $1, 'default', true, t.voice_name, t.language_code, t.temperature,
+			t.enable_affective_dialog, t.proactive_audio, replace(t.system_prompt, '{{.ClientName}}', $2),
+			replace(t.docstring_tool_knowledge, '{{.ClientName}}', $2), t.docstring_tool_terminate, t.docstring_tool_send_link
+		FROM aiVoice_config_templates t
+		WHERE t.name = $3`, t.ID, name, template)
+	if err != nil {
+		return Tenant{}, err
+	}
+	if res.RowsAffected() == 0 {
+		return Tenant{}, errTemplateNotFound
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Tenant{}, err
+	}
+	log.Printf("🆕 Cliente %s provisionado (template: %s)", name, template)
+	return t, nil
+}
+
+// -- Middleware --
+
+// adminAuth protege a API de provisionamento com o token de operador (PROVISIONING_TOKEN).
+// Sem o token configurado a API fica desabilitada.
+func adminAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expected := os.Getenv("PROVISIONING_TOKEN")
+		if expected == "" {
+			http.Error(w, "Provisioning API disabled", http.StatusServiceUnavailable)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// -- Handlers --
+
+// handleAdminClients lista (GET), cria (POST) e remove (DELETE ?name=) clientes.
+func handleAdminClients(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	switch r.Method {
+	case "GET":
+		rows, err := db.Query(ctx, "SELECT id, name, COALESCE(status, 'active'), created_at FROM aiVoice_clients ORDER BY name")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+		clients := []ClientInfo{}
+		for rows.Next() {
+			var c ClientInfo
+			if err := rows.Scan(&c.ID, &c.Name, &c.Status, &c.CreatedAt); err != nil {
+				continue
+			}
+			clients = append(clients, c)
+		}
+		json.NewEncoder(w).Encode(clients)
+	case "POST":
+		var req ProvisionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !clientNamePattern.MatchString(req.Name) {
+			http.Error(w, "Invalid client name", http.StatusBadRequest)
+			return
+		}
+
+		t, err := provisionClient(ctx, req.Name, req.Template)
+		if errors.Is(err, errClientExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, errTemplateNotFound) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Opcional: vincula um usuário existente do dashboard como responsável pelo cliente
+		if req.OwnerEmail != "" {
+			res, err := db.Exec(ctx, `
+				INSERT INTO dashboard_user_clients (user_id, client_id)
+				SELECT id, $2 FROM dashboard_users WHERE email = $1
+				ON CONFLICT DO NOTHING`, req.OwnerEmail, t.ID)
+			if err != nil || res.RowsAffected() == 0 {
+				log.Printf("⚠️ Provisionamento: usuário %s não vinculado ao cliente %s: %v", req.OwnerEmail, t.Name, err)
+			}
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(ClientInfo{ID: t.ID, Name: t.Name, Status: "active", CreatedAt: time.Now()})
+	case "DELETE":
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			http.Error(w, "name required", http.StatusBadRequest)
+			return
+		}
+		if name == defaultClientName() {
+			http.Error(w, "The instance default client cannot be deleted", http.StatusConflict)
+			return
+		}
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(ctx)
+		// Os arquivos enviados nas sessões ficam no armazenamento do orquestrador: as referências vão para
+		// a fila de exclusão antes que a cascata remova aiVoice_uploads, e o orquestrador apaga os arquivos
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO aiVoice_upload_deletions (id, storage_key)
+			SELECT id, storage_key FROM aiVoice_uploads
+			WHERE client_id = (SELECT id FROM aiVoice_clients WHERE name = $1)
+			ON CONFLICT (id) DO NOTHING`, name); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// aiVoice_calls não tem ON DELETE CASCADE; as demais tabelas são removidas em cascata
+		if _, err := tx.Exec(ctx, "DELETE FROM aiVoice_calls WHERE client_id = (SELECT id FROM aiVoice_clients WHERE name = $1)", name); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var clientID int
+		err = tx.QueryRow(ctx, "DELETE FROM aiVoice_clients WHERE name = $1 RETURNING id", name).Scan(&clientID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Client not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		go deleteClientFromMeili(clientID)
+		notifyConfigChange(name)
+		log.Printf("🗑️ Cliente %s removido", name)
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminClientStatus suspende ou reativa um cliente (POST ?name=).
+// O orquestrador recusa novas sessões de clientes suspensos.
+func handleAdminClientStatus(status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		name := r.URL.Query().Get("name")
+		res, err := db.Exec(context.Background(), "UPDATE aiVoice_clients SET status = $1 WHERE name = $2", status, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if res.RowsAffected() == 0 {
+			http.Error(w, "Client not found", http.StatusNotFound)
+			return
+		}
+		notifyConfigChange(name)
+		log.Printf("🔁 Cliente %s agora está %s", name, status)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func handleAdminTemplates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rows, err := db.Query(context.Background(), "SELECT name, COALESCE(description, '') FROM aiVoice_config_templates ORDER BY name")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	templates := []ConfigTemplate{}
+	for rows.Next() {
+		var t ConfigTemplate
+		if err := rows.Scan(&t.Name, &t.Description); err != nil {
+			continue
+		}
+		templates = append(templates, t)
+	}
+	json.NewEncoder(w).Encode(templates)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Nome de modelo Gemini aceito nas opções do cliente ("gemini-..." ou "models/gemini-...").
+var modelNamePattern = regexp.MustCompile(`^(models/)?[a-z0-9][a-z0-9.-]{0,99}$`)
+
+// maxFallbackModels limita a cadeia de modelos reserva tentados pelo orquestrador.
+const maxFallbackModels = 5
+
+// Limites do vídeo (tela/câmera) aceitos pelo orquestrador; 0 = padrão (1 quadro/s, 256 KB).
+const (
+	maxVideoFPS     = 2
+	maxVideoFrameKB = 1024
+)
+
+// maxUploadRetentionDays limita por quanto tempo os arquivos enviados nas sessões ficam guardados.
+const maxUploadRetentionDays = 365
+
+// ClientSettings são opções de sessão por cliente (colunas de aiVoice_clients) aplicadas pelo orquestrador.
+type ClientSettings struct {
+	// PushToTalk desliga a detecção automática de voz; o widget envia activity_start/activity_end
+	PushToTalk bool `json:"pushToTalk"`
+	// Model é o modelo Gemini Live principal (vazio = padrão do orquestrador)
+	Model string `json:"model"`
+	// FallbackModels são tentados em ordem quando o principal falha (indisponível, cota, etc.)
+	FallbackModels []string `json:"fallbackModels"`
+	// TextModel atende os chats só por texto (vazio = padrão do orquestrador; precisa aceitar TEXT no Live API)
+	TextModel string `json:"textModel"`
+	// TranscriptionModel transcreve as notas de voz do WhatsApp (vazio = padrão do orquestrador; modelo generateContent, não Live)
+	TranscriptionModel string `json:"transcriptionModel"`
+	// VideoInput libera o compartilhamento de tela/câmera no widget (quadros JPEG para o Gemini)
+	VideoInput bool `json:"videoInput"`
+	// VideoMaxFPS e VideoMaxFrameKB limitam a taxa e o tamanho dos quadros (0 = padrão do orquestrador)
+	VideoMaxFPS     float64 `json:"videoMaxFps"`
+	VideoMaxFrameKB int     `json:"videoMaxFrameKb"`
+	// UploadsEnabled libera o envio de imagens e PDFs pelo usuário durante a sessão
+	UploadsEnabled bool `json:"uploadsEnabled"`
+	// UploadRetentionDays é o prazo até o orquestrador apagar os arquivos (0 = padrão de 30 dias)
+	UploadRetentionDays int `json:"uploadRetentionDays"`
+}
+
+// validateModels normaliza e valida o modelo principal e a lista de reserva.
+func (s *ClientSettings) validateModels() string {
+	s.Model = strings.TrimSpace(s.Model)
+	if s.Model != "" && !modelNamePattern.MatchString(s.Model) {
+		return "invalid model: " + s.Model
+	}
+	seen := map[string]bool{strings.TrimPrefix(s.Model, "models/"): true}
+	fallbacks := []string{}
+	for _, m := range s.FallbackModels {
+		m = strings.TrimSpace(m)
+		if !modelNamePattern.MatchString(m) {
+			return "invalid fallback model: " + m
+		}
+		if key := strings.TrimPrefix(m, "models/"); !seen[key] {
+			seen[key] = true
+			fallbacks = append(fallbacks, m)
+		}
+	}
+	if len(fallbacks) > maxFallbackModels {
+		return "too many fallback models (max 5)"
+	}
+	s.FallbackModels = fallbacks
+	s.TextModel = strings.TrimSpace(s.TextModel)
+	if s.TextModel != "" && !modelNamePattern.MatchString(s.TextModel) {
+		return "invalid text model: " + s.TextModel
+	}
+	s.TranscriptionModel = strings.TrimSpace(s.TranscriptionModel)
+	if s.TranscriptionModel != "" && !modelNamePattern.MatchString(s.TranscriptionModel) {
+		return "invalid transcription model: " + s.TranscriptionModel
+	}
+	return ""
+}
+
+// validateVideo confere os limites de vídeo contra o máximo aceito pelo orquestrador.
+func (s *ClientSettings) validateVideo() string {
+	if s.VideoMaxFPS < 0 || s.VideoMaxFPS > maxVideoFPS {
+		return "videoMaxFps must be between 0 and 2"
+	}
+	if s.VideoMaxFrameKB < 0 || s.VideoMaxFrameKB > maxVideoFrameKB {
+		return "videoMaxFrameKb must be between 0 and 1024"
+	}
+	return ""
+}
+
+// validateUploads confere o prazo de retenção dos arquivos enviados nas sessões.
+func (s *ClientSettings) validateUploads() string {
+	if s.UploadRetentionDays < 0 || s.UploadRetentionDays > maxUploadRetentionDays {
+		return "uploadRetentionDays must be between 0 and 365"
+	}
+	return ""
+}
+
+// -- Handlers --
+
+// handleClientSettings lê (GET) e substitui (PUT) as opções de sessão do cliente atual.
+func handleClientSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	tenant := currentTenant(r)
+
+	switch r.Method {
+	case "GET":
+		var s ClientSettings
+		err := db.QueryRow(ctx, `
+			SELECT COALESCE(push_to_talk, false), COALESCE(model, ''), COALESCE(fallback_models, '{}'), COALESCE(text_model, ''),
+				COALESCE(transcription_model, ''), COALESCE(video_input, false), COALESCE(video_max_fps, 0), COALESCE(video_max_frame_kb, 0),
+				COALESCE(uploads_enabled, false), COALESCE(upload_retention_days, 0)
+			FROM aiVoice_clients WHERE id = $1`, tenant.ID).Scan(&s.PushToTalk, &s.Model, &s.FallbackModels, &s.TextModel,
+			&s.TranscriptionModel, &s.VideoInput, &s.VideoMaxFPS, &s.VideoMaxFrameKB, &s.UploadsEnabled, &s.UploadRetentionDays)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(s)
+
+	case "PUT":
+		var s ClientSettings
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if msg := s.validateModels(); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		if msg := s.validateVideo(); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		if msg := s.validateUploads(); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		if _, err := db.Exec(ctx, `
+			UPDATE aiVoice_clients SET push_to_talk = $1, model = NULLIF($2, ''), fallback_models = $3, text_model = NULLIF($4, ''),
+				transcription_model = NULLIF($5, ''), video_input = $6, video_max_fps = NULLIF($7::real, 0),
+				video_max_frame_kb = NULLIF($8::integer, 0), uploads_enabled = $9, upload_retention_days = NULLIF($10::integer, 0)
+			WHERE id = $11`,
+			s.PushToTalk, s.Model, s.FallbackModels, s.TextModel, s.TranscriptionModel, s.VideoInput, s.VideoMaxFPS,
+			s.VideoMaxFrameKB, s.UploadsEnabled, s.UploadRetentionDays, tenant.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		notifyConfigChange(tenant.Name)
+		log.Printf("⚙️ Opções de sessão de %s atualizadas por %s: %+v", tenant.Name, currentUserEmail(r), s)
+		json.NewEncoder(w).Encode(s)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,247 @@
+// aivoicectl administra os clientes (tenants) de um deploy aiVoice pela API de provisionamento
+// do dashboard-server.
+//
+// Uso:
+//
+//	aivoicectl [-url URL] [-token TOKEN] <comando> [argumentos]
+//
+//	list                                  lista os clientes
+//	templates                             lista os templates de configuração
+//	create <nome> [-template T] [-owner email]
+//	suspend <nome>
+//	reactivate <nome>
+//	delete <nome> -yes
+//	usage [-from AAAA-MM-DD] [-to AAAA-MM-DD] [-owner platform|client]
+//	rotate-secrets                        re-cifra os segredos do banco com a chave mestra ativa
+//
+// URL e token também podem vir de AIVOICE_URL e PROVISIONING_TOKEN.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+type client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func main() {
+	baseURL := flag.String("url", envOr("AIVOICE_URL", "http://localhost:8081"), "endereço do dashboard-server")
+	token := flag.String("token", os.Getenv("PROVISIONING_TOKEN"), "token de provisionamento")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	c := &client{baseURL: *baseURL, token: *token, http: &http.Client{Timeout: 15 * time.Second}}
+	if err := run(c, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "erro:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `uso: aivoicectl [-url URL] [-token TOKEN] <comando> [argumentos]
+
+comandos:
+  list                                  lista os clientes
+  templates                             lista os templates de configuração
+  create <nome> [-template T] [-owner email]
+  suspend <nome>
+  reactivate <nome>
+  delete <nome> -yes
+  usage [-from AAAA-MM-DD] [-to AAAA-MM-DD] [-owner platform|client]
+  rotate-secrets                        re-cifra os segredos do banco com a chave mestra ativa`)
+}
+
+func run(c *client, cmd string, args []string) error {
+	switch cmd {
+	case "list":
+		var clients []struct {
+			Name      string    `json:"name"`
+			Status    string    `json:"status"`
+			CreatedAt time.Time `json:"createdAt"`
+		}
+		if err := c.do("GET", "/api/admin/clients", nil, &clients); err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NOME\tSTATUS\tCRIADO EM")
+		for _, cl := range clients {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", cl.Name, cl.Status, cl.CreatedAt.Format("2006-01-02 15:04"))
+		}
+		return tw.Flush()
+
+	case "templates":
+		var templates []struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+		}
+		if err := c.do("GET", "/api/admin/templates", nil, &templates); err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "TEMPLATE\tDESCRIÇÃO")
+		for _, t := range templates {
+			fmt.Fprintf(tw, "%s\t%s\n", t.Name, t.Description)
+		}
+		return tw.Flush()
+
+	case "create":
+		fs := flag.NewFlagSet("create", flag.ExitOnError)
+		template := fs.String("template", "default", "template de configuração inicial")
+		owner := fs.String("owner", "", "email de um usuário do dashboard a vincular ao cliente")
+		name, err := nameArg(fs, args)
+		if err != nil {
+			return err
+		}
+		body := map[string]string{"name": name, "template": *template, "ownerEmail": *owner}
+		if err := c.do("POST", "/api/admin/clients", body, nil); err != nil {
+			return err
+		}
+		fmt.Printf("cliente %q criado (template %s)\n", name, *template)
+		return nil
+
+	case "suspend", "reactivate":
+		name, err := nameArg(flag.NewFlagSet(cmd, flag.ExitOnError), args)
+		if err != nil {
+			return err
+		}
+		if err := c.do("POST", "/api/admin/clients/"+cmd+"?name="+url.QueryEscape(name), nil, nil); err != nil {
+			return err
+		}
+		fmt.Printf("cliente %q: %s ok\n", name, cmd)
+		return nil
+
+	case "delete":
+		fs := flag.NewFlagSet("delete", flag.ExitOnError)
+		yes := fs.Bool("yes", false, "confirma a remoção definitiva (inclui chamadas e base de conhecimento)")
+		name, err := nameArg(fs, args)
+		if err != nil {
+			return err
+		}
+		if !*yes {
+			return fmt.Errorf("remoção é definitiva; repita com -yes para confirmar")
+		}
+		if err := c.do("DELETE", "/api/admin/clients?name="+url.QueryEscape(name), nil, nil); err != nil {
+			return err
+		}
+		fmt.Printf("cliente %q removido\n", name)
+		return nil
+
+	case "usage":
+		fs := flag.NewFlagSet("usage", flag.ExitOnError)
+		from := fs.String("from", "", "início do período (padrão: início do mês)")
+		to := fs.String("to", "", "fim do período, inclusivo")
+		owner := fs.String("owner", "", "platform (chaves da plataforma, para cobrança) ou client (BYOK)")
+		fs.Parse(args)
+		q := url.Values{}
+		for k, v := range map[string]string{"from": *from, "to": *to, "owner": *owner} {
+			if v != "" {
+				q.Set(k, v)
+			}
+		}
+		var report []struct {
+			ClientName   string `json:"clientName"`
+			KeyID        string `json:"keyId"`
+			Owner        string `json:"owner"`
+			Calls        int    `json:"calls"`
+			InputTokens  int64  `json:"inputTokens"`
+			OutputTokens int64  `json:"outputTokens"`
+		}
+		if err := c.do("GET", "/api/admin/key-usage?"+q.Encode(), nil, &report); err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "CLIENTE\tCHAVE\tDONO\tCHAMADAS\tTOKENS ENTRADA\tTOKENS SAÍDA")
+		for _, u := range report {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\n", u.ClientName, u.KeyID, u.Owner, u.Calls, u.InputTokens, u.OutputTokens)
+		}
+		return tw.Flush()
+
+	case "rotate-secrets":
+		var res struct {
+			ActiveKey string   `json:"activeKey"`
+			Rotated   int      `json:"rotated"`
+			Current   int      `json:"current"`
+			Failed    []string `json:"failed"`
+		}
+		if err := c.do("POST", "/api/admin/secrets/rotate", nil, &res); err != nil {
+			return err
+		}
+		fmt.Printf("chave ativa %s: %d re-cifrado(s), %d já atualizado(s)\n", res.ActiveKey, res.Rotated, res.Current)
+		if len(res.Failed) > 0 {
+			return fmt.Errorf("%d segredo(s) não puderam ser abertos com as chaves configuradas: %v", len(res.Failed), res.Failed)
+		}
+		return nil
+	}
+
+	usage()
+	return fmt.Errorf("comando desconhecido: %s", cmd)
+}
+
+// nameArg aceita o nome antes ou depois das flags do subcomando.
+func nameArg(fs *flag.FlagSet, args []string) (string, error) {
+	var name string
+	if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		name, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if name == "" {
+		name = fs.Arg(0)
+	}
+	if name == "" {
+		return "", fmt.Errorf("informe o nome do cliente")
+	}
+	return name, nil
+}
+
+func (c *client) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
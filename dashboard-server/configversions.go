@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// -- Structs --
+
+// ConfigAuthor identifica quem gravou uma versão (claims do JWT).
+type ConfigAuthor struct {
+	UserID int
+	Email  string
+}
+
+// ConfigChange é a alteração de um campo entre duas versões.
+type ConfigChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type ConfigVersion struct {
+	Version      int             `json:"version"`
+	AgentName    string          `json:"agentName"`
+	AuthorEmail  string          `json:"authorEmail"`
+	RestoredFrom *int            `json:"restoredFrom"`
+	Changes      []ConfigChange  `json:"changes"`
+	Snapshot     json.RawMessage `json:"snapshot,omitempty"`
+	CreatedAt    time.Time       `json:"createdAt"`
+}
+
+var errConfigNotFound = errors.New("config not found")
+
+// versionIgnoredFields não fazem parte do diff (identificação do agente e o próprio contador).
+var versionIgnoredFields = map[string]bool{"agentName": true, "isDefault": true, "version": true}
+
+func configAuthor(r *http.Request) ConfigAuthor {
+	return ConfigAuthor{UserID: currentUserID(r), Email: currentUserEmail(r)}
+}
+
+// -- Versionamento --
+
+// saveConfigVersion grava a configuração do agente e registra a nova versão com autor e diff,
+// tudo na mesma transação. restoredFrom > 0 indica que a gravação é um rollback para aquela versão.
+// Configurações anteriores ao versionamento ganham uma versão base (sem autor) na primeira gravação.
+func saveConfigVersion(ctx context.Context, tenant Tenant, agentName string, cfg AIConfig, author ConfigAuthor, restoredFrom int) (int, error) {
+	if cfg.EnabledTools == nil {
+		cfg.EnabledTools = []string{}
+	}
+	if cfg.KBCategories == nil {
+		cfg.KBCategories = []string{}
+	}
+	if cfg.BusinessHours == nil {
+		cfg.BusinessHours = []BusinessHoursRule{}
+	}
+	businessHours, _ := json.Marshal(cfg.BusinessHours)
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var configID int
+	err = tx.QueryRow(ctx, `
+		SELECT id FROM aiVoice_config
+		WHERE client_id = $1 AND (agent_name = $2 OR ($2 = '' AND is_default))
+		FOR UPDATE`, tenant.ID, agentName).Scan(&configID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, errConfigNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	prev, err := readConfigTx(ctx, tx, configID)
+	if err != nil {
+		return 0, err
+	}
+	// Sem leadSchema no corpo o schema atual é mantido (ele também é editado em /leads/schema)
+	if cfg.LeadSchema == nil {
+		cfg.LeadSchema = prev.LeadSchema
+	}
+	leadSchema, _ := json.Marshal(cfg.LeadSchema)
+	// A URL do webhook chega em texto puro (ou como máscara) e é gravada cifrada; no rollback ela vem
+	// do snapshot da própria configuração
+	if restoredFrom > 0 {
+		cfg.EscalationWebhookURL, err = restoreWebhookURL(cfg.EscalationWebhookURL)
+	} else {
+		cfg.EscalationWebhookURL, err = sealWebhookURL(cfg.EscalationWebhookURL, prev.EscalationWebhookURL)
+	}
+	if err != nil {
+		return 0, err
+	}
+	prevSnapshot, _ := json.Marshal(prev)
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO aiVoice_config_versions (config_id, version, snapshot)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (config_id, version) DO NOTHING`, configID, prev.Version, prevSnapshot); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE aiVoice_config SET
+			voice_name = $1,
+			language_code = $2,
+			temperature = $3,
+			thinking_budget = $4,
+			enable_affective_dialog = $5,
+			proactive_audio = $6,
+			system_prompt = $7,
+			docstring_tool_knowledge = $8,
+			duration_limit = $9,
+			termination_alert_time = $10,
+			docstring_tool_terminate = $11,
+			proactive_alert_instruction = $12,
+			docstring_tool_send_link = $13,
+			docstring_tool_escalate = $14,
+			escalation_webhook_url = $15,
+			docstring_tool_lead = $16,
+			scheduling_enabled = $17,
+			agent_description = $18,
+			enabled_tools = $19,
+			kb_categories = $20,
+			timezone = NULLIF($21, ''),
+			business_hours = $22,
+			top_p = $24,
+			top_k = $25,
+			max_output_tokens = $26,
+			vad_start_sensitivity = NULLIF($27, ''),
+			vad_end_sensitivity = NULLIF($28, ''),
+			vad_prefix_padding_ms = $29,
+			vad_silence_duration_ms = $30,
+			context_compression = $31,
+			compression_trigger_tokens = $32,
+			compression_target_tokens = $33,
+			lead_schema = $34,
+			version = version + 1,
+			updated_at = NOW()
+		WHERE id = $23`,
+		cfg.VoiceName, cfg.LanguageCode, cfg.Temperature, cfg.ThinkingBudget, cfg.EnableAffectiveDialog, cfg.ProactiveAudio, cfg.SystemPrompt, cfg.DocstringToolKnowledge, cfg.DurationLimit, cfg.TerminationAlertTime, cfg.DocstringToolTerminate, cfg.ProactiveAlertInstruction, cfg.DocstringToolSendLink, cfg.DocstringToolEscalate, cfg.EscalationWebhookURL, cfg.DocstringToolLead, cfg.SchedulingEnabled, cfg.AgentDescription, cfg.EnabledTools, cfg.KBCategories, cfg.Timezone, businessHours, configID,
+		cfg.TopP, cfg.TopK, cfg.MaxOutputTokens, cfg.VADStartSensitivity, cfg.VADEndSensitivity, cfg.VADPrefixPaddingMs, cfg.VADSilenceDurationMs, cfg.ContextCompression, cfg.CompressionTriggerTokens, cfg.CompressionTargetTokens, leadSchema)
+	if err != nil {
+		return 0, err
+	}
+
+	next, err := readConfigTx(ctx, tx, configID)
+	if err != nil {
+		return 0, err
+	}
+	snapshot, _ := json.Marshal(next)
+	diff, _ := json.Marshal(diffConfigs(prev, next))
+
+	var restored *int
+	if restoredFrom > 0 {
+		restored = &restoredFrom
+	}
+	var authorID *int
+	if author.UserID > 0 {
+		authorID = &author.UserID
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO aiVoice_config_versions (config_id, version, snapshot, diff, author_id, author_email, restored_from)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`, configID, next.Version, snapshot, diff, authorID, author.Email, restored); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	log.Printf("📝 Config de %s/%s salva por %s (versão %d)", tenant.Name, next.AgentName, author.Email, next.Version)
+	return next.Version, nil
+}
+
+func readConfigTx(ctx context.Context, tx pgx.Tx, configID int) (*AIConfig, error) {
+	var cfg AIConfig
+	err := scanConfig(tx.QueryRow(ctx, "SELECT "+configColumns+" FROM aiVoice_config c WHERE c.id = $1", configID), &cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// diffConfigs compara duas configurações campo a campo (pelos nomes JSON), em ordem alfabética.
+// A URL do webhook (cifrada) aparece mascarada.
+func diffConfigs(from, to *AIConfig) []ConfigChange {
+	var a, b map[string]interface{}
+	fromJSON, _ := json.Marshal(from)
+	toJSON, _ := json.Marshal(to)
+	json.Unmarshal(fromJSON, &a)
+	json.Unmarshal(toJSON, &b)
+
+	fields := map[string]bool{}
+	for k := range a {
+		fields[k] = true
+	}
+	for k := range b {
+		fields[k] = true
+	}
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		if !versionIgnoredFields[k] {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []ConfigChange{}
+	for _, k := range names {
+		if !reflect.DeepEqual(a[k], b[k]) {
+			change := ConfigChange{Field: k, From: a[k], To: b[k]}
+			if k == "escalationWebhookUrl" {
+				change.From, change.To = maskWebhookURL(from.EscalationWebhookURL), maskWebhookURL(to.EscalationWebhookURL)
+			}
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// versionConfigID resolve o registro de configuração do agente (?agent=, vazio = padrão) dentro do tenant.
+func versionConfigID(ctx context.Context, tenant Tenant, agentName string) (int, error) {
+	var id int
+	err := db.QueryRow(ctx, `
+		SELECT id FROM aiVoice_config
+		WHERE client_id = $1 AND (agent_name = $2 OR ($2 = '' AND is_default))`, tenant.ID, agentName).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, errConfigNotFound
+	}
+	return id, err
+}
+
+func fetchConfigVersion(ctx context.Context, configID, version int) (*ConfigVersion, *AIConfig, error) {
+	var v ConfigVersion
+	var diff []byte
+	err := db.QueryRow(ctx, `
+		SELECT cv.version, c.agent_name, COALESCE(cv.author_email, ''), cv.restored_from, cv.diff, cv.snapshot, cv.created_at
+		FROM aiVoice_config_versions cv
+		JOIN aiVoice_config c ON cv.config_id = c.id
+		WHERE cv.config_id = $1 AND cv.version = $2`, configID, version).Scan(
+		&v.Version, &v.AgentName, &v.AuthorEmail, &v.RestoredFrom, &diff, &v.Snapshot, &v.CreatedAt,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	json.Unmarshal(diff, &v.Changes)
+	var cfg AIConfig
+	if err := json.Unmarshal(v.Snapshot, &cfg); err != nil {
+		return nil, nil, err
+	}
+	return &v, &cfg, nil
+}
+
+// -- Handlers --
+
+// handleConfigVersions lista o histórico do agente (?agent=) ou devolve uma versão com snapshot (?version=).
+func handleConfigVersions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := context.Background()
+	configID, err := versionConfigID(ctx, currentTenant(r), r.URL.Query().Get("agent"))
+	if errors.Is(err, errConfigNotFound) {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if v := r.URL.Query().Get("version"); v != "" {
+		version, _ := strconv.Atoi(v)
+		cv, cfg, err := fetchConfigVersion(ctx, configID, version)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Version not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cfg.EscalationWebhookURL = maskWebhookURL(cfg.EscalationWebhookURL)
+		cv.Snapshot, _ = json.Marshal(cfg)
+		json.NewEncoder(w).Encode(cv)
+		return
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT cv.version, c.agent_name, COALESCE(cv.author_email, ''), cv.restored_from, cv.diff, cv.created_at
+		FROM aiVoice_config_versions cv
+		JOIN aiVoice_config c ON cv.config_id = c.id
+		WHERE cv.config_id = $1
+		ORDER BY cv.version DESC`, configID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	versions := []ConfigVersion{}
+	for rows.Next() {
+		var v ConfigVersion
+		var diff []byte
+		if err := rows.Scan(&v.Version, &v.AgentName, &v.AuthorEmail, &v.RestoredFrom, &diff, &v.CreatedAt); err != nil {
+			continue
+		}
+		json.Unmarshal(diff, &v.Changes)
+		versions = append(versions, v)
+	}
+	json.NewEncoder(w).Encode(versions)
+}
+
+// handleConfigVersionDiff compara duas versões do agente (?agent=&from=&to=).
+func handleConfigVersionDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := context.Background()
+	q := r.URL.Query()
+	configID, err := versionConfigID(ctx, currentTenant(r), q.Get("agent"))
+	if errors.Is(err, errConfigNotFound) {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	from, errFrom := strconv.Atoi(q.Get("from"))
+	to, errTo := strconv.Atoi(q.Get("to"))
+	if errFrom != nil || errTo != nil {
+		http.Error(w, "from and to are required", http.StatusBadRequest)
+		return
+	}
+	_, fromCfg, err := fetchConfigVersion(ctx, configID, from)
+	if err == nil {
+		var toCfg *AIConfig
+		if _, toCfg, err = fetchConfigVersion(ctx, configID, to); err == nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"from":    from,
+				"to":      to,
+				"changes": diffConfigs(fromCfg, toCfg),
+			})
+			return
+		}
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// handleConfigVersionRestore restaura uma versão (POST ?agent=&version=) gravando-a como uma nova versão,
+// de modo que o rollback também fica no histórico.
+func handleConfigVersionRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := context.Background()
+	tenant := currentTenant(r)
+	agentName := r.URL.Query().Get("agent")
+	configID, err := versionConfigID(ctx, tenant, agentName)
+	if errors.Is(err, errConfigNotFound) {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	version, _ := strconv.Atoi(r.URL.Query().Get("version"))
+	_, cfg, err := fetchConfigVersion(ctx, configID, version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	newVersion, err := saveConfigVersion(ctx, tenant, cfg.AgentName, *cfg, configAuthor(r), version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	notifyConfigChange(tenant.Name)
+	log.Printf("⏪ Config de %s/%s restaurada para a versão %d por %s", tenant.Name, cfg.AgentName, version, currentUserEmail(r))
+	json.NewEncoder(w).Encode(map[string]int{"version": newVersion})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Contexto do visitante enviado pelo widget no setup ({"context": {"pageUrl", "referrer", "userId", "locale", "custom": {...}}}).
+// O orquestrador só aceita os campos da allowlist do cliente (aiVoice_clients.context_fields); chaves
+// customizadas entram como "custom.<chave>".
+
+var (
+	standardContextFields = map[string]bool{"pageUrl": true, "referrer": true, "userId": true, "locale": true}
+	contextKeyPattern     = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,39}$`)
+)
+
+// CallContext espelha orchestrator.CallContext (dados de {{.Context.*}} no template do prompt).
+type CallContext struct {
+	PageURL  string            `json:"pageUrl,omitempty"`
+	Referrer string            `json:"referrer,omitempty"`
+	UserID   string            `json:"userId,omitempty"`
+	Locale   string            `json:"locale,omitempty"`
+	Custom   map[string]string `json:"custom,omitempty"`
+}
+
+func (c CallContext) IsEmpty() bool {
+	return c.PageURL == "" && c.Referrer == "" && c.UserID == "" && c.Locale == "" && len(c.Custom) == 0
+}
+
+type ContextFieldsRequest struct {
+	Fields []string `json:"fields"`
+}
+
+func validContextField(f string) bool {
+	if standardContextFields[f] {
+		return true
+	}
+	key, ok := strings.CutPrefix(f, "custom.")
+	return ok && contextKeyPattern.MatchString(key)
+}
+
+// callContextFilter monta o objeto JSONB usado em caller_context @> $n a partir de ?context.<campo>=valor.
+// Sem filtros devolve nil (a consulta ignora a condição).
+func callContextFilter(q url.Values) (json.RawMessage, error) {
+	filter := map[string]interface{}{}
+	custom := map[string]string{}
+	for k, v := range q {
+		field, ok := strings.CutPrefix(k, "context.")
+		if !ok || len(v) == 0 || v[0] == "" {
+			continue
+		}
+		if !validContextField(field) {
+			return nil, errors.New("invalid context filter: " + field)
+		}
+		if key, isCustom := strings.CutPrefix(field, "custom."); isCustom {
+			custom[key] = v[0]
+		} else {
+			filter[field] = v[0]
+		}
+	}
+	if len(custom) > 0 {
+		filter["custom"] = custom
+	}
+	if len(filter) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(filter)
+}
+
+// nullableJSON trata corpo vazio ou "null" como ausente (NULL no banco).
+func nullableJSON(raw json.RawMessage) json.RawMessage {
+	if t := bytes.TrimSpace(raw); len(t) == 0 || string(t) == "null" {
+		return nil
+	}
+	return raw
+}
+
+// -- Handlers --
+
+// handleContextFields lê (GET) e substitui (PUT) a allowlist de campos de contexto do cliente.
+func handleContextFields(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	tenant := currentTenant(r)
+
+	switch r.Method {
+	case "GET":
+		var fields []string
+		err := db.QueryRow(ctx, "SELECT COALESCE(context_fields, '{}') FROM aiVoice_clients WHERE id = $1", tenant.ID).Scan(&fields)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(ContextFieldsRequest{Fields: fields})
+
+	case "PUT":
+		var req ContextFieldsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		seen := map[string]bool{}
+		fields := []string{}
+		for _, f := range req.Fields {
+			if !validContextField(f) {
+				http.Error(w, "invalid context field: "+f+" (use pageUrl, referrer, userId, locale or custom.<key>)", http.StatusBadRequest)
+				return
+			}
+			if !seen[f] {
+				seen[f] = true
+				fields = append(fields, f)
+			}
+		}
+		if _, err := db.Exec(ctx, "UPDATE aiVoice_clients SET context_fields = $1 WHERE id = $2", fields, tenant.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		notifyConfigChange(tenant.Name)
+		log.Printf("🧭 Campos de contexto de %s atualizados por %s: %v", tenant.Name, currentUserEmail(r), fields)
+		json.NewEncoder(w).Encode(ContextFieldsRequest{Fields: fields})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// -- Structs --
+
+type Escalation struct {
+	ID         int             `json:"id"`
+	CallID     string          `json:"callId"`
+	ClientName string          `json:"clientName"`
+	Reason     string          `json:"reason"`
+	Summary    string          `json:"summary"`
+	Contact    json.RawMessage `json:"contact"`
+	Status     string          `json:"status"` // open, claimed, resolved
+	ClaimedBy  *string         `json:"claimedBy"`
+	ClaimedAt  *time.Time      `json:"claimedAt"`
+	ResolvedAt *time.Time      `json:"resolvedAt"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	Context    json.RawMessage `json:"context,omitempty"` // Contexto do visitante (só no webhook)
+}
+
+type EscalationRequest struct {
+	CallID     string          `json:"callId"`
+	ClientName string          `json:"clientName"`
+	Reason     string          `json:"reason"`
+	Summary    string          `json:"summary"`
+	Contact    json.RawMessage `json:"contact"`
+	Context    json.RawMessage `json:"context"`
+}
+
+// -- Handlers --
+
+// handleCreateEscalation é chamado pelo orquestrador quando o agente invoca transferir_para_humano.
+func handleCreateEscalation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req EscalationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.CallID == "" {
+		http.Error(w, "callId is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Contact) == 0 {
+		req.Contact = json.RawMessage("{}")
+	}
+
+	ctx := context.Background()
+	var clientID int
+	if err := db.QueryRow(ctx, "SELECT id FROM aiVoice_clients WHERE name = $1", req.ClientName).Scan(&clientID); err != nil {
+		http.Error(w, "Unknown client", http.StatusNotFound)
+		return
+	}
+
+	var e Escalation
+	err := db.QueryRow(ctx, `
+		INSERT INTO aiVoice_escalations (call_id, client_id, reason, summary, contact)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, call_id, reason, summary, contact, status, created_at`,
+		req.CallID, clientID, req.Reason, req.Summary, req.Contact,
+	).Scan(&e.ID, &e.CallID, &e.Reason, &e.Summary, &e.Contact, &e.Status, &e.CreatedAt)
+	if err != nil {
+		log.Printf("[ESCALATION ERROR] Erro criando escalonamento para Call %s: %v", req.CallID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	e.ClientName = req.ClientName
+	e.Context = nullableJSON(req.Context)
+
+	// Marca a chamada (se já sincronizada); o sync seguinte também carrega a flag.
+	db.Exec(ctx, "UPDATE aiVoice_calls SET escalated = true, updated_at = NOW() WHERE call_id = $1 AND client_id = $2", req.CallID, clientID)
+
+	var storedURL string
+	db.QueryRow(ctx, `
+		SELECT COALESCE(escalation_webhook_url, '')
+		FROM aiVoice_config
+		WHERE client_id = $1
+		ORDER BY is_default DESC
+		LIMIT 1`, clientID).Scan(&storedURL)
+	webhookURL, err := openWebhookURL(storedURL)
+	if err != nil {
+		log.Printf("⚠️ Webhook de escalonamento não enviado (#%d): URL indisponível: %v", e.ID, err)
+	}
+	if webhookURL != "" {
+		secret, err := webhookSigningSecret(ctx, clientID)
+		if err != nil {
+			log.Printf("⚠️ Webhook de escalonamento não enviado (#%d): segredo de assinatura indisponível: %v", e.ID, err)
+		} else {
+			go notifyEscalationWebhook(webhookURL, secret, e)
+		}
+	}
+
+	log.Printf("[ESCALATION] Ticket #%d aberto para Call %s", e.ID, e.CallID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(e)
+}
+
+// notifyEscalationWebhook envia o ticket ao webhook do cliente, assinado com o segredo do cliente
+// (ver signWebhook) para o receptor conferir a origem.
+func notifyEscalationWebhook(webhookURL, secret string, e Escalation) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"event":      "escalation.created",
+		"escalation": e,
+	})
+	req, err := http.NewRequest("POST", webhookURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("⚠️ Webhook de escalonamento inválido (#%d): %v", e.ID, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signWebhook(req, secret, body, time.Now())
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		log.Printf("⚠️ Webhook de escalonamento falhou (#%d): %v", e.ID, err)
+		return
+	}
+	resp.Body.Close()
+	log.Printf("✅ Webhook de escalonamento notificado (#%d): %d", e.ID, resp.StatusCode)
+}
+
+// -- Destino dos webhooks --
+
+// cgnatRange (100.64.0.0/10) também não é endereço público.
+var cgnatRange = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicIP recusa loopback, redes privadas, link-local (inclui o metadata 169.254.169.254),
+// multicast e endereços não especificados: o webhook é configurado pelo cliente e não pode
+// alcançar a rede interna.
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || cgnatRange.Contains(ip))
+}
+
+// webhookDialControl confere o IP no momento da conexão (o DNS pode mudar depois da validação e
+// redirecionamentos passam por aqui também).
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return fmt.Errorf("webhook destination %s is not a public address", host)
+	}
+	return nil
+}
+
+// webhookClient envia os webhooks dos clientes; sem proxy, para o controle de IP valer.
+var webhookClient = &http.Client{
+	Timeout: 5 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: webhookDialControl}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+}
+
+// -- Assinatura dos webhooks --
+
+// Os webhooks levam X-AIVoice-Timestamp (unix) e X-AIVoice-Signature: sha256=<hex>, o HMAC-SHA256 de
+// "<timestamp>.<corpo>" com o segredo do cliente. O receptor recalcula e recusa timestamps antigos.
+const (
+	webhookTimestampHeader = "X-AIVoice-Timestamp"
+	webhookSignatureHeader = "X-AIVoice-Signature"
+)
+
+func signWebhook(req *http.Request, secret string, body []byte, now time.Time) {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	req.Header.Set(webhookTimestampHeader, ts)
+	req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// webhookSigningSecret devolve o segredo de assinatura do cliente (aiVoice_clients.webhook_secret,
+// cifrado), gerando-o no primeiro uso.
+func webhookSigningSecret(ctx context.Context, clientID int) (string, error) {
+	var enc string
+	if err := db.QueryRow(ctx, "SELECT COALESCE(webhook_secret, '') FROM aiVoice_clients WHERE id = $1", clientID).Scan(&enc); err != nil {
+		return "", err
+	}
+	if enc != "" {
+		return decryptSecret(enc)
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return "", err
+	}
+	if enc, err = encryptSecret(secret); err != nil {
+		return "", err
+	}
+	// Outro pedido pode ter gerado o segredo ao mesmo tempo: vale o que ficou gravado
+	if err := db.QueryRow(ctx, `
+		UPDATE aiVoice_clients SET webhook_secret = COALESCE(NULLIF(webhook_secret, ''), $1)
+		WHERE id = $2 RETURNING webhook_secret`, enc, clientID).Scan(&enc); err != nil {
+		return "", err
+	}
+	return decryptSecret(enc)
+}
+
+// handleWebhookSecret mostra (GET) ou troca (POST) o segredo que assina os webhooks do cliente atual.
+func handleWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	tenant := currentTenant(r)
+
+	switch r.Method {
+	case "GET":
+		secret, err := webhookSigningSecret(ctx, tenant.ID)
+		if err != nil {
+			log.Printf("❌ Erro ao ler o segredo de webhook de %s: %v", tenant.Name, err)
+			http.Error(w, "Secret storage unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"secret": secret})
+
+	case "POST":
+		secret, err := newWebhookSecret()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		enc, err := encryptSecret(secret)
+		if err != nil {
+			log.Printf("❌ Erro ao cifrar o segredo de webhook de %s: %v", tenant.Name, err)
+			http.Error(w, "Secret storage unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if _, err := db.Exec(ctx, "UPDATE aiVoice_clients SET webhook_secret = $1 WHERE id = $2", enc, tenant.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("🔑 Segredo de webhook de %s trocado por %s", tenant.Name, currentUserEmail(r))
+		json.NewEncoder(w).Encode(map[string]string{"secret": secret})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// -- URL do webhook (cifrada) --
+
+// aiVoice_config.escalation_webhook_url guarda a URL cifrada (pode levar token na query) e a API só
+// devolve a máscara. Um PUT que devolve a máscara mantém a URL gravada.
+
+func isMaskedSecret(value string) bool {
+	return strings.HasPrefix(value, "••••")
+}
+
+// validateWebhookURL aceita vazio (desliga o webhook), a máscara (mantém a atual) ou uma URL http(s)
+// cujo host resolve só para endereços públicos (ver publicIP).
+func validateWebhookURL(value string) error {
+	if value == "" || isMaskedSecret(value) {
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("escalationWebhookUrl must be an http(s) URL")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return errors.New("escalationWebhookUrl host does not resolve")
+	}
+	for _, a := range addrs {
+		if !publicIP(a.IP) {
+			return errors.New("escalationWebhookUrl must point to a public address")
+		}
+	}
+	return nil
+}
+
+// sealWebhookURL converte o valor recebido num PUT para o que é gravado: vazio desliga, a máscara ou o
+// próprio valor gravado mantêm a URL atual e qualquer outro valor é uma URL nova (já validada por
+// validateWebhookURL), que é cifrada. Um valor cifrado vindo de fora nunca é aceito como está.
+func sealWebhookURL(value, stored string) (string, error) {
+	switch {
+	case value == "":
+		return "", nil
+	case value == stored || isMaskedSecret(value):
+		return stored, nil
+	}
+	return encryptSecret(value)
+}
+
+// restoreWebhookURL prepara a URL de um snapshot da própria configuração para ser regravada: os
+// snapshots guardam o valor cifrado; os anteriores à criptografia, a URL em texto puro.
+func restoreWebhookURL(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if _, err := decryptSecret(value); err == nil {
+		return value, nil
+	}
+	return encryptSecret(value)
+}
+
+// openWebhookURL decifra a URL gravada.
+func openWebhookURL(stored string) (string, error) {
+	if stored == "" {
+		return "", nil
+	}
+	return decryptSecret(stored)
+}
+
+// maskWebhookURL é o que a API mostra no lugar da URL gravada.
+func maskWebhookURL(stored string) string {
+	if stored == "" {
+		return ""
+	}
+	plain, err := openWebhookURL(stored)
+	if err != nil {
+		return "••••"
+	}
+	return maskSecret(plain)
+}
+
+// encryptLegacyWebhookURLs cifra as URLs gravadas em texto puro antes de elas passarem a ser cifradas.
+func encryptLegacyWebhookURLs(ctx context.Context) {
+	rows, err := db.Query(ctx, "SELECT id, escalation_webhook_url FROM aiVoice_config WHERE escalation_webhook_url ~* '^https?://'")
+	if err != nil {
+		log.Printf("⚠️ Erro ao listar webhooks de escalonamento em texto puro: %v", err)
+		return
+	}
+	type legacy struct {
+		id  int
+		url string
+	}
+	var pending []legacy
+	for rows.Next() {
+		var l legacy
+		if err := rows.Scan(&l.id, &l.url); err == nil {
+			pending = append(pending, l)
+		}
+	}
+	rows.Close()
+
+	for _, l := range pending {
+		enc, err := encryptSecret(l.url)
+		if err != nil {
+			log.Printf("⚠️ Webhooks de escalonamento continuam em texto puro: %v", err)
+			return
+		}
+		if _, err := db.Exec(ctx, "UPDATE aiVoice_config SET escalation_webhook_url = $1 WHERE id = $2 AND escalation_webhook_url = $3", enc, l.id, l.url); err != nil {
+			log.Printf("⚠️ Erro ao cifrar o webhook de escalonamento da config %d: %v", l.id, err)
+		}
+	}
+	if len(pending) > 0 {
+		log.Printf("🔐 %d webhooks de escalonamento cifrados", len(pending))
+	}
+}
+
+func handleEscalations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Fila: por padrão exibe apenas tickets não resolvidos
+	status := r.URL.Query().Get("status")
+	rows, err := db.Query(context.Background(), `
+		SELECT e.id, e.call_id, COALESCE(cl.name, ''), e.reason, e.summary, e.contact, e.status, e.claimed_by, e.claimed_at, e.resolved_at, e.created_at
+		FROM aiVoice_escalations e
+		LEFT JOIN aiVoice_clients cl ON e.client_id = cl.id
+		WHERE e.client_id = $2 AND (($1 = '' AND e.status <> 'resolved') OR e.status = $1 OR $1 = 'all')
+		ORDER BY e.created_at ASC
+		LIMIT 100`, status, currentTenant(r).ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var items []Escalation
+	for rows.Next() {
+		var e Escalation
+		if err := rows.Scan(&e.ID, &e.CallID, &e.ClientName, &e.Reason, &e.Summary, &e.Contact, &e.Status, &e.ClaimedBy, &e.ClaimedAt, &e.ResolvedAt, &e.CreatedAt); err != nil {
+			continue
+		}
+		items = append(items, e)
+	}
+	if items == nil {
+		items = []Escalation{}
+	}
+	json.NewEncoder(w).Encode(items)
+}
+
+// handleEscalationItem altera o estado de um ticket: open -> claimed -> resolved.
+func handleEscalationItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var query string
+	switch req.Status {
+	case "claimed":
+		query = "UPDATE aiVoice_escalations SET status = 'claimed', claimed_by = $2, claimed_at = NOW() WHERE id = $1 AND client_id = $3 AND status = 'open'"
+	case "resolved":
+		query = "UPDATE aiVoice_escalations SET status = 'resolved', claimed_by = COALESCE(claimed_by, $2), resolved_at = NOW() WHERE id = $1 AND client_id = $3 AND status <> 'resolved'"
+	case "open":
+		// Devolve o ticket à fila (apenas quem o assumiu)
+		query = "UPDATE aiVoice_escalations SET status = 'open', claimed_by = NULL, claimed_at = NULL WHERE id = $1 AND client_id = $3 AND status = 'claimed' AND claimed_by = $2"
+	default:
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+
+	res, err := db.Exec(context.Background(), query, id, currentUserEmail(r), currentTenant(r).ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if res.RowsAffected() == 0 {
+		http.Error(w, "Invalid state transition", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
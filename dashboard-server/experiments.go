@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// -- Structs --
+
+type Experiment struct {
+	ID        int                 `json:"id"`
+	Name      string              `json:"name"`
+	AgentName string              `json:"agentName"`
+	Status    string              `json:"status"` // running, stopped
+	Variants  []ExperimentVariant `json:"variants"`
+	CreatedAt time.Time           `json:"createdAt"`
+	EndedAt   *time.Time          `json:"endedAt"`
+}
+
+// ExperimentVariant sobrescreve parte da configuração do agente (nomes de campo do AIConfig).
+// Overrides vazio ({}) é o grupo de controle.
+type ExperimentVariant struct {
+	Name      string          `json:"name"`
+	Weight    int             `json:"weight"`
+	Overrides json.RawMessage `json:"overrides"`
+}
+
+// VariantResult agrega as chamadas atribuídas a uma variante.
+type VariantResult struct {
+	Variant         string   `json:"variant"`
+	Calls           int      `json:"calls"`
+	Completed       int      `json:"completed"`
+	Interrupted     int      `json:"interrupted"`
+	CompletionRate  *float64 `json:"completionRate"` // Completed / (Completed + Interrupted)
+	AvgDuration     float64  `json:"avgDurationSeconds"`
+	AvgInputTokens  float64  `json:"avgInputTokens"`
+	AvgOutputTokens float64  `json:"avgOutputTokens"`
+	EscalationRate  *float64 `json:"escalationRate"`
+	LeadRate        *float64 `json:"leadRate"`
+}
+
+// experimentOverrideFields são os campos do AIConfig que uma variante pode alterar.
+var experimentOverrideFields = map[string]bool{
+	"systemPrompt": true, "voiceName": true, "languageCode": true, "temperature": true, "thinkingBudget": true,
+	"topP": true, "topK": true, "maxOutputTokens": true,
+	"enableAffectiveDialog": true, "proactiveAudio": true, "proactiveAlertInstruction": true,
+	"durationLimit": true, "terminationAlertTime": true, "enabledTools": true,
+	"docstringToolKnowledge": true, "docstringToolTerminate": true, "docstringToolSendLink": true,
+	"docstringToolEscalate": true, "docstringToolLead": true,
+}
+
+// validateExperiment confere variantes (ao menos duas, nomes únicos, pesos positivos) e os campos sobrescritos.
+func validateExperiment(exp *Experiment) error {
+	if exp.Name == "" || len(exp.Variants) < 2 {
+		return errors.New("an experiment needs a name and at least two variants")
+	}
+	seen := map[string]bool{}
+	for i, v := range exp.Variants {
+		if v.Name == "" || seen[v.Name] {
+			return errors.New("variant names must be unique and non-empty")
+		}
+		seen[v.Name] = true
+		if v.Weight <= 0 {
+			return errors.New("variant weights must be positive")
+		}
+		if len(bytes.TrimSpace(v.Overrides)) == 0 || string(v.Overrides) == "null" {
+			exp.Variants[i].Overrides = json.RawMessage("{}")
+			continue
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(v.Overrides, &fields); err != nil {
+			return errors.New("variant overrides must be a JSON object")
+		}
+		for k := range fields {
+			if !experimentOverrideFields[k] {
+				return errors.New("field cannot be overridden by an experiment: " + k)
+			}
+		}
+		var typed AIConfig
+		if err := json.Unmarshal(v.Overrides, &typed); err != nil {
+			return errors.New("invalid override value: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// -- Handlers --
+
+// handleExperiments lista (GET), cria (POST) e remove (DELETE ?id=) experimentos do cliente.
+// Só pode haver um experimento em andamento por agente; a atribuição é feita pelo orquestrador no setup.
+func handleExperiments(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	tenant := currentTenant(r)
+
+	switch r.Method {
+	case "GET":
+		rows, err := db.Query(ctx, `
+			SELECT e.id, e.name, e.agent_name, e.status, e.created_at, e.ended_at, v.name, v.weight, v.overrides
+			FROM aiVoice_experiments e
+			JOIN aiVoice_experiment_variants v ON v.experiment_id = e.id
+			WHERE e.client_id = $1
+			ORDER BY e.created_at DESC, e.id, v.id`, tenant.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		experiments := []Experiment{}
+		for rows.Next() {
+			var e Experiment
+			var v ExperimentVariant
+			if err := rows.Scan(&e.ID, &e.Name, &e.AgentName, &e.Status, &e.CreatedAt, &e.EndedAt, &v.Name, &v.Weight, &v.Overrides); err != nil {
+				continue
+			}
+			if n := len(experiments); n == 0 || experiments[n-1].ID != e.ID {
+				experiments = append(experiments, e)
+			}
+			last := &experiments[len(experiments)-1]
+			last.Variants = append(last.Variants, v)
+		}
+		json.NewEncoder(w).Encode(experiments)
+
+	case "POST":
+		var exp Experiment
+		if err := json.NewDecoder(r.Body).Decode(&exp); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateExperiment(&exp); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		// Agente vazio = agente padrão do cliente
+		err = tx.QueryRow(ctx, `
+			SELECT agent_name FROM aiVoice_config
+			WHERE client_id = $1 AND (agent_name = $2 OR ($2 = '' AND is_default))`, tenant.ID, exp.AgentName).Scan(&exp.AgentName)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Agent not found", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		err = tx.QueryRow(ctx, `
+			INSERT INTO aiVoice_experiments (client_id, agent_name, name, status)
+			VALUES ($1, $2, $3, 'running')
+			RETURNING id, status, created_at`, tenant.ID, exp.AgentName, exp.Name).Scan(&exp.ID, &exp.Status, &exp.CreatedAt)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			http.Error(w, "This agent already has a running experiment", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, v := range exp.Variants {
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO aiVoice_experiment_variants (experiment_id, name, weight, overrides)
+				VALUES ($1, $2, $3, $4)`, exp.ID, v.Name, v.Weight, v.Overrides); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := tx.Commit(ctx); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		notifyConfigChange(tenant.Name)
+		log.Printf("🧪 Experimento '%s' iniciado para %s/%s por %s", exp.Name, tenant.Name, exp.AgentName, currentUserEmail(r))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(exp)
+
+	case "DELETE":
+		res, err := db.Exec(ctx, "DELETE FROM aiVoice_experiments WHERE id = $1 AND client_id = $2", r.URL.Query().Get("id"), tenant.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if res.RowsAffected() == 0 {
+			http.Error(w, "Experiment not found", http.StatusNotFound)
+			return
+		}
+		notifyConfigChange(tenant.Name)
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleExperimentStop encerra um experimento (POST ?id=); novas chamadas voltam à configuração do agente.
+func handleExperimentStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tenant := currentTenant(r)
+	res, err := db.Exec(context.Background(), `
+		UPDATE aiVoice_experiments SET status = 'stopped', ended_at = NOW()
+		WHERE id = $1 AND client_id = $2 AND status = 'running'`, r.URL.Query().Get("id"), tenant.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if res.RowsAffected() == 0 {
+		http.Error(w, "Experiment not found or already stopped", http.StatusNotFound)
+		return
+	}
+	notifyConfigChange(tenant.Name)
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleExperimentResults compara as variantes de um experimento (GET ?id=).
+func handleExperimentResults(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := context.Background()
+	tenant := currentTenant(r)
+
+	rows, err := db.Query(ctx, `
+		SELECT v.name,
+			COUNT(c.id),
+			COUNT(c.id) FILTER (WHERE c.status = 'Completed'),
+			COUNT(c.id) FILTER (WHERE c.status = 'Interrupted'),
+			COALESCE(AVG(c.duration_seconds), 0),
+			COALESCE(AVG(c.input_tokens), 0),
+			COALESCE(AVG(c.output_tokens), 0),
+			COUNT(c.id) FILTER (WHERE c.escalated),
+			COUNT(c.id) FILTER (WHERE EXISTS (SELECT 1 FROM aiVoice_leads l WHERE l.call_id = c.call_id))
+		FROM aiVoice_experiments e
+		JOIN aiVoice_experiment_variants v ON v.experiment_id = e.id
+		LEFT JOIN aiVoice_calls c ON c.experiment_id = e.id AND c.experiment_variant = v.name AND c.client_id = e.client_id
+		WHERE e.id = $1 AND e.client_id = $2
+		GROUP BY v.id, v.name
+		ORDER BY v.id`, r.URL.Query().Get("id"), tenant.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	results := []VariantResult{}
+	for rows.Next() {
+		var v VariantResult
+		var escalated, leads int
+		if err := rows.Scan(&v.Variant, &v.Calls, &v.Completed, &v.Interrupted, &v.AvgDuration, &v.AvgInputTokens, &v.AvgOutputTokens, &escalated, &leads); err != nil {
+			continue
+		}
+		v.CompletionRate = ratio(v.Completed, v.Completed+v.Interrupted)
+		v.EscalationRate = ratio(escalated, v.Calls)
+		v.LeadRate = ratio(leads, v.Calls)
+		results = append(results, v)
+	}
+	if len(results) == 0 {
+		http.Error(w, "Experiment not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(results)
+}
+
+// ratio devolve nil quando não há base de comparação (evita mostrar 0% sem dados).
+func ratio(n, total int) *float64 {
+	if total == 0 {
+		return nil
+	}
+	r := float64(n) / float64(total)
+	return &r
+}
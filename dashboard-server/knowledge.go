@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -21,6 +22,7 @@ type KnowledgeItem struct {
 	Answer       string    `json:"answer"`
 	CategoryID   *int      `json:"categoryId"`
 	CategoryName string    `json:"categoryName"`
+	ClientID     int       `json:"-"`
 	CreatedAt    time.Time `json:"createdAt"`
 }
 
@@ -89,6 +91,9 @@ func initMeiliSearch() {
 	}
 	// 2. Configure Settings (Embedders & Searchable Attributes)
 	configureSettings()
+
+	// 3. Reindexa a base para garantir o client_id em todos os documentos
+	reindexMeili()
 }
 
 func configureSettings() {
@@ -113,7 +118,7 @@ func configureSettings() {
 			},
 		},
 		SearchableAttributes: []string{"question", "answer"},
-		FilterableAttributes: []string{"category"},
+		FilterableAttributes: []string{"category", "client_id"}, // client_id: filtro obrigatório de tenant
 	}
 
 	body, _ := json.Marshal(settings)
@@ -154,6 +159,7 @@ func syncToMeili(item KnowledgeItem) {
 		"question": item.Question,
 		"answer":   item.Answer,
         "category": item.CategoryName,
+		"client_id": item.ClientID,
 	}
 	
 	body, _ := json.Marshal([]interface{}{doc}) // Must be an array
@@ -165,6 +171,67 @@ func syncToMeili(item KnowledgeItem) {
 	client.Do(req)
 }
 
+// reindexMeili reenvia toda a base de conhecimento ao índice (documentos carregam o client_id).
+func reindexMeili() {
+	if db == nil {
+		return
+	}
+	rows, err := db.Query(context.Background(), `
+		SELECT k.id, k.question, k.answer, COALESCE(c.name, 'Sem Categoria'), k.client_id
+		FROM knowledge_base k
+		LEFT JOIN knowledge_categories c ON k.category_id = c.id
+		WHERE k.client_id IS NOT NULL`)
+	if err != nil {
+		log.Printf("⚠️ Reindexação MeiliSearch falhou: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	docs := []interface{}{}
+	for rows.Next() {
+		var item KnowledgeItem
+		if err := rows.Scan(&item.ID, &item.Question, &item.Answer, &item.CategoryName, &item.ClientID); err != nil {
+			continue
+		}
+		docs = append(docs, map[string]interface{}{
+			"id":        item.ID,
+			"question":  item.Question,
+			"answer":    item.Answer,
+			"category":  item.CategoryName,
+			"client_id": item.ClientID,
+		})
+	}
+	if len(docs) == 0 {
+		return
+	}
+
+	body, _ := json.Marshal(docs)
+	req, _ := http.NewRequest("POST", fmt.Sprintf("%s/indexes/%s/documents", meiliHost, meiliIndex), bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+meiliMasterKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("⚠️ Reindexação MeiliSearch falhou: %v", err)
+		return
+	}
+	resp.Body.Close()
+	log.Printf("✅ MeiliSearch reindexado (%d documentos).", len(docs))
+}
+
+// deleteClientFromMeili remove todos os documentos de um cliente (usado ao excluir o tenant).
+func deleteClientFromMeili(clientID int) {
+	url := fmt.Sprintf("%s/indexes/%s/documents/delete", meiliHost, meiliIndex)
+	body, _ := json.Marshal(map[string]interface{}{"filter": fmt.Sprintf("client_id = %d", clientID)})
+	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+meiliMasterKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	client.Do(req)
+}
+
 func deleteFromMeili(id int) {
 	url := fmt.Sprintf("%s/indexes/%s/documents/%d", meiliHost, meiliIndex, id)
 	req, _ := http.NewRequest("DELETE", url, nil)
@@ -187,7 +254,7 @@ func handleCategories(w http.ResponseWriter, r *http.Request) {
 }
 
 func listCategories(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.Query(context.Background(), "SELECT id, name, created_at FROM knowledge_categories ORDER BY name ASC")
+	rows, err := db.Query(context.Background(), "SELECT id, name, created_at FROM knowledge_categories WHERE client_id = $1 ORDER BY name ASC", currentTenant(r).ID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -217,11 +284,12 @@ func createCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var c KnowledgeCategory
-	err := db.QueryRow(context.Background(), "INSERT INTO knowledge_categories (name) VALUES ($1) RETURNING id, name, created_at", req.Name).Scan(&c.ID, &c.Name, &c.CreatedAt)
+	err := db.QueryRow(context.Background(), "INSERT INTO knowledge_categories (name, client_id) VALUES ($1, $2) RETURNING id, name, created_at", req.Name, currentTenant(r).ID).Scan(&c.ID, &c.Name, &c.CreatedAt)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	notifyConfigChange(currentTenant(r).Name)
 	json.NewEncoder(w).Encode(c)
 }
 
@@ -274,7 +342,8 @@ func listKnowledge(w http.ResponseWriter, r *http.Request) {
         SELECT k.id, k.question, k.answer, k.category_id, c.name, k.created_at 
         FROM knowledge_base k 
         LEFT JOIN knowledge_categories c ON k.category_id = c.id 
-        ORDER BY k.created_at DESC`)
+        WHERE k.client_id = $1
+        ORDER BY k.created_at DESC`, currentTenant(r).ID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -306,11 +375,22 @@ func createKnowledge(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tenant := currentTenant(r)
+
+	// Fetch category name for Meili sync (a categoria precisa ser do mesmo cliente)
+	categoryName := "Sem Categoria"
+	if req.CategoryID != nil {
+		if err := db.QueryRow(context.Background(), "SELECT name FROM knowledge_categories WHERE id=$1 AND client_id=$2", *req.CategoryID, tenant.ID).Scan(&categoryName); err != nil {
+			http.Error(w, "Invalid category", http.StatusBadRequest)
+			return
+		}
+	}
+
 	var id int
     var createdAt time.Time
 	err := db.QueryRow(context.Background(), 
-		"INSERT INTO knowledge_base (question, answer, category_id) VALUES ($1, $2, $3) RETURNING id, created_at", 
-		req.Question, req.Answer, req.CategoryID).Scan(&id, &createdAt)
+		"INSERT INTO knowledge_base (question, answer, category_id, client_id) VALUES ($1, $2, $3, $4) RETURNING id, created_at", 
+		req.Question, req.Answer, req.CategoryID, tenant.ID).Scan(&id, &createdAt)
 	
 	if err != nil {
 		log.Printf("Erro salvando conhecimento: %v", err)
@@ -318,17 +398,12 @@ func createKnowledge(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Fetch category name for Meili sync
-	categoryName := "Sem Categoria"
-	if req.CategoryID != nil {
-		db.QueryRow(context.Background(), "SELECT name FROM knowledge_categories WHERE id=$1", *req.CategoryID).Scan(&categoryName)
-	}
-
 	item := KnowledgeItem{
 		ID:           id,
 		Question:     req.Question,
 		Answer:       req.Answer,
 		CategoryName: categoryName,
+		ClientID:     tenant.ID,
 		CreatedAt:    createdAt,
 	}
 
@@ -349,18 +424,22 @@ func updateKnowledge(w http.ResponseWriter, r *http.Request, id int) {
 		return
 	}
 
-    _, err := db.Exec(context.Background(), 
-        "UPDATE knowledge_base SET question=$1, answer=$2 WHERE id=$3", 
-        req.Question, req.Answer, id)
+    res, err := db.Exec(context.Background(), 
+        "UPDATE knowledge_base SET question=$1, answer=$2 WHERE id=$3 AND client_id=$4", 
+        req.Question, req.Answer, id, currentTenant(r).ID)
+    if err == nil && res.RowsAffected() == 0 {
+        http.Error(w, "Not found", http.StatusNotFound)
+        return
+    }
     
     
     // Fetch updated item for Meili sync (including category name)
     var item KnowledgeItem
     err = db.QueryRow(context.Background(), `
-        SELECT k.id, k.question, k.answer, c.name 
+        SELECT k.id, k.question, k.answer, COALESCE(c.name, 'Sem Categoria'), k.client_id 
         FROM knowledge_base k 
         LEFT JOIN knowledge_categories c ON k.category_id = c.id 
-        WHERE k.id = $1`, id).Scan(&item.ID, &item.Question, &item.Answer, &item.CategoryName)
+        WHERE k.id = $1`, id).Scan(&item.ID, &item.Question, &item.Answer, &item.CategoryName, &item.ClientID)
 
     if err == nil {
         go syncToMeili(item) // Updates existing doc
@@ -371,11 +450,15 @@ func updateKnowledge(w http.ResponseWriter, r *http.Request, id int) {
 
 
 func deleteKnowledge(w http.ResponseWriter, r *http.Request, id int) {
-	_, err := db.Exec(context.Background(), "DELETE FROM knowledge_base WHERE id=$1", id)
+	res, err := db.Exec(context.Background(), "DELETE FROM knowledge_base WHERE id=$1 AND client_id=$2", id, currentTenant(r).ID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if res.RowsAffected() == 0 {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
 
 	go deleteFromMeili(id)
 
@@ -384,21 +467,29 @@ func deleteKnowledge(w http.ResponseWriter, r *http.Request, id int) {
 
 // handleSearch performs a semantic search on MeiliSearch and returns results
 func handleSearch(w http.ResponseWriter, r *http.Request) {
-    w.Header().Set("Access-Control-Allow-Origin", "*")
-    w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-    w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-    if r.Method == "OPTIONS" {
-        w.WriteHeader(http.StatusOK)
-        return
-    }
-
 	query := r.URL.Query().Get("q")
 	if query == "" {
 		http.Error(w, "Query parameter 'q' is required", http.StatusBadRequest)
 		return
 	}
 
+	// Tenant obrigatório: a busca nunca cruza a base de outro cliente
+	clientName := r.URL.Query().Get("client")
+	var clientID int
+	if err := db.QueryRow(context.Background(), "SELECT id FROM aiVoice_clients WHERE name = $1", clientName).Scan(&clientID); err != nil {
+		http.Error(w, "Query parameter 'client' is required and must be a known client", http.StatusBadRequest)
+		return
+	}
+	known, err := fetchAllCategories(clientName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	isKnown := make(map[string]bool, len(known))
+	for _, c := range known {
+		isKnown[c] = true
+	}
+
 	searchURL := fmt.Sprintf("%s/indexes/%s/search", meiliHost, meiliIndex)
 	
 	
@@ -412,11 +503,33 @@ func handleSearch(w http.ResponseWriter, r *http.Request) {
         },
 	}
 
-    // Add Scope Filter
-    category := r.URL.Query().Get("category")
-    if category != "" && category != "all" {
-        searchParams["filter"] = fmt.Sprintf("category = '%s'", category)
-    }
+	// Filtro em array (E entre os itens, OU dentro dos subarrays): cada condição fica isolada e as
+	// categorias só entram se forem do cliente, já escapadas
+	filters := []interface{}{fmt.Sprintf("client_id = %d", clientID)}
+	category := r.URL.Query().Get("category")
+	if category != "" && category != "all" {
+		if !isKnown[category] {
+			http.Error(w, "Unknown category: "+category, http.StatusBadRequest)
+			return
+		}
+		filters = append(filters, "category = "+meiliQuote(category))
+	}
+	// Restrição das categorias do agente (multi-agente); categorias removidas do cliente são ignoradas
+	if agentCats := r.URL.Query()["categories"]; len(agentCats) > 0 {
+		var anyOf []string
+		for _, c := range agentCats {
+			if isKnown[c] {
+				anyOf = append(anyOf, "category = "+meiliQuote(c))
+			}
+		}
+		if len(anyOf) == 0 {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"hits": []interface{}{}})
+			return
+		}
+		filters = append(filters, anyOf)
+	}
+	searchParams["filter"] = filters
 	
 	body, _ := json.Marshal(searchParams)
 	req, _ := http.NewRequest("POST", searchURL, bytes.NewBuffer(body))
@@ -440,3 +553,8 @@ func handleSearch(w http.ResponseWriter, r *http.Request) {
     w.Header().Set("Content-Type", "application/json")
 	io.Copy(w, resp.Body)
 }
+
+// meiliQuote cita um valor para o filtro do MeiliSearch, escapando aspas e barras invertidas.
+func meiliQuote(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// -- Structs --
+
+// LeadField descreve um campo do formulário de captura de leads de um cliente.
+// Type: string, number, boolean, email ou phone.
+type LeadField struct {
+	Name        string `json:"name"`
+	Label       string `json:"label"`
+	Type        string `json:"type"`
+	Required    bool   `json:"required"`
+	Pattern     string `json:"pattern,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type Lead struct {
+	ID         int             `json:"id"`
+	CallID     string          `json:"callId"`
+	ClientName string          `json:"clientName"`
+	Data       json.RawMessage `json:"data"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+type LeadSubmission struct {
+	CallID     string                 `json:"callId"`
+	ClientName string                 `json:"clientName"`
+	Data       map[string]interface{} `json:"data"`
+}
+
+var leadFieldName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+var defaultLeadPatterns = map[string]string{
+	"email": `^[^@\s]+@[^@\s]+\.[^@\s]+$`,
+	"phone": `^\+?[0-9 ()-]{8,20}$`,
+}
+
+// -- Schema --
+
+func fetchLeadSchema(ctx context.Context, clientName string) ([]LeadField, error) {
+	var raw []byte
+	err := db.QueryRow(ctx, `
+		SELECT COALESCE(c.lead_schema, '[]'::jsonb)
+		FROM aiVoice_config c
+		JOIN aiVoice_clients cl ON c.client_id = cl.id
+		WHERE cl.name = $1
+		ORDER BY c.is_default DESC
+		LIMIT 1`, clientName).Scan(&raw)
+	if err != nil {
+		return nil, err
+	}
+	var fields []LeadField
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func validateLeadSchema(fields []LeadField) error {
+	seen := map[string]bool{}
+	for _, f := range fields {
+		if !leadFieldName.MatchString(f.Name) {
+			return fmt.Errorf("invalid field name %q (use snake_case)", f.Name)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("duplicated field %q", f.Name)
+		}
+		seen[f.Name] = true
+		switch f.Type {
+		case "string", "number", "boolean", "email", "phone":
+		default:
+			return fmt.Errorf("invalid type %q for field %q", f.Type, f.Name)
+		}
+		if f.Pattern != "" {
+			if _, err := regexp.Compile(f.Pattern); err != nil {
+				return fmt.Errorf("invalid pattern for field %q: %v", f.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateLead aplica o schema aos dados enviados pelo agente e devolve
+// os erros por campo (em português, pois voltam para o modelo).
+func validateLead(fields []LeadField, data map[string]interface{}) (map[string]interface{}, map[string]string) {
+	clean := map[string]interface{}{}
+	errs := map[string]string{}
+
+	for _, f := range fields {
+		v, ok := data[f.Name]
+		if s, isStr := v.(string); isStr {
+			v = strings.TrimSpace(s)
+			ok = ok && v != ""
+		}
+		if !ok || v == nil {
+			if f.Required {
+				errs[f.Name] = "campo obrigatório não informado"
+			}
+			continue
+		}
+
+		switch f.Type {
+		case "number":
+			switch n := v.(type) {
+			case float64:
+				clean[f.Name] = n
+			case string:
+				parsed, err := strconv.ParseFloat(strings.ReplaceAll(n, ",", "."), 64)
+				if err != nil {
+					errs[f.Name] = "deve ser um número"
+					continue
+				}
+				clean[f.Name] = parsed
+			default:
+				errs[f.Name] = "deve ser um número"
+			}
+			continue
+		case "boolean":
+			b, isBool := v.(bool)
+			if !isBool {
+				errs[f.Name] = "deve ser verdadeiro ou falso"
+				continue
+			}
+			clean[f.Name] = b
+			continue
+		}
+
+		s, isStr := v.(string)
+		if !isStr {
+			s = fmt.Sprint(v)
+		}
+		pattern := f.Pattern
+		if pattern == "" {
+			pattern = defaultLeadPatterns[f.Type]
+		}
+		if pattern != "" {
+			re, err := regexp.Compile(pattern)
+			if err == nil && !re.MatchString(s) {
+				errs[f.Name] = "formato inválido"
+				continue
+			}
+		}
+		clean[f.Name] = s
+	}
+	return clean, errs
+}
+
+// -- Handlers --
+
+// handleLeadSchema lê (GET) ou grava (PUT) o schema de leads do agente (?agent=, vazio = padrão).
+// A gravação passa pelo versionamento da configuração e avisa os orquestradores, como handleConfig.
+func handleLeadSchema(w http.ResponseWriter, r *http.Request) {
+	tenant := currentTenant(r)
+	agentName := r.URL.Query().Get("agent")
+
+	if r.Method == "GET" {
+		cfg, err := fetchConfig(tenant.Name, agentName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fields := []LeadField{}
+		if cfg != nil && cfg.LeadSchema != nil {
+			fields = cfg.LeadSchema
+		}
+		json.NewEncoder(w).Encode(fields)
+	} else if r.Method == "PUT" {
+		var fields []LeadField
+		if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateLeadSchema(fields); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if fields == nil {
+			fields = []LeadField{}
+		}
+
+		cfg, err := fetchConfig(tenant.Name, agentName)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// fetchConfig cai no agente padrão quando ?agent= não existe
+		if cfg == nil || (agentName != "" && cfg.AgentName != agentName) {
+			http.Error(w, "Agent not found", http.StatusNotFound)
+			return
+		}
+		cfg.LeadSchema = fields
+		version, err := saveConfigVersion(context.Background(), tenant, cfg.AgentName, *cfg, configAuthor(r), 0)
+		if errors.Is(err, errConfigNotFound) {
+			http.Error(w, "Agent not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		notifyConfigChange(tenant.Name)
+		json.NewEncoder(w).Encode(map[string]int{"version": version})
+	} else {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleCreateLead é chamado pelo orquestrador (tool registrar_lead).
+// Responde 422 com os erros por campo para que o modelo pergunte novamente.
+func handleCreateLead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req LeadSubmission
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.CallID == "" {
+		http.Error(w, "callId is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	var clientID int
+	if err := db.QueryRow(ctx, "SELECT id FROM aiVoice_clients WHERE name = $1", req.ClientName).Scan(&clientID); err != nil {
+		http.Error(w, "Unknown client", http.StatusNotFound)
+		return
+	}
+	fields, err := fetchLeadSchema(ctx, req.ClientName)
+	if err != nil {
+		log.Printf("[LEAD ERROR] Schema indisponível para %s: %v", req.ClientName, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	clean, errs := validateLead(fields, req.Data)
+	if len(errs) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{"errors": errs})
+		return
+	}
+
+	raw, _ := json.Marshal(clean)
+	var id int
+	err = db.QueryRow(ctx, `
+		INSERT INTO aiVoice_leads (call_id, client_id, data)
+		VALUES ($1, $2, $3)
+		RETURNING id`, req.CallID, clientID, raw).Scan(&id)
+	if err != nil {
+		log.Printf("[LEAD ERROR] Erro salvando lead da Call %s: %v", req.CallID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[LEAD] Lead #%d registrado para Call %s", id, req.CallID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": id})
+}
+
+func queryLeads(ctx context.Context, clientID int, from, to string) ([]Lead, error) {
+	rows, err := db.Query(ctx, `
+		SELECT l.id, l.call_id, COALESCE(cl.name, ''), l.data, l.created_at
+		FROM aiVoice_leads l
+		LEFT JOIN aiVoice_clients cl ON l.client_id = cl.id
+		WHERE l.client_id = $3
+		  AND ($1 = '' OR l.created_at >= NULLIF($1, '')::date)
+		  AND ($2 = '' OR l.created_at < NULLIF($2, '')::date + INTERVAL '1 day')
+		ORDER BY l.created_at DESC
+		LIMIT 5000`, from, to, clientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var leads []Lead
+	for rows.Next() {
+		var l Lead
+		if err := rows.Scan(&l.ID, &l.CallID, &l.ClientName, &l.Data, &l.CreatedAt); err != nil {
+			continue
+		}
+		leads = append(leads, l)
+	}
+	return leads, nil
+}
+
+func handleLeads(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	leads, err := queryLeads(context.Background(), currentTenant(r).ID, r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if leads == nil {
+		leads = []Lead{}
+	}
+	json.NewEncoder(w).Encode(leads)
+}
+
+// handleLeadsExport exporta os leads em CSV, com uma coluna por campo do schema.
+func handleLeadsExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := context.Background()
+	tenant := currentTenant(r)
+	fields, err := fetchLeadSchema(ctx, tenant.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	leads, err := queryLeads(ctx, tenant.ID, r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=leads-%s.csv", time.Now().Format("2006-01-02")))
+
+	cw := csv.NewWriter(w)
+	header := []string{"id", "call_id", "created_at"}
+	for _, f := range fields {
+		header = append(header, csvSafe(f.Name))
+	}
+	cw.Write(header)
+
+	for _, l := range leads {
+		var data map[string]interface{}
+		json.Unmarshal(l.Data, &data)
+		row := []string{strconv.Itoa(l.ID), csvSafe(l.CallID), l.CreatedAt.Format(time.RFC3339)}
+		for _, f := range fields {
+			if v, ok := data[f.Name]; ok {
+				row = append(row, csvSafe(fmt.Sprint(v)))
+			} else {
+				row = append(row, "")
+			}
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+}
+
+// csvSafe neutraliza fórmulas: planilhas executam células que começam com =, +, -, @ (ou tab/CR),
+// e os valores vêm do que o visitante disse ao agente. O apóstrofo faz a célula ser lida como texto.
+func csvSafe(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
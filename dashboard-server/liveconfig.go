@@ -0,0 +1,31 @@
+package main
+
+import "errors"
+
+// Opções nativas do Gemini Live (generationConfig, realtimeInputConfig e contextWindowCompression)
+// aplicadas pelo orquestrador no setup. Zero = padrão do modelo.
+
+var vadSensitivities = map[string]bool{"": true, "high": true, "low": true}
+
+// validateLiveConfig confere os limites aceitos pela API antes de salvar.
+func validateLiveConfig(cfg *AIConfig) error {
+	switch {
+	case cfg.ThinkingBudget < -1 || cfg.ThinkingBudget > 24576:
+		return errors.New("thinkingBudget must be -1 (dynamic), 0 (off) or up to 24576")
+	case cfg.TopP < 0 || cfg.TopP > 1:
+		return errors.New("topP must be between 0 and 1")
+	case cfg.TopK < 0 || cfg.TopK > 100:
+		return errors.New("topK must be between 0 and 100")
+	case cfg.MaxOutputTokens < 0 || cfg.MaxOutputTokens > 65536:
+		return errors.New("maxOutputTokens must be between 0 and 65536")
+	case !vadSensitivities[cfg.VADStartSensitivity] || !vadSensitivities[cfg.VADEndSensitivity]:
+		return errors.New("VAD sensitivity must be empty, 'high' or 'low'")
+	case cfg.VADPrefixPaddingMs < 0 || cfg.VADPrefixPaddingMs > 5000 || cfg.VADSilenceDurationMs < 0 || cfg.VADSilenceDurationMs > 10000:
+		return errors.New("VAD prefix padding (0-5000 ms) or silence duration (0-10000 ms) out of range")
+	case cfg.CompressionTriggerTokens < 0 || cfg.CompressionTargetTokens < 0:
+		return errors.New("compression token limits must be positive")
+	case cfg.ContextCompression && cfg.CompressionTriggerTokens > 0 && cfg.CompressionTargetTokens >= cfg.CompressionTriggerTokens:
+		return errors.New("compressionTargetTokens must be lower than compressionTriggerTokens")
+	}
+	return nil
+}
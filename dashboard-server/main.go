@@ -2,34 +2,63 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"regexp"
+	"strconv"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+
+	"aivoice-shared/secrets"
 )
 
 type AIConfig struct {
-	VoiceName                 string  `json:"voiceName"`
-	LanguageCode              string  `json:"languageCode"`
-	Temperature               float64 `json:"temperature"`
-	ThinkingBudget            int     `json:"thinkingBudget"`
-	EnableAffectiveDialog     bool    `json:"enableAffectiveDialog"`
-	ProactiveAudio            bool    `json:"proactiveAudio"`
-	SystemPrompt              string  `json:"systemPrompt,omitempty"`
-	DocstringToolKnowledge    string  `json:"docstringToolKnowledge,omitempty"`
-	DurationLimit             int     `json:"durationLimit"`
-	TerminationAlertTime      int     `json:"terminationAlertTime"`
-	DocstringToolTerminate    string  `json:"docstringToolTerminate,omitempty"`
-	DocstringToolSendLink     string  `json:"docstringToolSendLink,omitempty"`
-	ProactiveAlertInstruction string  `json:"proactiveAlertInstruction,omitempty"`
+	VoiceName                 string              `json:"voiceName"`
+	LanguageCode              string              `json:"languageCode"`
+	Temperature               float64             `json:"temperature"`
+	ThinkingBudget            int                 `json:"thinkingBudget"`
+	EnableAffectiveDialog     bool                `json:"enableAffectiveDialog"`
+	ProactiveAudio            bool                `json:"proactiveAudio"`
+	SystemPrompt              string              `json:"systemPrompt,omitempty"`
+	DocstringToolKnowledge    string              `json:"docstringToolKnowledge,omitempty"`
+	DurationLimit             int                 `json:"durationLimit"`
+	TerminationAlertTime      int                 `json:"terminationAlertTime"`
+	DocstringToolTerminate    string              `json:"docstringToolTerminate,omitempty"`
+	DocstringToolSendLink     string              `json:"docstringToolSendLink,omitempty"`
+	ProactiveAlertInstruction string              `json:"proactiveAlertInstruction,omitempty"`
+	DocstringToolEscalate     string              `json:"docstringToolEscalate,omitempty"`
+	EscalationWebhookURL      string              `json:"escalationWebhookUrl"`
+	DocstringToolLead         string              `json:"docstringToolLead,omitempty"`
+	LeadSchema                []LeadField         `json:"leadSchema"` // nil num PUT mantém o schema atual
+	SchedulingEnabled         bool                `json:"schedulingEnabled"`
+	AgentName                 string              `json:"agentName"`
+	AgentDescription          string              `json:"agentDescription"`
+	IsDefault                 bool                `json:"isDefault"`
+	EnabledTools              []string            `json:"enabledTools"`
+	KBCategories              []string            `json:"kbCategories"`
+	Version                   int                 `json:"version"`
+	Timezone                  string              `json:"timezone"`
+	BusinessHours             []BusinessHoursRule `json:"businessHours"`
+	TopP                      float64             `json:"topP"`
+	TopK                      int                 `json:"topK"`
+	MaxOutputTokens           int                 `json:"maxOutputTokens"`
+	VADStartSensitivity       string              `json:"vadStartSensitivity"` // "", "high", "low"
+	VADEndSensitivity         string              `json:"vadEndSensitivity"`   // "", "high", "low"
+	VADPrefixPaddingMs        int                 `json:"vadPrefixPaddingMs"`
+	VADSilenceDurationMs      int                 `json:"vadSilenceDurationMs"`
+	ContextCompression        bool                `json:"contextCompression"`
+	CompressionTriggerTokens  int                 `json:"compressionTriggerTokens"`
+	CompressionTargetTokens   int                 `json:"compressionTargetTokens"`
 }
 
 // Structs para Dashboard
@@ -66,6 +95,16 @@ type CallSyncRequest struct {
 	InputTokens    int             `json:"inputTokens"`
 	OutputTokens   int             `json:"outputTokens"`
 	Status         string          `json:"status"`
+	Escalated      bool            `json:"escalated"`
+	Agents         []string        `json:"agents"`
+	ConfigVersion  int             `json:"configVersion"`
+	ExperimentID   int             `json:"experimentId"`
+	Variant        string          `json:"variant"`
+	Context        json.RawMessage `json:"context"`
+	Model          string          `json:"model"`
+	Modality       string          `json:"modality"` // "audio" (voz) ou "text" (chat)
+	Video          bool            `json:"video"`    // o usuário compartilhou tela ou câmera
+	KeyUsage       []KeyUsage      `json:"keyUsage"`
 }
 
 type CallRecord struct {
@@ -77,6 +116,14 @@ type CallRecord struct {
 	InputTokens     int             `json:"inputTokens"`
 	OutputTokens    int             `json:"outputTokens"`
 	Status          string          `json:"status"`
+	Escalated       bool            `json:"escalated"`
+	Agents          []string        `json:"agents"`
+	ConfigVersion   *int            `json:"configVersion"`
+	Variant         string          `json:"variant,omitempty"`
+	Context         json.RawMessage `json:"context"`
+	Model           string          `json:"model,omitempty"`
+	Modality        string          `json:"modality"`
+	Video           bool            `json:"video,omitempty"`
 	CreatedAt       time.Time       `json:"createdAt"`
 }
 
@@ -97,6 +144,14 @@ func main() {
 	if db != nil {
 		defer db.Close()
 	}
+	if keys, err := secrets.Default(); err != nil {
+		log.Printf("⚠️ Segredos no banco indisponíveis: %v", err)
+	} else {
+		log.Printf("🔐 Chave mestra ativa para segredos: %s", keys.ActiveID())
+		if db != nil {
+			encryptLegacyWebhookURLs(context.Background())
+		}
+	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -105,16 +160,56 @@ func main() {
 
 	// Rotas Públicas
 	http.HandleFunc("/api/auth/login", handleLogin)
-	http.HandleFunc("/api/knowledge/search", handleSearch)
+	http.HandleFunc("/api/knowledge/search", internalAuth(handleSearch)) // Internal (called by orchestrator)
 
 	// Rotas Protegidas (Dashboard)
 	http.HandleFunc("/api/dashboard/users", authMiddleware(handleUsers))
 	http.HandleFunc("/api/dashboard/config", authMiddleware(handleConfig))
+	http.HandleFunc("/api/dashboard/config/preview", authMiddleware(handlePromptPreview))
+	http.HandleFunc("/api/dashboard/config/versions", authMiddleware(handleConfigVersions))
+	http.HandleFunc("/api/dashboard/config/versions/diff", authMiddleware(handleConfigVersionDiff))
+	http.HandleFunc("/api/dashboard/config/versions/restore", authMiddleware(handleConfigVersionRestore))
+	http.HandleFunc("/api/dashboard/experiments", authMiddleware(handleExperiments))
+	http.HandleFunc("/api/dashboard/experiments/stop", authMiddleware(handleExperimentStop))
+	http.HandleFunc("/api/dashboard/experiments/results", authMiddleware(handleExperimentResults))
+	http.HandleFunc("/api/dashboard/agents", authMiddleware(handleAgents))
+	http.HandleFunc("/api/dashboard/clients", authMiddleware(handleClients))
+	http.HandleFunc("/api/dashboard/keys", authMiddleware(handleClientKeys))
+	http.HandleFunc("/api/admin/clients", adminAuth(handleAdminClients))
+	http.HandleFunc("/api/admin/clients/suspend", adminAuth(handleAdminClientStatus("suspended")))
+	http.HandleFunc("/api/admin/clients/reactivate", adminAuth(handleAdminClientStatus("active")))
+	http.HandleFunc("/api/admin/templates", adminAuth(handleAdminTemplates))
+	http.HandleFunc("/api/admin/key-usage", adminAuth(handleAdminKeyUsage))
+	http.HandleFunc("/api/admin/secrets/rotate", adminAuth(handleAdminRotateSecrets))
 	http.HandleFunc("/api/dashboard/calls", authMiddleware(handleCalls))
-	http.HandleFunc("/api/calls/sync", handleSync) // Public (called by agent client)
+	http.HandleFunc("/api/calls/sync", internalAuth(handleSync)) // Internal (called by orchestrator)
 	http.HandleFunc("/api/dashboard/knowledge", authMiddleware(handleKnowledge))
 	http.HandleFunc("/api/dashboard/knowledge/item", authMiddleware(handleKnowledgeItem))
 	http.HandleFunc("/api/dashboard/categories", authMiddleware(handleCategories))
+	http.HandleFunc("/api/escalations", internalAuth(handleCreateEscalation)) // Internal (called by orchestrator)
+	http.HandleFunc("/api/dashboard/escalations", authMiddleware(handleEscalations))
+	http.HandleFunc("/api/dashboard/escalations/item", authMiddleware(handleEscalationItem))
+	http.HandleFunc("/api/dashboard/webhook-secret", authMiddleware(handleWebhookSecret))
+	http.HandleFunc("/api/leads", internalAuth(handleCreateLead)) // Internal (called by orchestrator)
+	http.HandleFunc("/api/dashboard/leads", authMiddleware(handleLeads))
+	http.HandleFunc("/api/dashboard/leads/export", authMiddleware(handleLeadsExport))
+	http.HandleFunc("/api/dashboard/leads/schema", authMiddleware(handleLeadSchema))
+	http.HandleFunc("/api/schedule/availability", internalAuth(handleAvailability))                   // Internal (called by orchestrator)
+	http.HandleFunc("/api/schedule/bookings", internalAuth(handleCreateBooking))                      // Internal (called by orchestrator)
+	http.HandleFunc("/api/schedule/bookings/confirm", internalAuth(handleBookingAction("confirmed"))) // Internal (called by orchestrator)
+	http.HandleFunc("/api/schedule/bookings/cancel", internalAuth(handleBookingAction("cancelled")))  // Internal (called by orchestrator)
+	http.HandleFunc("/api/dashboard/schedule/resources", authMiddleware(handleScheduleResources))
+	http.HandleFunc("/api/dashboard/schedule/hours", authMiddleware(handleScheduleHours))
+	http.HandleFunc("/api/dashboard/schedule/blackouts", authMiddleware(handleScheduleBlackouts))
+	http.HandleFunc("/api/dashboard/schedule/bookings", authMiddleware(handleScheduleBookings))
+	http.HandleFunc("/api/dashboard/notifications/templates", authMiddleware(handleNotificationTemplates))
+	http.HandleFunc("/api/dashboard/notifications", authMiddleware(handleNotificationLog))
+	http.HandleFunc("/api/dashboard/context-fields", authMiddleware(handleContextFields))
+	http.HandleFunc("/api/dashboard/caller-memory", authMiddleware(handleCallerMemory))
+	http.HandleFunc("/api/dashboard/caller-memory/settings", authMiddleware(handleCallerMemorySettings))
+	http.HandleFunc("/api/dashboard/client-settings", authMiddleware(handleClientSettings))
+	http.HandleFunc("/api/dashboard/gemini-key", authMiddleware(handleGeminiKey))
+	http.HandleFunc("/api/dashboard/whatsapp", authMiddleware(handleWhatsAppChannels))
 
     // Inicializa MeiliSearch em background
     go initMeiliSearch()
@@ -139,7 +234,7 @@ func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Client")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -177,45 +272,41 @@ func initDB() {
 }
 
 func ensureClientOnboarding() {
-	clientName := os.Getenv("INSTANCE_CLIENT_NAME")
-	if clientName == "" {
-		clientName = "aiVoice"
-	}
+	clientName := defaultClientName()
 
 	ctx := context.Background()
 	
-	// 1. Garante que o cliente existe
+	// 1. Garante que o cliente padrão existe (provisionado a partir do template "default")
 	var clientID int
-	err := db.QueryRow(ctx, "INSERT INTO aiVoice_clients (name, status) VALUES ($1, 'active') ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id", clientName).Scan(&clientID)
+	err := db.QueryRow(ctx, "SELECT id FROM aiVoice_clients WHERE name = $1", clientName).Scan(&clientID)
 	if err != nil {
-		log.Printf("⚠️ Auto-Onboarding: Falha ao garantir cliente %s: %v", clientName, err)
-		return
+		t, err := provisionClient(ctx, clientName, "default")
+		if err != nil {
+			log.Printf("⚠️ Auto-Onboarding: Falha ao provisionar cliente %s: %v", clientName, err)
+			return
+		}
+		clientID = t.ID
+		log.Printf("✅ Auto-Onboarding concluído para o cliente: %s", clientName)
 	}
 
-	// 2. Garante que a configuração existe
-	queryConfig := `
-		INSERT INTO aiVoice_config (
-			client_id, voice_name, language_code, temperature, 
-			enable_affective_dialog, proactive_audio, system_prompt, 
-			docstring_tool_knowledge, docstring_tool_terminate, docstring_tool_send_link
-		) VALUES ($1, 'Aoede', 'pt-BR', 0.7, true, true, $2, $3, $4, $5)
-		ON CONFLICT (client_id) DO NOTHING
-	`
-	systemPrompt := fmt.Sprintf("Você é o %s, um assistente de voz avançado criado pelo estúdio TkzM.", clientName)
-	knowledgeDoc := "Invoque esta ferramenta sempre que o usuário tiver dúvidas que não estejam no seu System Prompt."
-	terminateDoc := "Use esta ferramenta para finalizar o atendimento educadamente."
-	sendLinkDoc := "Use esta ferramenta para enviar um link ao usuário. Você deve obrigatoriamente fornecer a 'url' (completa com http/https) e o 'alias' (texto curto que descreve o link)."
-
-	_, err = db.Exec(ctx, queryConfig, clientID, systemPrompt, knowledgeDoc, terminateDoc, sendLinkDoc)
-	if err != nil {
-		log.Printf("⚠️ Auto-Onboarding: Falha ao criar configuração para %s: %v", clientName, err)
-	} else {
-		log.Printf("✅ Auto-Onboarding concluído para o cliente: %s", clientName)
+	// 2. Single-tenant: usuários sem vínculo e conhecimento sem dono passam a pertencer ao cliente padrão
+	db.Exec(ctx, `
+		INSERT INTO dashboard_user_clients (user_id, client_id)
+		SELECT u.id, $1 FROM dashboard_users u
+		WHERE NOT EXISTS (SELECT 1 FROM dashboard_user_clients uc WHERE uc.user_id = u.id)
+		ON CONFLICT DO NOTHING`, clientID)
+	db.Exec(ctx, "UPDATE knowledge_categories SET client_id = $1 WHERE client_id IS NULL", clientID)
+	if res, err := db.Exec(ctx, "UPDATE knowledge_base SET client_id = $1 WHERE client_id IS NULL", clientID); err == nil && res.RowsAffected() > 0 {
+		go reindexMeili() // Documentos antigos ganham o client_id no índice
 	}
 }
 
 // --- Middlewares ---
 
+type contextKey string
+
+const claimsKey contextKey = "claims"
+
 func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
@@ -231,7 +322,8 @@ func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			tokenString = authHeader
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		claims := jwt.MapClaims{}
+		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
@@ -243,10 +335,47 @@ func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		// Resolve o cliente (tenant) da requisição entre os vinculados ao usuário
+		userID, _ := claims["user_id"].(float64)
+		tenant, err := resolveTenant(r.Context(), int(userID), r.Header.Get("X-Client"))
+		if err != nil {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), claimsKey, claims)
+		ctx = context.WithValue(ctx, tenantKey, tenant)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// internalAuth protege as rotas chamadas só pelo orquestrador (rede interna) com o segredo compartilhado
+// INTERNAL_API_SECRET no cabeçalho X-Internal-Secret; sem o segredo configurado as rotas ficam desligadas.
+func internalAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expected := os.Getenv("INTERNAL_API_SECRET")
+		if expected == "" {
+			http.Error(w, "Internal API disabled", http.StatusServiceUnavailable)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Internal-Secret")), []byte(expected)) != 1 {
+			http.Error(w, "Invalid internal secret", http.StatusUnauthorized)
+			return
+		}
 		next(w, r)
 	}
 }
 
+// currentUserEmail retorna o email do usuário autenticado (claims do JWT) ou "" fora do authMiddleware.
+func currentUserEmail(r *http.Request) string {
+	claims, ok := r.Context().Value(claimsKey).(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	email, _ := claims["email"].(string)
+	return email
+}
+
 // --- Handlers ---
 
 func handleLogin(w http.ResponseWriter, r *http.Request) {
@@ -295,7 +424,12 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 
 func handleUsers(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		rows, err := db.Query(context.Background(), "SELECT id, email, name, role, created_at FROM dashboard_users ORDER BY created_at DESC")
+		rows, err := db.Query(context.Background(), `
+			SELECT u.id, u.email, u.name, u.role, u.created_at
+			FROM dashboard_users u
+			JOIN dashboard_user_clients uc ON uc.user_id = u.id
+			WHERE uc.client_id = $1
+			ORDER BY u.created_at DESC`, currentTenant(r).ID)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -327,7 +461,12 @@ func handleUsers(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		_, err = db.Exec(context.Background(), "INSERT INTO dashboard_users (email, password_hash, name) VALUES ($1, $2, $3)", req.Email, string(hash), req.Name)
+		// Novo usuário nasce vinculado ao cliente atual
+		_, err = db.Exec(context.Background(), `
+			WITH u AS (
+				INSERT INTO dashboard_users (email, password_hash, name) VALUES ($1, $2, $3) RETURNING id
+			)
+			INSERT INTO dashboard_user_clients (user_id, client_id) SELECT id, $4 FROM u`, req.Email, string(hash), req.Name, currentTenant(r).ID)
 		if err != nil {
 			log.Printf("Erro criando usuário: %v", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -340,7 +479,18 @@ func handleUsers(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "ID required", http.StatusBadRequest)
 			return
 		}
-		_, err := db.Exec(context.Background(), "DELETE FROM dashboard_users WHERE id = $1", id)
+		// Remove o vínculo com o cliente atual; o usuário só é apagado se não restar nenhum vínculo
+		ctx := context.Background()
+		res, err := db.Exec(ctx, "DELETE FROM dashboard_user_clients WHERE user_id = $1 AND client_id = $2", id, currentTenant(r).ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if res.RowsAffected() == 0 {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		_, err = db.Exec(ctx, "DELETE FROM dashboard_users u WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM dashboard_user_clients uc WHERE uc.user_id = u.id)", id)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -359,6 +509,26 @@ func handleUsers(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		var bound, shared bool
+		err := db.QueryRow(context.Background(), `
+			SELECT EXISTS(SELECT 1 FROM dashboard_user_clients WHERE user_id = $1 AND client_id = $2),
+				EXISTS(SELECT 1 FROM dashboard_user_clients WHERE user_id = $1 AND client_id <> $2)`,
+			id, currentTenant(r).ID).Scan(&bound, &shared)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !bound {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		// Email, nome e senha valem para todos os clientes do usuário: se ele também acessa outro cliente,
+		// só ele mesmo pode alterá-los (senão o admin de um cliente tomaria a conta usada em outro)
+		if shared && id != strconv.Itoa(currentUserID(r)) {
+			http.Error(w, "User is linked to other clients; only the user can change these credentials", http.StatusForbidden)
+			return
+		}
+
 		if req.Password != "" {
 			hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 			if err != nil {
@@ -383,13 +553,21 @@ func handleUsers(w http.ResponseWriter, r *http.Request) {
 
 func handleCalls(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
+		// Filtros por contexto (?context.userId=123&context.custom.plano=premium) e por modalidade (?modality=text)
+		filter, err := callContextFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		rows, err := db.Query(context.Background(), `
-			SELECT c.id, c.call_id, cl.name as client_name, c.transcript, c.duration_seconds, c.input_tokens, c.output_tokens, c.status, c.created_at 
+			SELECT c.id, c.call_id, cl.name as client_name, c.transcript, c.duration_seconds, c.input_tokens, c.output_tokens, c.status, COALESCE(c.escalated, false), COALESCE(c.agents, '{}'), c.config_version, COALESCE(c.experiment_variant, ''), COALESCE(c.caller_context, '{}'::jsonb), COALESCE(c.model, ''), COALESCE(c.modality, 'audio'), COALESCE(c.video_used, false), c.created_at 
 			FROM aiVoice_calls c
 			JOIN aiVoice_clients cl ON c.client_id = cl.id
+			WHERE c.client_id = $1 AND ($2::jsonb IS NULL OR c.caller_context @> $2::jsonb)
+				AND ($3 = '' OR COALESCE(c.modality, 'audio') = $3)
 			ORDER BY c.created_at DESC
 			LIMIT 50
-		`)
+		`, currentTenant(r).ID, filter, r.URL.Query().Get("modality"))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -399,7 +577,7 @@ func handleCalls(w http.ResponseWriter, r *http.Request) {
 		var calls []CallRecord
 		for rows.Next() {
 			var c CallRecord
-			if err := rows.Scan(&c.ID, &c.CallID, &c.ClientName, &c.Transcript, &c.DurationSeconds, &c.InputTokens, &c.OutputTokens, &c.Status, &c.CreatedAt); err != nil {
+			if err := rows.Scan(&c.ID, &c.CallID, &c.ClientName, &c.Transcript, &c.DurationSeconds, &c.InputTokens, &c.OutputTokens, &c.Status, &c.Escalated, &c.Agents, &c.ConfigVersion, &c.Variant, &c.Context, &c.Model, &c.Modality, &c.Video, &c.CreatedAt); err != nil {
 				continue
 			}
 			calls = append(calls, c)
@@ -435,16 +613,32 @@ func handleSync(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var clientID int
+	if err := db.QueryRow(context.Background(), "SELECT id FROM aiVoice_clients WHERE name = $1", req.ClientName).Scan(&clientID); err != nil {
+		log.Printf("[SYNC ERROR] Cliente desconhecido para Call %s: %q", req.CallID, req.ClientName)
+		http.Error(w, "Unknown client", http.StatusNotFound)
+		return
+	}
+
 	query := `
-		INSERT INTO aiVoice_calls (call_id, client_id, transcript, duration_seconds, input_tokens, output_tokens, status)
+		INSERT INTO aiVoice_calls (call_id, client_id, transcript, duration_seconds, input_tokens, output_tokens, status, escalated, agents, config_version, experiment_id, experiment_variant, caller_context, model, modality, video_used)
 		VALUES (
 			$1, 
-			(SELECT id FROM aiVoice_clients WHERE name = $2), 
+			$2, 
 			$3, 
 			$4, 
 			$5, 
 			$6, 
-			$7
+			$7,
+			$8,
+			$9,
+			NULLIF($10, 0),
+			NULLIF($11, 0),
+			NULLIF($12, ''),
+			COALESCE($13::jsonb, '{}'::jsonb),
+			NULLIF($14, ''),
+			COALESCE(NULLIF($15, ''), 'audio'),
+			$16
 		)
 		ON CONFLICT (call_id) DO UPDATE SET
 			transcript = EXCLUDED.transcript,
@@ -452,17 +646,36 @@ func handleSync(w http.ResponseWriter, r *http.Request) {
 			input_tokens = EXCLUDED.input_tokens,
 			output_tokens = EXCLUDED.output_tokens,
 			status = EXCLUDED.status,
-			updated_at = NOW();
+			escalated = aiVoice_calls.escalated OR EXCLUDED.escalated,
+			agents = EXCLUDED.agents,
+			config_version = COALESCE(aiVoice_calls.config_version, EXCLUDED.config_version),
+			experiment_id = COALESCE(aiVoice_calls.experiment_id, EXCLUDED.experiment_id),
+			experiment_variant = COALESCE(aiVoice_calls.experiment_variant, EXCLUDED.experiment_variant),
+			caller_context = CASE WHEN $13::jsonb IS NULL THEN aiVoice_calls.caller_context ELSE EXCLUDED.caller_context END,
+			model = COALESCE(EXCLUDED.model, aiVoice_calls.model),
+			modality = CASE WHEN $15 = '' THEN aiVoice_calls.modality ELSE EXCLUDED.modality END,
+			video_used = aiVoice_calls.video_used OR EXCLUDED.video_used,
+			updated_at = NOW()
+		WHERE aiVoice_calls.client_id = EXCLUDED.client_id;
 	`
 
 	res, err := db.Exec(context.Background(), query, 
 		req.CallID, 
-		req.ClientName, 
+		clientID, 
 		req.NewTranscript, 
 		req.DurationSecond, 
 		req.InputTokens, 
 		req.OutputTokens, 
 		req.Status,
+		req.Escalated,
+		req.Agents,
+		req.ConfigVersion,
+		req.ExperimentID,
+		req.Variant,
+		nullableJSON(req.Context),
+		req.Model,
+		req.Modality,
+		req.Video,
 	)
 
 	if err != nil {
@@ -470,6 +683,16 @@ func handleSync(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	// Nenhuma linha: o call_id já existe em outro cliente e não é sobrescrito
+	if res.RowsAffected() == 0 {
+		log.Printf("[SYNC ERROR] Call %s pertence a outro cliente (recebido: %s)", req.CallID, req.ClientName)
+		http.Error(w, "Call belongs to another client", http.StatusConflict)
+		return
+	}
+
+	if err := saveKeyUsage(context.Background(), req.CallID, req.KeyUsage); err != nil {
+		log.Printf("[SYNC ERROR] Erro ao gravar consumo por chave da Call %s: %v", req.CallID, err)
+	}
 
 	affected := res.RowsAffected()
 	log.Printf("[SYNC SUCCESS] Call %s processada. Rows affected: %d", req.CallID, affected)
@@ -480,18 +703,15 @@ func handleSync(w http.ResponseWriter, r *http.Request) {
 
 func handleConfig(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		clientName := os.Getenv("INSTANCE_CLIENT_NAME")
-		if clientName == "" {
-			clientName = "aiVoice"
-		}
-		cfg, err := fetchConfig(clientName)
+		clientName := currentTenant(r).Name
+		cfg, err := fetchConfig(clientName, r.URL.Query().Get("agent"))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// Injeta Categorias dinamicamente na Docstring para visualização no Dashboard
-		cats, _ := fetchAllCategories()
+		cats, _ := fetchAllCategories(clientName)
 		catList := "all"
 		for _, c := range cats {
 			catList += ", " + c
@@ -499,6 +719,7 @@ func handleConfig(w http.ResponseWriter, r *http.Request) {
 
 		dynamicInstruction := fmt.Sprintf("\n\n---\n⚠️ INJEÇÃO DINÂMICA (Categorias Ativas): [%s]\nUse o parâmetro 'category' com uma das opções acima para filtrar a busca, ou 'all' para busca global.", catList)
 		cfg.DocstringToolKnowledge += dynamicInstruction
+		cfg.EscalationWebhookURL = maskWebhookURL(cfg.EscalationWebhookURL)
 
 		json.NewEncoder(w).Encode(cfg)
 	} else if r.Method == "PUT" {
@@ -508,66 +729,94 @@ func handleConfig(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		query := `
-			UPDATE aiVoice_config SET
-				voice_name = $1,
-				language_code = $2,
-				temperature = $3,
-				thinking_budget = $4,
-				enable_affective_dialog = $5,
-				proactive_audio = $6,
-				system_prompt = $7,
-				docstring_tool_knowledge = $8,
-				duration_limit = $9,
-				termination_alert_time = $10,
-				docstring_tool_terminate = $11,
-				proactive_alert_instruction = $12,
-				docstring_tool_send_link = $13,
-				updated_at = NOW()
-			WHERE client_id = (SELECT id FROM aiVoice_clients WHERE name = $14)
-		`
-
 		// Remove a parte injetada dinamicamente antes de salvar para não poluir o banco
 		re := regexp.MustCompile(`(?s)\n\n---\n⚠️ INJEÇÃO DINÂMICA.*`)
 		cfg.DocstringToolKnowledge = re.ReplaceAllString(cfg.DocstringToolKnowledge, "")
 
-		clientName := os.Getenv("INSTANCE_CLIENT_NAME")
-		if clientName == "" {
-			clientName = "aiVoice"
+		clientName := currentTenant(r).Name
+		if err := validatePromptConfig(&cfg, clientName); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateLiveConfig(&cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateWebhookURL(cfg.EscalationWebhookURL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if cfg.LeadSchema != nil {
+			if err := validateLeadSchema(cfg.LeadSchema); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Agente alvo: ?agent= tem precedência; vazio = agente padrão
+		agentName := r.URL.Query().Get("agent")
+		if agentName == "" {
+			agentName = cfg.AgentName
 		}
 
-		_, err := db.Exec(context.Background(), query, cfg.VoiceName, cfg.LanguageCode, cfg.Temperature, cfg.ThinkingBudget, cfg.EnableAffectiveDialog, cfg.ProactiveAudio, cfg.SystemPrompt, cfg.DocstringToolKnowledge, cfg.DurationLimit, cfg.TerminationAlertTime, cfg.DocstringToolTerminate, cfg.ProactiveAlertInstruction, cfg.DocstringToolSendLink, clientName)
+		// Cada gravação gera uma versão imutável (autor, data e diff) em aiVoice_config_versions
+		version, err := saveConfigVersion(context.Background(), currentTenant(r), agentName, cfg, configAuthor(r), 0)
+		if errors.Is(err, errConfigNotFound) {
+			http.Error(w, "Agent not found", http.StatusNotFound)
+			return
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		w.WriteHeader(http.StatusOK)
+		notifyConfigChange(clientName)
+		json.NewEncoder(w).Encode(map[string]int{"version": version})
 	}
 }
 
-func fetchConfig(clientName string) (*AIConfig, error) {
+func fetchConfig(clientName, agentName string) (*AIConfig, error) {
 	if db == nil {
 		return nil, nil
 	}
 	var cfg AIConfig
 	query := `
-		SELECT c.voice_name, c.language_code, c.temperature, c.thinking_budget, COALESCE(c.enable_affective_dialog, false), COALESCE(c.proactive_audio, false), COALESCE(c.system_prompt, ''), COALESCE(c.docstring_tool_knowledge, ''), c.duration_limit, c.termination_alert_time, COALESCE(c.docstring_tool_terminate, ''), COALESCE(c.proactive_alert_instruction, ''), COALESCE(c.docstring_tool_send_link, '')
+		SELECT ` + configColumns + `
 		FROM aiVoice_config c
 		JOIN aiVoice_clients cl ON c.client_id = cl.id
 		WHERE cl.name = $1 AND cl.status = 'active'
+		ORDER BY (c.agent_name = $2) DESC, c.is_default DESC, c.id ASC
 		LIMIT 1
 	`
-	err := db.QueryRow(context.Background(), query, clientName).Scan(
-		&cfg.VoiceName, &cfg.LanguageCode, &cfg.Temperature, &cfg.ThinkingBudget, &cfg.EnableAffectiveDialog, &cfg.ProactiveAudio, &cfg.SystemPrompt, &cfg.DocstringToolKnowledge, &cfg.DurationLimit, &cfg.TerminationAlertTime, &cfg.DocstringToolTerminate, &cfg.ProactiveAlertInstruction, &cfg.DocstringToolSendLink,
-	)
-	if err != nil {
+	if err := scanConfig(db.QueryRow(context.Background(), query, clientName, agentName), &cfg); err != nil {
 		return nil, err
 	}
 	return &cfg, nil
 }
 
-func fetchAllCategories() ([]string, error) {
-	rows, err := db.Query(context.Background(), "SELECT name FROM knowledge_categories ORDER BY name ASC")
+// configColumns é a lista de colunas lida por scanConfig (alias c = aiVoice_config).
+const configColumns = `c.voice_name, c.language_code, c.temperature, c.thinking_budget, COALESCE(c.enable_affective_dialog, false), COALESCE(c.proactive_audio, false), COALESCE(c.system_prompt, ''), COALESCE(c.docstring_tool_knowledge, ''), c.duration_limit, c.termination_alert_time, COALESCE(c.docstring_tool_terminate, ''), COALESCE(c.proactive_alert_instruction, ''), COALESCE(c.docstring_tool_send_link, ''), COALESCE(c.docstring_tool_escalate, ''), COALESCE(c.escalation_webhook_url, ''), COALESCE(c.docstring_tool_lead, ''), COALESCE(c.lead_schema, '[]'::jsonb), COALESCE(c.scheduling_enabled, false), c.agent_name, COALESCE(c.agent_description, ''), COALESCE(c.is_default, false), COALESCE(c.enabled_tools, '{}'), COALESCE(c.kb_categories, '{}'), c.version, COALESCE(c.timezone, ''), COALESCE(c.business_hours, '[]'::jsonb), COALESCE(c.top_p, 0), COALESCE(c.top_k, 0), COALESCE(c.max_output_tokens, 0), COALESCE(c.vad_start_sensitivity, ''), COALESCE(c.vad_end_sensitivity, ''), COALESCE(c.vad_prefix_padding_ms, 0), COALESCE(c.vad_silence_duration_ms, 0), COALESCE(c.context_compression, false), COALESCE(c.compression_trigger_tokens, 0), COALESCE(c.compression_target_tokens, 0)`
+
+func scanConfig(row pgx.Row, cfg *AIConfig) error {
+	return row.Scan(
+		&cfg.VoiceName, &cfg.LanguageCode, &cfg.Temperature, &cfg.ThinkingBudget, &cfg.EnableAffectiveDialog, &cfg.ProactiveAudio, &cfg.SystemPrompt, &cfg.DocstringToolKnowledge, &cfg.DurationLimit, &cfg.TerminationAlertTime, &cfg.DocstringToolTerminate, &cfg.ProactiveAlertInstruction, &cfg.DocstringToolSendLink, &cfg.DocstringToolEscalate, &cfg.EscalationWebhookURL, &cfg.DocstringToolLead, &cfg.LeadSchema, &cfg.SchedulingEnabled, &cfg.AgentName, &cfg.AgentDescription, &cfg.IsDefault, &cfg.EnabledTools, &cfg.KBCategories, &cfg.Version, &cfg.Timezone, &cfg.BusinessHours,
+		&cfg.TopP, &cfg.TopK, &cfg.MaxOutputTokens, &cfg.VADStartSensitivity, &cfg.VADEndSensitivity, &cfg.VADPrefixPaddingMs, &cfg.VADSilenceDurationMs, &cfg.ContextCompression, &cfg.CompressionTriggerTokens, &cfg.CompressionTargetTokens,
+	)
+}
+
+// notifyConfigChange avisa os orquestradores (LISTEN aivoice_config) que a configuração do cliente
+// mudou, invalidando o cache deles. Falhas só atrasam a atualização até a expiração do cache.
+func notifyConfigChange(clientName string) {
+	if _, err := db.Exec(context.Background(), "SELECT pg_notify('aivoice_config', $1)", clientName); err != nil {
+		log.Printf("⚠️ Erro ao notificar mudança de configuração de %s: %v", clientName, err)
+	}
+}
+
+func fetchAllCategories(clientName string) ([]string, error) {
+	rows, err := db.Query(context.Background(), `
+		SELECT kc.name FROM knowledge_categories kc
+		JOIN aiVoice_clients cl ON kc.client_id = cl.id
+		WHERE cl.name = $1
+		ORDER BY kc.name ASC`, clientName)
 	if err != nil {
 		return nil, err
 	}
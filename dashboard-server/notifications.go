@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// -- Structs --
+
+type NotificationTemplate struct {
+	ID        int       `json:"id"`
+	Channel   string    `json:"channel"` // email, sms
+	Name      string    `json:"name"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type NotificationLog struct {
+	ID        int       `json:"id"`
+	CallID    string    `json:"callId"`
+	Channel   string    `json:"channel"`
+	Template  string    `json:"template"`
+	Recipient string    `json:"recipient"`
+	Status    string    `json:"status"` // sent, failed
+	Error     string    `json:"error"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// -- Handlers --
+
+// handleNotificationTemplates gerencia os modelos de email/SMS do cliente.
+// Os modelos usam text/template com {{.Name}}, {{.Message}}, {{.Code}}, {{.When}}, {{.ClientName}} e {{.CallID}}.
+func handleNotificationTemplates(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	tenant := currentTenant(r)
+	clientName := tenant.Name
+
+	switch r.Method {
+	case "GET":
+		rows, err := db.Query(ctx, `
+			SELECT t.id, t.channel, t.name, COALESCE(t.subject, ''), t.body, t.updated_at
+			FROM aiVoice_notification_templates t
+			JOIN aiVoice_clients cl ON t.client_id = cl.id
+			WHERE cl.name = $1
+			ORDER BY t.channel, t.name`, clientName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+		templates := []NotificationTemplate{}
+		for rows.Next() {
+			var t NotificationTemplate
+			if err := rows.Scan(&t.ID, &t.Channel, &t.Name, &t.Subject, &t.Body, &t.UpdatedAt); err != nil {
+				continue
+			}
+			templates = append(templates, t)
+		}
+		json.NewEncoder(w).Encode(templates)
+	case "POST":
+		var t NotificationTemplate
+		if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if t.Channel != "email" && t.Channel != "sms" {
+			http.Error(w, "channel must be email or sms", http.StatusBadRequest)
+			return
+		}
+		if t.Name == "" || t.Body == "" {
+			http.Error(w, "name and body are required", http.StatusBadRequest)
+			return
+		}
+		err := db.QueryRow(ctx, `
+			INSERT INTO aiVoice_notification_templates (client_id, channel, name, subject, body)
+			VALUES ((SELECT id FROM aiVoice_clients WHERE name = $1), $2, $3, $4, $5)
+			ON CONFLICT (client_id, channel, name) DO UPDATE SET subject = EXCLUDED.subject, body = EXCLUDED.body, updated_at = NOW()
+			RETURNING id, updated_at`, clientName, t.Channel, t.Name, t.Subject, t.Body).Scan(&t.ID, &t.UpdatedAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Os nomes dos modelos entram na declaração das ferramentas enviar_email/enviar_sms
+		notifyConfigChange(clientName)
+		json.NewEncoder(w).Encode(t)
+	case "DELETE":
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "ID required", http.StatusBadRequest)
+			return
+		}
+		if _, err := db.Exec(ctx, "DELETE FROM aiVoice_notification_templates WHERE id = $1 AND client_id = $2", id, tenant.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		notifyConfigChange(clientName)
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleNotificationLog lista o status de entrega (opcionalmente filtrado por ?callId=).
+func handleNotificationLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rows, err := db.Query(context.Background(), `
+		SELECT id, call_id::text, channel, template, recipient, status, COALESCE(error, ''), created_at
+		FROM aiVoice_notifications
+		WHERE client_id = $2 AND ($1 = '' OR call_id::text = $1)
+		ORDER BY created_at DESC
+		LIMIT 200`, r.URL.Query().Get("callId"), currentTenant(r).ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	logs := []NotificationLog{}
+	for rows.Next() {
+		var l NotificationLog
+		if err := rows.Scan(&l.ID, &l.CallID, &l.Channel, &l.Template, &l.Recipient, &l.Status, &l.Error, &l.CreatedAt); err != nil {
+			continue
+		}
+		logs = append(logs, l)
+	}
+	json.NewEncoder(w).Encode(logs)
+}
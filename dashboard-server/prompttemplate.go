@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Espelha o template do prompt renderizado pelo orquestrador (server/internal/orchestrator/prompt.go)
+// para validar e pré-visualizar o prompt antes de salvar.
+
+const defaultTimezone = "America/Sao_Paulo"
+
+var (
+	weekdayNames = [...]string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"}
+	clockPattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+)
+
+// -- Structs --
+
+type CallerInfo struct {
+	Name string `json:"name"`
+}
+
+type PageInfo struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// BusinessHoursRule é um intervalo de atendimento; weekday 0 = domingo.
+type BusinessHoursRule struct {
+	Weekday int    `json:"weekday"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+}
+
+type BusinessHoursStatus struct {
+	Configured bool
+	Open       bool
+	Status     string
+	Today      string
+}
+
+// PromptData são as variáveis disponíveis no template ({{.ClientName}}, {{.Now}}, {{.Caller.Name}}, {{.Page.URL}}, {{.BusinessHours.Status}}, {{.Context.UserID}}...).
+type PromptData struct {
+	ClientName    string
+	AgentName     string
+	Now           string
+	Date          string
+	Time          string
+	Weekday       string
+	Caller        CallerInfo
+	Page          PageInfo
+	BusinessHours BusinessHoursStatus
+	Context       CallContext
+}
+
+type PromptPreviewRequest struct {
+	SystemPrompt  string              `json:"systemPrompt"`
+	Timezone      string              `json:"timezone"`
+	BusinessHours []BusinessHoursRule `json:"businessHours"`
+	Caller        CallerInfo          `json:"caller"`
+	Page          PageInfo            `json:"page"`
+	Context       CallContext         `json:"context"`
+	Now           *time.Time          `json:"now"`
+}
+
+// -- Renderização --
+
+func newPromptData(clientName, agentName, timezone string, hours []BusinessHoursRule, caller CallerInfo, page PageInfo, callCtx CallContext, now time.Time) PromptData {
+	if page.URL == "" {
+		page.URL = callCtx.PageURL
+	}
+	loc, err := time.LoadLocation(timezone)
+	if timezone == "" || err != nil {
+		loc, _ = time.LoadLocation(defaultTimezone)
+	}
+	if loc != nil {
+		now = now.In(loc)
+	}
+	return PromptData{
+		ClientName:    clientName,
+		AgentName:     agentName,
+		Now:           fmt.Sprintf("%s, %s", weekdayNames[now.Weekday()], now.Format("02/01/2006 15:04")),
+		Date:          now.Format("02/01/2006"),
+		Time:          now.Format("15:04"),
+		Weekday:       weekdayNames[now.Weekday()],
+		Caller:        CallerInfo{Name: promptFallback(caller.Name, "não informado")},
+		Page:          PageInfo{URL: promptFallback(page.URL, "não informada"), Title: promptFallback(page.Title, "não informado")},
+		BusinessHours: businessHoursStatus(hours, now),
+		Context:       callCtx,
+	}
+}
+
+func renderPrompt(text string, data PromptData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tpl, err := template.New("prompt").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func businessHoursStatus(rules []BusinessHoursRule, now time.Time) BusinessHoursStatus {
+	if len(rules) == 0 {
+		return BusinessHoursStatus{Status: "não informado", Today: "não informado"}
+	}
+	status := BusinessHoursStatus{Configured: true, Status: "fechado"}
+	clock := now.Format("15:04")
+	var today []string
+	for _, r := range rules {
+		if r.Weekday != int(now.Weekday()) {
+			continue
+		}
+		today = append(today, r.Start+"–"+r.End)
+		if clock >= r.Start && clock < r.End {
+			status.Open = true
+			status.Status = "aberto"
+		}
+	}
+	status.Today = "fechado hoje"
+	if len(today) > 0 {
+		status.Today = strings.Join(today, ", ")
+	}
+	return status
+}
+
+func promptFallback(v, def string) string {
+	if v = strings.Join(strings.Fields(v), " "); v == "" {
+		return def
+	}
+	return v
+}
+
+// validatePromptConfig confere fuso, horários de atendimento e se o template do prompt renderiza.
+func validatePromptConfig(cfg *AIConfig, clientName string) error {
+	if cfg.Timezone != "" {
+		if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+			return errors.New("invalid timezone")
+		}
+	}
+	for _, h := range cfg.BusinessHours {
+		if h.Weekday < 0 || h.Weekday > 6 || !clockPattern.MatchString(h.Start) || !clockPattern.MatchString(h.End) || h.Start >= h.End {
+			return errors.New("invalid business hours (weekday 0-6, start < end, HH:MM)")
+		}
+	}
+	data := newPromptData(clientName, cfg.AgentName, cfg.Timezone, cfg.BusinessHours, CallerInfo{}, PageInfo{}, CallContext{}, time.Now())
+	if _, err := renderPrompt(cfg.SystemPrompt, data); err != nil {
+		return fmt.Errorf("invalid system prompt template: %w", err)
+	}
+	return nil
+}
+
+// -- Handlers --
+
+// handlePromptPreview renderiza o template do prompt com dados de exemplo (POST ?agent=).
+// Fuso e horários vêm do corpo ou, se omitidos, da configuração salva do agente.
+func handlePromptPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req PromptPreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	clientName := currentTenant(r).Name
+	agentName := r.URL.Query().Get("agent")
+	if cfg, err := fetchConfig(clientName, agentName); err == nil && cfg != nil {
+		agentName = cfg.AgentName
+		if req.Timezone == "" {
+			req.Timezone = cfg.Timezone
+		}
+		if req.BusinessHours == nil {
+			req.BusinessHours = cfg.BusinessHours
+		}
+	}
+
+	// Dados de exemplo para o que não foi informado
+	if req.Caller.Name == "" {
+		req.Caller.Name = "Maria Silva"
+	}
+	if req.Page.URL == "" {
+		req.Page.URL = "https://www.exemplo.com.br/produtos"
+	}
+	if req.Page.Title == "" {
+		req.Page.Title = "Produtos"
+	}
+	if req.Context.IsEmpty() {
+		req.Context = CallContext{PageURL: req.Page.URL, Locale: "pt-BR", UserID: "12345", Custom: map[string]string{"plano": "premium"}}
+	}
+	now := time.Now()
+	if req.Now != nil {
+		now = *req.Now
+	}
+
+	data := newPromptData(clientName, agentName, req.Timezone, req.BusinessHours, req.Caller, req.Page, req.Context, now)
+	rendered, err := renderPrompt(req.SystemPrompt, data)
+	resp := map[string]interface{}{"rendered": rendered, "data": data}
+	if err != nil {
+		resp["error"] = err.Error()
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,667 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// -- Structs --
+
+type ScheduleResource struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	SlotMinutes int       `json:"slotMinutes"`
+	Timezone    string    `json:"timezone"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type WorkingHours struct {
+	Weekday   int    `json:"weekday"` // 0 = domingo
+	StartTime string `json:"startTime"`
+	EndTime   string `json:"endTime"`
+}
+
+type Blackout struct {
+	ID         int    `json:"id"`
+	ResourceID *int   `json:"resourceId"` // nil = todos os recursos do cliente
+	Date       string `json:"date"`
+	Reason     string `json:"reason"`
+}
+
+type Booking struct {
+	ID            int       `json:"id"`
+	Code          string    `json:"code"`
+	ResourceID    int       `json:"resourceId"`
+	ResourceName  string    `json:"resourceName"`
+	CallID        *string   `json:"callId"`
+	CustomerName  string    `json:"customerName"`
+	CustomerPhone string    `json:"customerPhone"`
+	StartsAt      time.Time `json:"startsAt"`
+	EndsAt        time.Time `json:"endsAt"`
+	Status        string    `json:"status"` // booked, confirmed, cancelled
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type BookingRequest struct {
+	ClientName    string `json:"clientName"`
+	CallID        string `json:"callId"`
+	ResourceID    int    `json:"resourceId"`
+	Start         string `json:"start"` // "2006-01-02T15:04" no fuso do recurso
+	CustomerName  string `json:"customerName"`
+	CustomerPhone string `json:"customerPhone"`
+}
+
+type AvailableSlots struct {
+	ResourceID   int      `json:"resourceId"`
+	ResourceName string   `json:"resourceName"`
+	Date         string   `json:"date"`
+	Slots        []string `json:"slots"` // "15:04"
+}
+
+var (
+	errSlotTaken        = errors.New("slot already booked")
+	errResourceRequired = errors.New("resourceId is required when the client has more than one resource")
+)
+
+const bookingCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func newBookingCode() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	for i := range b {
+		b[i] = bookingCodeAlphabet[int(b[i])%len(bookingCodeAlphabet)]
+	}
+	return string(b)
+}
+
+// -- Availability --
+
+func loadResources(ctx context.Context, clientName string, resourceID int) ([]ScheduleResource, error) {
+	rows, err := db.Query(ctx, `
+		SELECT r.id, r.name, r.slot_minutes, r.timezone, r.active, r.created_at
+		FROM aiVoice_schedule_resources r
+		JOIN aiVoice_clients cl ON r.client_id = cl.id
+		WHERE cl.name = $1 AND r.active AND ($2 = 0 OR r.id = $2)
+		ORDER BY r.name`, clientName, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var resources []ScheduleResource
+	for rows.Next() {
+		var res ScheduleResource
+		if err := rows.Scan(&res.ID, &res.Name, &res.SlotMinutes, &res.Timezone, &res.Active, &res.CreatedAt); err != nil {
+			continue
+		}
+		resources = append(resources, res)
+	}
+	return resources, nil
+}
+
+// freeSlots calcula os horários livres de um recurso em uma data (no fuso do recurso),
+// descontando bloqueios, reservas ativas e horários já passados.
+func freeSlots(ctx context.Context, res ScheduleResource, date string) ([]string, error) {
+	loc, err := time.LoadLocation(res.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	day, err := time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocked bool
+	err = db.QueryRow(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM aiVoice_schedule_blackouts b
+			JOIN aiVoice_schedule_resources r ON r.client_id = b.client_id
+			WHERE r.id = $1 AND b.date = $2::date AND (b.resource_id IS NULL OR b.resource_id = r.id)
+		)`, res.ID, date).Scan(&blocked)
+	if err != nil || blocked {
+		return []string{}, err
+	}
+
+	rows, err := db.Query(ctx, "SELECT to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI') FROM aiVoice_schedule_hours WHERE resource_id = $1 AND weekday = $2 ORDER BY start_time", res.ID, int(day.Weekday()))
+	if err != nil {
+		return nil, err
+	}
+	var windows [][2]time.Time
+	for rows.Next() {
+		var start, end string
+		if err := rows.Scan(&start, &end); err != nil {
+			continue
+		}
+		s, _ := time.ParseInLocation("2006-01-02 15:04", date+" "+start, loc)
+		e, _ := time.ParseInLocation("2006-01-02 15:04", date+" "+end, loc)
+		windows = append(windows, [2]time.Time{s, e})
+	}
+	rows.Close()
+
+	rows, err = db.Query(ctx, "SELECT starts_at, ends_at FROM aiVoice_bookings WHERE resource_id = $1 AND status <> 'cancelled' AND starts_at < $3 AND ends_at > $2", res.ID, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	var taken [][2]time.Time
+	for rows.Next() {
+		var s, e time.Time
+		if err := rows.Scan(&s, &e); err == nil {
+			taken = append(taken, [2]time.Time{s, e})
+		}
+	}
+	rows.Close()
+
+	slot := time.Duration(res.SlotMinutes) * time.Minute
+	now := time.Now()
+	slots := []string{}
+	for _, w := range windows {
+		for t := w[0]; !t.Add(slot).After(w[1]); t = t.Add(slot) {
+			if t.Before(now) {
+				continue
+			}
+			free := true
+			for _, b := range taken {
+				if t.Before(b[1]) && t.Add(slot).After(b[0]) {
+					free = false
+					break
+				}
+			}
+			if free {
+				slots = append(slots, t.Format("15:04"))
+			}
+		}
+	}
+	return slots, nil
+}
+
+// createBooking grava a reserva; a constraint de exclusão no Postgres impede
+// sobreposição de horários no mesmo recurso, mesmo com chamadas concorrentes.
+func createBooking(ctx context.Context, req BookingRequest) (*Booking, error) {
+	resources, err := loadResources(ctx, req.ClientName, req.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("resource %d not found", req.ResourceID)
+	}
+	// Sem resource_id só dá para escolher quando o cliente tem um único recurso ativo
+	if req.ResourceID == 0 && len(resources) > 1 {
+		return nil, errResourceRequired
+	}
+	res := resources[0]
+
+	loc, err := time.LoadLocation(res.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	start, err := time.ParseInLocation("2006-01-02T15:04", req.Start, loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start: %w", err)
+	}
+
+	// O horário precisa ser um slot válido (expediente, sem bloqueio, futuro)
+	slots, err := freeSlots(ctx, res, start.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	valid := false
+	for _, s := range slots {
+		if s == start.Format("15:04") {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, errSlotTaken
+	}
+
+	var callID *string
+	if req.CallID != "" {
+		callID = &req.CallID
+	}
+
+	b := Booking{ResourceID: res.ID, ResourceName: res.Name, CallID: callID, CustomerName: req.CustomerName, CustomerPhone: req.CustomerPhone}
+	for attempt := 0; attempt < 3; attempt++ {
+		b.Code = newBookingCode()
+		err = db.QueryRow(ctx, `
+			INSERT INTO aiVoice_bookings (resource_id, client_id, call_id, code, customer_name, customer_phone, starts_at, ends_at)
+			SELECT $1, r.client_id, $2, $3, $4, $5, $6, $7 FROM aiVoice_schedule_resources r WHERE r.id = $1
+			RETURNING id, starts_at, ends_at, status, created_at`,
+			res.ID, callID, b.Code, req.CustomerName, req.CustomerPhone, start, start.Add(time.Duration(res.SlotMinutes)*time.Minute),
+		).Scan(&b.ID, &b.StartsAt, &b.EndsAt, &b.Status, &b.CreatedAt)
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23P01": // exclusion_violation: horário já reservado
+				return nil, errSlotTaken
+			case "23505": // unique_violation: código repetido, tenta outro
+				continue
+			}
+		}
+		break
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// setBookingStatus confirma ou cancela a reserva pelo código, só entre as reservas do cliente.
+func setBookingStatus(ctx context.Context, clientName, code, status string) (bool, error) {
+	query := "UPDATE aiVoice_bookings SET status = 'confirmed' WHERE code = $1 AND status = 'booked'"
+	if status == "cancelled" {
+		query = "UPDATE aiVoice_bookings SET status = 'cancelled' WHERE code = $1 AND status <> 'cancelled'"
+	}
+	query += " AND client_id = (SELECT id FROM aiVoice_clients WHERE name = $2)"
+	res, err := db.Exec(ctx, query, code, clientName)
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// -- Internal Handlers (orchestrator) --
+
+func handleAvailability(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	clientName := r.URL.Query().Get("client")
+	date := r.URL.Query().Get("date")
+	if clientName == "" || date == "" {
+		http.Error(w, "client and date are required", http.StatusBadRequest)
+		return
+	}
+	resourceID, _ := strconv.Atoi(r.URL.Query().Get("resourceId"))
+
+	ctx := context.Background()
+	resources, err := loadResources(ctx, clientName, resourceID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := []AvailableSlots{}
+	for _, res := range resources {
+		slots, err := freeSlots(ctx, res, date)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result = append(result, AvailableSlots{ResourceID: res.ID, ResourceName: res.Name, Date: date, Slots: slots})
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func handleCreateBooking(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req BookingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	b, err := createBooking(context.Background(), req)
+	if errors.Is(err, errSlotTaken) {
+		http.Error(w, "Slot unavailable", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[BOOKING ERROR] %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("[BOOKING] Reserva %s criada (%s %s)", b.Code, b.ResourceName, b.StartsAt.Format(time.RFC3339))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(b)
+}
+
+// handleBookingAction confirma ou cancela uma reserva pelo código (?client=&code=; tools confirmar_reserva
+// e cancelar_reserva do orquestrador).
+func handleBookingAction(status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		clientName := r.URL.Query().Get("client")
+		code := r.URL.Query().Get("code")
+		if clientName == "" || code == "" {
+			http.Error(w, "client and code are required", http.StatusBadRequest)
+			return
+		}
+		ok, err := setBookingStatus(context.Background(), clientName, code, status)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "Booking not found or already "+status, http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// -- Dashboard Handlers --
+
+func handleScheduleResources(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	tenant := currentTenant(r)
+	clientName := tenant.Name
+
+	switch r.Method {
+	case "GET":
+		rows, err := db.Query(ctx, `
+			SELECT r.id, r.name, r.slot_minutes, r.timezone, r.active, r.created_at
+			FROM aiVoice_schedule_resources r
+			JOIN aiVoice_clients cl ON r.client_id = cl.id
+			WHERE cl.name = $1 ORDER BY r.name`, clientName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+		resources := []ScheduleResource{}
+		for rows.Next() {
+			var res ScheduleResource
+			if err := rows.Scan(&res.ID, &res.Name, &res.SlotMinutes, &res.Timezone, &res.Active, &res.CreatedAt); err != nil {
+				continue
+			}
+			resources = append(resources, res)
+		}
+		json.NewEncoder(w).Encode(resources)
+	case "POST", "PUT":
+		var req ScheduleResource
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.SlotMinutes <= 0 {
+			req.SlotMinutes = 30
+		}
+		if req.Timezone == "" {
+			req.Timezone = "America/Sao_Paulo"
+		}
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			http.Error(w, "Invalid timezone", http.StatusBadRequest)
+			return
+		}
+
+		var err error
+		if r.Method == "POST" {
+			err = db.QueryRow(ctx, `
+				INSERT INTO aiVoice_schedule_resources (client_id, name, slot_minutes, timezone, active)
+				VALUES ((SELECT id FROM aiVoice_clients WHERE name = $1), $2, $3, $4, true)
+				RETURNING id, active, created_at`, clientName, req.Name, req.SlotMinutes, req.Timezone).Scan(&req.ID, &req.Active, &req.CreatedAt)
+		} else {
+			req.ID, err = strconv.Atoi(r.URL.Query().Get("id"))
+			if err != nil {
+				http.Error(w, "Invalid ID", http.StatusBadRequest)
+				return
+			}
+			_, err = db.Exec(ctx, "UPDATE aiVoice_schedule_resources SET name = $1, slot_minutes = $2, timezone = $3, active = $4 WHERE id = $5 AND client_id = $6", req.Name, req.SlotMinutes, req.Timezone, req.Active, req.ID, tenant.ID)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(req)
+	case "DELETE":
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "ID required", http.StatusBadRequest)
+			return
+		}
+		if _, err := db.Exec(ctx, "DELETE FROM aiVoice_schedule_resources WHERE id = $1 AND client_id = $2", id, tenant.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleScheduleHours lê ou substitui o expediente semanal de um recurso.
+func handleScheduleHours(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	resourceID, err := strconv.Atoi(r.URL.Query().Get("resourceId"))
+	if err != nil {
+		http.Error(w, "resourceId required", http.StatusBadRequest)
+		return
+	}
+	var owned bool
+	db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM aiVoice_schedule_resources WHERE id = $1 AND client_id = $2)", resourceID, currentTenant(r).ID).Scan(&owned)
+	if !owned {
+		http.Error(w, "Resource not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		rows, err := db.Query(ctx, "SELECT weekday, to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI') FROM aiVoice_schedule_hours WHERE resource_id = $1 ORDER BY weekday, start_time", resourceID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+		hours := []WorkingHours{}
+		for rows.Next() {
+			var h WorkingHours
+			if err := rows.Scan(&h.Weekday, &h.StartTime, &h.EndTime); err != nil {
+				continue
+			}
+			hours = append(hours, h)
+		}
+		json.NewEncoder(w).Encode(hours)
+	case "PUT":
+		var hours []WorkingHours
+		if err := json.NewDecoder(r.Body).Decode(&hours); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, h := range hours {
+			if h.Weekday < 0 || h.Weekday > 6 || h.StartTime >= h.EndTime {
+				http.Error(w, "Invalid working hours", http.StatusBadRequest)
+				return
+			}
+		}
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(ctx)
+		if _, err := tx.Exec(ctx, "DELETE FROM aiVoice_schedule_hours WHERE resource_id = $1", resourceID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, h := range hours {
+			if _, err := tx.Exec(ctx, "INSERT INTO aiVoice_schedule_hours (resource_id, weekday, start_time, end_time) VALUES ($1, $2, $3::time, $4::time)", resourceID, h.Weekday, h.StartTime, h.EndTime); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := tx.Commit(ctx); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleScheduleBlackouts(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	tenant := currentTenant(r)
+	clientName := tenant.Name
+
+	switch r.Method {
+	case "GET":
+		rows, err := db.Query(ctx, `
+			SELECT b.id, b.resource_id, to_char(b.date, 'YYYY-MM-DD'), b.reason
+			FROM aiVoice_schedule_blackouts b
+			JOIN aiVoice_clients cl ON b.client_id = cl.id
+			WHERE cl.name = $1 AND b.date >= CURRENT_DATE
+			ORDER BY b.date`, clientName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+		blackouts := []Blackout{}
+		for rows.Next() {
+			var b Blackout
+			if err := rows.Scan(&b.ID, &b.ResourceID, &b.Date, &b.Reason); err != nil {
+				continue
+			}
+			blackouts = append(blackouts, b)
+		}
+		json.NewEncoder(w).Encode(blackouts)
+	case "POST":
+		var b Blackout
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err := db.QueryRow(ctx, `
+			INSERT INTO aiVoice_schedule_blackouts (client_id, resource_id, date, reason)
+			VALUES ((SELECT id FROM aiVoice_clients WHERE name = $1), $2, $3::date, $4)
+			RETURNING id`, clientName, b.ResourceID, b.Date, b.Reason).Scan(&b.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(b)
+	case "DELETE":
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "ID required", http.StatusBadRequest)
+			return
+		}
+		if _, err := db.Exec(ctx, "DELETE FROM aiVoice_schedule_blackouts WHERE id = $1 AND client_id = $2", id, tenant.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleScheduleBookings lista a agenda (GET ?from=&to=) e permite criar, remarcar ou alterar o status (PUT ?id=).
+func handleScheduleBookings(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	tenant := currentTenant(r)
+	clientName := tenant.Name
+
+	switch r.Method {
+	case "GET":
+		from := r.URL.Query().Get("from")
+		to := r.URL.Query().Get("to")
+		rows, err := db.Query(ctx, `
+			SELECT b.id, b.code, b.resource_id, r.name, b.call_id::text, b.customer_name, b.customer_phone, b.starts_at, b.ends_at, b.status, b.created_at
+			FROM aiVoice_bookings b
+			JOIN aiVoice_schedule_resources r ON b.resource_id = r.id
+			JOIN aiVoice_clients cl ON b.client_id = cl.id
+			WHERE cl.name = $1
+			  AND b.starts_at >= COALESCE(NULLIF($2, '')::date, CURRENT_DATE)
+			  AND ($3 = '' OR b.starts_at < NULLIF($3, '')::date + INTERVAL '1 day')
+			ORDER BY b.starts_at
+			LIMIT 500`, clientName, from, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+		bookings := []Booking{}
+		for rows.Next() {
+			var b Booking
+			if err := rows.Scan(&b.ID, &b.Code, &b.ResourceID, &b.ResourceName, &b.CallID, &b.CustomerName, &b.CustomerPhone, &b.StartsAt, &b.EndsAt, &b.Status, &b.CreatedAt); err != nil {
+				continue
+			}
+			bookings = append(bookings, b)
+		}
+		json.NewEncoder(w).Encode(bookings)
+	case "POST":
+		var req BookingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.ClientName = clientName
+		b, err := createBooking(ctx, req)
+		if errors.Is(err, errSlotTaken) {
+			http.Error(w, "Slot unavailable", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(b)
+	case "PUT":
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Status   string     `json:"status"`
+			StartsAt *time.Time `json:"startsAt"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch req.Status {
+		case "", "booked", "confirmed", "cancelled":
+		default:
+			http.Error(w, "Invalid status", http.StatusBadRequest)
+			return
+		}
+
+		// Remarcação mantém a duração original; a constraint de exclusão barra conflitos
+		_, err = db.Exec(ctx, `
+			UPDATE aiVoice_bookings SET
+				status = COALESCE(NULLIF($2, ''), status),
+				starts_at = COALESCE($3, starts_at),
+				ends_at = COALESCE($3 + (ends_at - starts_at), ends_at)
+			WHERE id = $1 AND client_id = $4`, id, req.Status, req.StartsAt, tenant.ID)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23P01" {
+			http.Error(w, "Slot unavailable", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"aivoice-shared/secrets"
+)
+
+// Segredos gravados no banco (ex.: chave Gemini própria do cliente) usam a criptografia envelope do
+// pacote compartilhado aivoice-shared/secrets, o mesmo do orquestrador, que decifra os mesmos valores.
+//
+// Chaves mestras: SECRETS_MASTER_KEYS ("id=base64,..." com chaves de 32 bytes; a primeira cifra,
+// todas decifram), o arquivo em SECRETS_MASTER_KEYS_FILE (mesmo formato) ou SECRETS_KEY (ID "default").
+// Para rotacionar: coloque a nova chave em primeiro lugar, mantenha a antiga e rode
+// `aivoicectl rotate-secrets`; depois disso a antiga pode ser removida.
+
+func encryptSecret(plain string) (string, error) {
+	return secrets.Encrypt(plain)
+}
+
+func decryptSecret(value string) (string, error) {
+	return secrets.Decrypt(value)
+}
+
+// secretColumns lista as colunas com segredos cifrados (nunca devolvidos pela API), percorridas na rotação.
+var secretColumns = []struct{ table, idColumn, column string }{
+	{"aiVoice_clients", "id", "gemini_api_key"},
+	{"aiVoice_clients", "id", "webhook_secret"},
+	{"aiVoice_config", "id", "escalation_webhook_url"},
+	{"aiVoice_whatsapp_channels", "id", "access_token"},
+}
+
+// maskSecret mostra só o final do segredo, para o usuário reconhecer qual está gravado.
+func maskSecret(plain string) string {
+	if len(plain) <= 8 {
+		return "••••"
+	}
+	return "••••" + plain[len(plain)-4:]
+}
+
+type SecretRotationResult struct {
+	ActiveKey string   `json:"activeKey"`
+	Rotated   int      `json:"rotated"`
+	Current   int      `json:"current"` // já estavam com a chave ativa
+	Failed    []string `json:"failed"`  // tabela.coluna#id que nenhuma chave configurada abre
+}
+
+// rotateSecrets re-cifra com a chave mestra ativa todos os segredos cifrados com outra chave.
+func rotateSecrets(ctx context.Context) (SecretRotationResult, error) {
+	keys, err := secrets.Default()
+	if err != nil {
+		return SecretRotationResult{}, err
+	}
+	res := SecretRotationResult{ActiveKey: keys.ActiveID(), Failed: []string{}}
+	for _, sc := range secretColumns {
+		rows, err := db.Query(ctx, fmt.Sprintf("SELECT %s, %s FROM %s WHERE COALESCE(%s, '') <> ''", sc.idColumn, sc.column, sc.table, sc.column))
+		if err != nil {
+			return res, err
+		}
+		type row struct {
+			id    int
+			value string
+		}
+		var pending []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.value); err != nil {
+				rows.Close()
+				return res, err
+			}
+			if keys.NeedsRotation(r.value) {
+				pending = append(pending, r)
+			} else {
+				res.Current++
+			}
+		}
+		rows.Close()
+
+		for _, r := range pending {
+			ref := fmt.Sprintf("%s.%s#%d", sc.table, sc.column, r.id)
+			plain, err := keys.Decrypt(r.value)
+			if err != nil {
+				res.Failed = append(res.Failed, ref)
+				continue
+			}
+			enc, err := keys.Encrypt(plain)
+			if err != nil {
+				return res, err
+			}
+			// Só troca se o valor não mudou desde a leitura
+			tag, err := db.Exec(ctx, fmt.Sprintf("UPDATE %s SET %s = $1 WHERE %s = $2 AND %s = $3", sc.table, sc.column, sc.idColumn, sc.column), enc, r.id, r.value)
+			if err != nil {
+				return res, err
+			}
+			if tag.RowsAffected() == 1 {
+				res.Rotated++
+			}
+		}
+	}
+	return res, nil
+}
+
+// handleAdminRotateSecrets re-cifra os segredos do banco com a chave mestra ativa (POST).
+func handleAdminRotateSecrets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	res, err := rotateSecrets(context.Background())
+	if err != nil {
+		log.Printf("❌ Rotação de segredos falhou: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("🔐 Segredos re-cifrados com a chave %s: %d rotacionados, %d já atuais, %d com falha", res.ActiveKey, res.Rotated, res.Current, len(res.Failed))
+	json.NewEncoder(w).Encode(res)
+}
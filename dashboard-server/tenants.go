@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// -- Structs --
+
+// Tenant é o cliente ativo da requisição, resolvido a partir do usuário autenticado.
+type Tenant struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type ClientKey struct {
+	ID        int        `json:"id"`
+	Label     string     `json:"label"`
+	Kind      string     `json:"kind"` // widget (pública, ?key= no /ws) ou server (secreta, API de chat)
+	Prefix    string     `json:"prefix"`
+	Key       string     `json:"key,omitempty"` // Só é devolvida na criação
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt"`
+}
+
+const tenantKey contextKey = "tenant"
+
+var errNoTenantAccess = errors.New("user has no access to this client")
+
+// defaultClientName retorna o cliente padrão da instância (usado apenas em implantações single-tenant).
+func defaultClientName() string {
+	if name := os.Getenv("INSTANCE_CLIENT_NAME"); name != "" {
+		return name
+	}
+	return "aiVoice"
+}
+
+// -- Resolução do tenant --
+
+// userClients lista os clientes aos quais o usuário do dashboard está vinculado.
+func userClients(ctx context.Context, userID int) ([]Tenant, error) {
+	rows, err := db.Query(ctx, `
+		SELECT cl.id, cl.name
+		FROM dashboard_user_clients uc
+		JOIN aiVoice_clients cl ON uc.client_id = cl.id
+		WHERE uc.user_id = $1
+		ORDER BY cl.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []Tenant
+	for rows.Next() {
+		var t Tenant
+		if err := rows.Scan(&t.ID, &t.Name); err == nil {
+			tenants = append(tenants, t)
+		}
+	}
+	return tenants, nil
+}
+
+// resolveTenant escolhe o cliente da requisição: o solicitado (header X-Client) se o usuário
+// tiver acesso a ele, senão o primeiro cliente vinculado.
+func resolveTenant(ctx context.Context, userID int, requested string) (Tenant, error) {
+	tenants, err := userClients(ctx, userID)
+	if err != nil {
+		return Tenant{}, err
+	}
+	if len(tenants) == 0 {
+		return Tenant{}, errNoTenantAccess
+	}
+	if requested == "" {
+		return tenants[0], nil
+	}
+	for _, t := range tenants {
+		if t.Name == requested {
+			return t, nil
+		}
+	}
+	return Tenant{}, errNoTenantAccess
+}
+
+// currentTenant retorna o cliente resolvido pelo authMiddleware.
+func currentTenant(r *http.Request) Tenant {
+	t, _ := r.Context().Value(tenantKey).(Tenant)
+	return t
+}
+
+// currentUserID retorna o id do usuário autenticado (claims do JWT) ou 0 fora do authMiddleware.
+func currentUserID(r *http.Request) int {
+	claims, ok := r.Context().Value(claimsKey).(jwt.MapClaims)
+	if !ok {
+		return 0
+	}
+	id, _ := claims["user_id"].(float64)
+	return int(id)
+}
+
+// -- Chaves de widget --
+
+// hashClientKey é o formato armazenado das chaves; o orquestrador calcula o mesmo hash.
+func hashClientKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Tipos de chave: as de widget ficam expostas no navegador; as de servidor autenticam a API de chat
+// (POST /v1/chat no orquestrador) e nunca devem sair do backend do parceiro.
+const (
+	keyKindWidget = "widget"
+	keyKindServer = "server"
+)
+
+func newClientKey(kind string) (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	prefix := "pk_"
+	if kind == keyKindServer {
+		prefix = "sk_"
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+// -- Handlers --
+
+// handleClients lista os clientes acessíveis ao usuário (seletor de tenant do dashboard).
+func handleClients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tenants, err := userClients(context.Background(), currentUserID(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if tenants == nil {
+		tenants = []Tenant{}
+	}
+	json.NewEncoder(w).Encode(tenants)
+}
+
+// handleClientKeys gerencia as chaves usadas pelo widget e pelos servidores dos parceiros
+// para se autenticar no orquestrador.
+func handleClientKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	tenant := currentTenant(r)
+
+	switch r.Method {
+	case "GET":
+		rows, err := db.Query(ctx, `
+			SELECT id, label, COALESCE(kind, 'widget'), key_prefix, created_at, revoked_at
+			FROM aiVoice_client_keys
+			WHERE client_id = $1
+			ORDER BY created_at DESC`, tenant.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+		keys := []ClientKey{}
+		for rows.Next() {
+			var k ClientKey
+			if err := rows.Scan(&k.ID, &k.Label, &k.Kind, &k.Prefix, &k.CreatedAt, &k.RevokedAt); err != nil {
+				continue
+			}
+			keys = append(keys, k)
+		}
+		json.NewEncoder(w).Encode(keys)
+	case "POST":
+		var req ClientKey
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Kind == "" {
+			req.Kind = keyKindWidget
+		}
+		if req.Kind != keyKindWidget && req.Kind != keyKindServer {
+			http.Error(w, "kind must be widget or server", http.StatusBadRequest)
+			return
+		}
+		key, err := newClientKey(req.Kind)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		req.Key = key
+		req.Prefix = key[:11]
+		err = db.QueryRow(ctx, `
+			INSERT INTO aiVoice_client_keys (client_id, label, kind, key_prefix, key_hash)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, created_at`, tenant.ID, req.Label, req.Kind, req.Prefix, hashClientKey(key)).Scan(&req.ID, &req.CreatedAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("🔑 Chave (%s) %s criada para o cliente %s por %s", req.Kind, req.Prefix, tenant.Name, currentUserEmail(r))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(req)
+	case "DELETE":
+		res, err := db.Exec(ctx, "UPDATE aiVoice_client_keys SET revoked_at = NOW() WHERE id = $1 AND client_id = $2 AND revoked_at IS NULL", r.URL.Query().Get("id"), tenant.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if res.RowsAffected() == 0 {
+			http.Error(w, "Key not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
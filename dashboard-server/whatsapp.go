@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Canal WhatsApp: cada cliente vincula os números do WhatsApp Business (phone_number_id da Cloud API)
+// e o token de acesso usado pelo orquestrador para responder. O token é gravado cifrado (secrets.go).
+
+var phoneNumberIDPattern = regexp.MustCompile(`^[0-9]{5,32}$`)
+
+// -- Structs --
+
+type WhatsAppChannel struct {
+	ID            int       `json:"id"`
+	PhoneNumberID string    `json:"phoneNumberId"`
+	DisplayPhone  string    `json:"displayPhone"`
+	Enabled       bool      `json:"enabled"`
+	TokenMasked   string    `json:"tokenMasked,omitempty"` // o token nunca é devolvido
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type WhatsAppChannelRequest struct {
+	PhoneNumberID string `json:"phoneNumberId"`
+	DisplayPhone  string `json:"displayPhone"`
+	AccessToken   string `json:"accessToken"`
+	Enabled       *bool  `json:"enabled"`
+}
+
+// -- Handlers --
+
+// handleWhatsAppChannels lista (GET), vincula ou atualiza (PUT, por phoneNumberId) e remove
+// (DELETE ?id=) os números de WhatsApp do cliente.
+func handleWhatsAppChannels(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	tenant := currentTenant(r)
+
+	switch r.Method {
+	case "GET":
+		rows, err := db.Query(ctx, `
+			SELECT id, phone_number_id, COALESCE(display_phone, ''), enabled, access_token, created_at
+			FROM aiVoice_whatsapp_channels
+			WHERE client_id = $1
+			ORDER BY created_at`, tenant.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+		channels := []WhatsAppChannel{}
+		for rows.Next() {
+			var c WhatsAppChannel
+			var enc string
+			if err := rows.Scan(&c.ID, &c.PhoneNumberID, &c.DisplayPhone, &c.Enabled, &enc, &c.CreatedAt); err != nil {
+				continue
+			}
+			if token, err := decryptSecret(enc); err == nil {
+				c.TokenMasked = maskSecret(token)
+			}
+			channels = append(channels, c)
+		}
+		json.NewEncoder(w).Encode(channels)
+
+	case "PUT":
+		var req WhatsAppChannelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.PhoneNumberID = strings.TrimSpace(req.PhoneNumberID)
+		if !phoneNumberIDPattern.MatchString(req.PhoneNumberID) {
+			http.Error(w, "invalid phoneNumberId", http.StatusBadRequest)
+			return
+		}
+		enabled := req.Enabled == nil || *req.Enabled
+
+		var c WhatsAppChannel
+		token := strings.TrimSpace(req.AccessToken)
+		if token == "" {
+			// Sem accessToken, só atualiza os demais campos de um número já vinculado
+			err := db.QueryRow(ctx, `
+				UPDATE aiVoice_whatsapp_channels
+				SET display_phone = COALESCE(NULLIF($3, ''), display_phone), enabled = $4
+				WHERE client_id = $1 AND phone_number_id = $2
+				RETURNING id, phone_number_id, COALESCE(display_phone, ''), enabled, created_at`,
+				tenant.ID, req.PhoneNumberID, req.DisplayPhone, enabled).Scan(&c.ID, &c.PhoneNumberID, &c.DisplayPhone, &c.Enabled, &c.CreatedAt)
+			if errors.Is(err, pgx.ErrNoRows) {
+				http.Error(w, "accessToken is required to link a new number", http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		} else {
+			enc, err := encryptSecret(token)
+			if err != nil {
+				log.Printf("❌ Erro ao cifrar o token do WhatsApp de %s: %v", tenant.Name, err)
+				http.Error(w, "Secret storage unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			// O WHERE do ON CONFLICT impede tomar o número vinculado a outro cliente
+			err = db.QueryRow(ctx, `
+				INSERT INTO aiVoice_whatsapp_channels (client_id, phone_number_id, display_phone, access_token, enabled)
+				VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+				ON CONFLICT (phone_number_id) DO UPDATE SET
+					display_phone = COALESCE(EXCLUDED.display_phone, aiVoice_whatsapp_channels.display_phone),
+					access_token = EXCLUDED.access_token,
+					enabled = EXCLUDED.enabled
+				WHERE aiVoice_whatsapp_channels.client_id = EXCLUDED.client_id
+				RETURNING id, phone_number_id, COALESCE(display_phone, ''), enabled, created_at`,
+				tenant.ID, req.PhoneNumberID, req.DisplayPhone, enc, enabled).Scan(&c.ID, &c.PhoneNumberID, &c.DisplayPhone, &c.Enabled, &c.CreatedAt)
+			if errors.Is(err, pgx.ErrNoRows) {
+				http.Error(w, "phoneNumberId already linked to another client", http.StatusConflict)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if token != "" {
+			c.TokenMasked = maskSecret(token)
+		}
+		log.Printf("💬 WhatsApp %s vinculado ao cliente %s por %s (ativo: %v)", c.PhoneNumberID, tenant.Name, currentUserEmail(r), c.Enabled)
+		json.NewEncoder(w).Encode(c)
+
+	case "DELETE":
+		res, err := db.Exec(ctx, "DELETE FROM aiVoice_whatsapp_channels WHERE id = $1 AND client_id = $2", r.URL.Query().Get("id"), tenant.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if res.RowsAffected() == 0 {
+			http.Error(w, "Channel not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("💬 WhatsApp removido do cliente %s por %s", tenant.Name, currentUserEmail(r))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
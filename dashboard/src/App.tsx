@@ -5,6 +5,7 @@ import AgentConfig from './pages/AgentConfig';
 import Calls from './pages/Calls';
 import Users from './pages/Users';
 import ToolsConfig from './pages/ToolsConfig';
+import Escalations from './pages/Escalations';
 import Layout from './components/Layout';
 import { AuthProvider, useAuth } from './context/AuthContext';
 import { ToastProvider } from './components/Toast';
@@ -58,6 +59,14 @@ function App() {
                             </ProtectedRoute>
                         } />
 
+                        <Route path="/escalations" element={
+                            <ProtectedRoute>
+                                <Layout>
+                                    <Escalations />
+                                </Layout>
+                            </ProtectedRoute>
+                        } />
+
                         <Route path="/users" element={
                             <ProtectedRoute>
                                 <Layout>
@@ -1,5 +1,5 @@
 import { NavLink, useLocation } from 'react-router-dom';
-import { LayoutDashboard, Phone, Bot, Users, LogOut, ChevronDown, Book, Settings, Terminal } from 'lucide-react';
+import { LayoutDashboard, Phone, Bot, Users, LogOut, ChevronDown, Book, Settings, Terminal, Headphones } from 'lucide-react';
 import { motion, AnimatePresence } from 'framer-motion';
 import { useAuth } from '../context/AuthContext';
 import { useState, useEffect } from 'react';
@@ -7,6 +7,7 @@ import { useState, useEffect } from 'react';
 const menuItems = [
     { icon: LayoutDashboard, label: 'Painel', path: '/dashboard' },
     { icon: Phone, label: 'Atendimentos', path: '/calls' },
+    { icon: Headphones, label: 'Transferências', path: '/escalations' },
 ];
 
 const agentSubMenu = [
@@ -1,5 +1,5 @@
 import { useEffect, useState } from 'react';
-import { Bot, Save, Loader2, Brain, Activity } from 'lucide-react';
+import { Bot, Save, Loader2, Brain, Activity, SlidersHorizontal, Mic } from 'lucide-react';
 import api from '../services/api';
 import { useToast } from '../components/Toast';
 
@@ -16,8 +16,23 @@ interface AIConfig {
     proactiveAlertInstruction: string;
     durationLimit: number;
     terminationAlertTime: number;
+    // Opções nativas do Gemini Live (0 / vazio = padrão do modelo)
+    topP: number;
+    topK: number;
+    maxOutputTokens: number;
+    vadStartSensitivity: '' | 'high' | 'low';
+    vadEndSensitivity: '' | 'high' | 'low';
+    vadPrefixPaddingMs: number;
+    vadSilenceDurationMs: number;
+    contextCompression: boolean;
+    compressionTriggerTokens: number;
+    compressionTargetTokens: number;
 }
 
+type NumericField = 'thinkingBudget' | 'topP' | 'topK' | 'maxOutputTokens' | 'vadPrefixPaddingMs' | 'vadSilenceDurationMs' | 'compressionTriggerTokens' | 'compressionTargetTokens';
+
+const inputClass = "w-full bg-zinc-950 border border-zinc-700 rounded px-2 py-1.5 text-white text-sm focus:outline-none focus:border-blue-500 transition-colors";
+
 
 export default function AgentConfig() {
     const [config, setConfig] = useState<AIConfig | null>(null);
@@ -55,6 +70,22 @@ export default function AgentConfig() {
         }
     };
 
+    const numberInput = (field: NumericField, label: string, hint: string, step = 1) => (
+        <div className="space-y-1">
+            <div className="flex items-center justify-between">
+                <span className="text-xs font-medium text-zinc-400">{label}</span>
+                <span className="text-[10px] text-zinc-600 font-mono">{hint}</span>
+            </div>
+            <input
+                type="number"
+                step={step}
+                value={config ? config[field] ?? 0 : 0}
+                onChange={(e) => config && setConfig({ ...config, [field]: step < 1 ? parseFloat(e.target.value) || 0 : parseInt(e.target.value) || 0 })}
+                className={inputClass}
+            />
+        </div>
+    );
+
     if (loading) return <div className="flex h-full items-center justify-center"><Loader2 className="animate-spin h-8 w-8 text-blue-500" /></div>;
     if (!config) return <div>Erro ao carregar.</div>;
 
@@ -161,6 +192,69 @@ export default function AgentConfig() {
                                     />
                                 </div>
                             </div>
+
+                            {/* Geração (generationConfig) */}
+                            <div className="space-y-3">
+                                <label className="text-xs font-bold text-zinc-500 uppercase tracking-widest pl-1 flex items-center gap-2">
+                                    <SlidersHorizontal className="h-3 w-3" /> Geração
+                                </label>
+                                <div className="grid grid-cols-2 gap-3 p-3 bg-zinc-900/30 rounded-lg border border-zinc-800/50">
+                                    {numberInput('thinkingBudget', 'Raciocínio', '-1 auto · 0 off')}
+                                    {numberInput('maxOutputTokens', 'Máx. tokens', '0 = padrão')}
+                                    {numberInput('topP', 'Top P', '0–1', 0.05)}
+                                    {numberInput('topK', 'Top K', '0 = padrão')}
+                                </div>
+                            </div>
+
+                            {/* Detecção de voz (realtimeInputConfig.automaticActivityDetection) */}
+                            <div className="space-y-3">
+                                <label className="text-xs font-bold text-zinc-500 uppercase tracking-widest pl-1 flex items-center gap-2">
+                                    <Mic className="h-3 w-3" /> Detecção de Voz
+                                </label>
+                                <div className="grid grid-cols-2 gap-3 p-3 bg-zinc-900/30 rounded-lg border border-zinc-800/50">
+                                    {(['vadStartSensitivity', 'vadEndSensitivity'] as const).map((field) => (
+                                        <div key={field} className="space-y-1">
+                                            <span className="text-xs font-medium text-zinc-400">{field === 'vadStartSensitivity' ? 'Início da fala' : 'Fim da fala'}</span>
+                                            <select
+                                                value={config[field] || ''}
+                                                onChange={(e) => setConfig({ ...config, [field]: e.target.value as AIConfig[typeof field] })}
+                                                className={inputClass}
+                                            >
+                                                <option value="">Padrão</option>
+                                                <option value="high">Alta</option>
+                                                <option value="low">Baixa</option>
+                                            </select>
+                                        </div>
+                                    ))}
+                                    {numberInput('vadPrefixPaddingMs', 'Pré-fala (ms)', '0 = padrão')}
+                                    {numberInput('vadSilenceDurationMs', 'Silêncio (ms)', '0 = padrão')}
+                                </div>
+                            </div>
+
+                            {/* Compressão da janela de contexto (contextWindowCompression) */}
+                            <div className="space-y-3">
+                                <label className="flex items-center justify-between p-3 bg-zinc-900/50 rounded-lg border border-zinc-800/50 cursor-pointer hover:border-zinc-700 transition-colors group">
+                                    <div className="flex items-center gap-3">
+                                        <div className={`h-2 w-2 rounded-full ${config.contextCompression ? 'bg-blue-500 shadow-[0_0_8px_rgba(59,130,246,0.5)]' : 'bg-zinc-700'}`} />
+                                        <span className="text-sm font-medium text-zinc-300 group-hover:text-white transition-colors">Compressão de Contexto</span>
+                                    </div>
+                                    <div className={`w-10 h-6 rounded-full relative transition-colors ${config.contextCompression ? 'bg-blue-700' : 'bg-zinc-800'}`}>
+                                        <div className={`absolute top-1 left-1 w-4 h-4 rounded-full bg-white transition-transform ${config.contextCompression ? 'translate-x-4' : 'translate-x-0'}`} />
+                                        <input
+                                            type="checkbox"
+                                            checked={!!config.contextCompression}
+                                            onChange={(e) => setConfig({ ...config, contextCompression: e.target.checked })}
+                                            className="hidden"
+                                        />
+                                    </div>
+                                </label>
+                                {config.contextCompression && (
+                                    <div className="grid grid-cols-2 gap-3 p-3 bg-zinc-900/30 rounded-lg border border-zinc-800/50">
+                                        {numberInput('compressionTriggerTokens', 'Disparo', 'tokens')}
+                                        {numberInput('compressionTargetTokens', 'Alvo', 'tokens')}
+                                    </div>
+                                )}
+                            </div>
                         </div>
                     </div>
                 </div>
@@ -13,13 +13,36 @@ interface Call {
     inputTokens: number;
     outputTokens: number;
     status: string;
+    context?: CallContext;
+    model?: string;
+    modality?: 'audio' | 'text';
+    video?: boolean;
     createdAt: string;
 }
 
+// Contexto enviado pelo widget no setup (filtrado pela allowlist do cliente)
+interface CallContext {
+    pageUrl?: string;
+    referrer?: string;
+    userId?: string;
+    locale?: string;
+    custom?: Record<string, string>;
+}
+
+const CONTEXT_FILTER_FIELDS = [
+    { value: 'userId', label: 'Usuário' },
+    { value: 'locale', label: 'Idioma' },
+    { value: 'pageUrl', label: 'Página' },
+    { value: 'referrer', label: 'Origem' },
+];
+
 export default function Calls() {
     const [calls, setCalls] = useState<Call[]>([]);
     const [selectedCall, setSelectedCall] = useState<Call | null>(null);
     const [currentPage, setCurrentPage] = useState(1);
+    const [filterField, setFilterField] = useState('userId');
+    const [filterValue, setFilterValue] = useState('');
+    const [modalityFilter, setModalityFilter] = useState('');
     const ITEMS_PER_PAGE = 7;
 
     const totalPages = Math.ceil(calls.length / ITEMS_PER_PAGE);
@@ -32,8 +55,13 @@ export default function Calls() {
 
     const fetchCalls = async () => {
         try {
-            const { data } = await api.get('/dashboard/calls');
+            // Campos customizados são filtrados como "custom.<chave>"
+            const field = filterField.trim();
+            const params: Record<string, string> = filterValue.trim() && field ? { [`context.${field}`]: filterValue.trim() } : {};
+            if (modalityFilter) params.modality = modalityFilter;
+            const { data } = await api.get('/dashboard/calls', { params });
             setCalls(data || []);
+            setCurrentPage(1);
         } catch (error) {
             console.error('Error fetching calls', error);
         }
@@ -52,6 +80,42 @@ export default function Calls() {
                 <p className="text-zinc-400">Histórico de conversas realizadas pelo agente.</p>
             </div>
 
+            <form
+                onSubmit={(e) => { e.preventDefault(); fetchCalls(); }}
+                className="flex flex-wrap items-center gap-2 mb-4"
+            >
+                <input
+                    list="context-filter-fields"
+                    value={filterField}
+                    onChange={(e) => setFilterField(e.target.value)}
+                    placeholder="Campo (ex: custom.plano)"
+                    className="bg-white/5 border border-white/10 rounded-lg px-3 py-2 text-sm w-48"
+                />
+                <datalist id="context-filter-fields">
+                    {CONTEXT_FILTER_FIELDS.map((f) => (
+                        <option key={f.value} value={f.value}>{f.label}</option>
+                    ))}
+                </datalist>
+                <input
+                    value={filterValue}
+                    onChange={(e) => setFilterValue(e.target.value)}
+                    placeholder="Valor do contexto"
+                    className="bg-white/5 border border-white/10 rounded-lg px-3 py-2 text-sm w-64"
+                />
+                <select
+                    value={modalityFilter}
+                    onChange={(e) => setModalityFilter(e.target.value)}
+                    className="bg-white/5 border border-white/10 rounded-lg px-3 py-2 text-sm"
+                >
+                    <option value="">Voz e texto</option>
+                    <option value="audio">Só voz</option>
+                    <option value="text">Só texto</option>
+                </select>
+                <button type="submit" className="px-4 py-2 rounded-lg bg-blue-600 hover:bg-blue-500 text-sm font-medium">
+                    Filtrar
+                </button>
+            </form>
+
             <div className="glass-card rounded-2xl overflow-hidden flex flex-col">
                 <div className="overflow-x-auto">
                     <table className="w-full text-left">
@@ -184,6 +248,39 @@ export default function Calls() {
                                         <span className="text-zinc-400 text-sm">Status</span>
                                         <span className="font-medium uppercase text-xs">{selectedCall.status}</span>
                                     </div>
+                                    <div className="flex justify-between">
+                                        <span className="text-zinc-400 text-sm">Canal</span>
+                                        <span className="font-medium text-xs">{selectedCall.modality === 'text' ? 'Chat (texto)' : 'Voz'}</span>
+                                    </div>
+                                    {selectedCall.video && (
+                                        <div className="flex justify-between">
+                                            <span className="text-zinc-400 text-sm">Vídeo</span>
+                                            <span className="font-medium text-xs">Tela/câmera compartilhada</span>
+                                        </div>
+                                    )}
+                                    {selectedCall.model && (
+                                        <div className="flex justify-between gap-4">
+                                            <span className="text-zinc-400 text-sm">Modelo</span>
+                                            <span className="font-mono text-xs text-zinc-300 break-all text-right">{selectedCall.model.replace(/^models\//, '')}</span>
+                                        </div>
+                                    )}
+                                    {selectedCall.context && Object.keys(selectedCall.context).length > 0 && (
+                                        <div className="border-t border-white/5 pt-2 mt-2 space-y-1">
+                                            <span className="text-zinc-400 text-sm">Contexto</span>
+                                            {Object.entries({
+                                                userId: selectedCall.context.userId,
+                                                locale: selectedCall.context.locale,
+                                                pageUrl: selectedCall.context.pageUrl,
+                                                referrer: selectedCall.context.referrer,
+                                                ...Object.fromEntries(Object.entries(selectedCall.context.custom || {}).map(([k, v]) => [`custom.${k}`, v])),
+                                            }).filter(([, v]) => v).map(([k, v]) => (
+                                                <div key={k} className="flex justify-between gap-4 text-xs">
+                                                    <span className="text-zinc-500 font-mono">{k}</span>
+                                                    <span className="text-zinc-300 break-all text-right">{v}</span>
+                                                </div>
+                                            ))}
+                                        </div>
+                                    )}
                                     <div className="text-[10px] text-zinc-600 font-mono mt-4 break-all">
                                         ID: {selectedCall.callId}
                                     </div>
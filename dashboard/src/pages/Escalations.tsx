@@ -0,0 +1,124 @@
+import { useEffect, useState } from 'react';
+import { Headphones, Loader2 } from 'lucide-react';
+import api from '../services/api';
+import { cn } from '../utils/cn';
+import { useToast } from '../components/Toast';
+
+interface Escalation {
+    id: number;
+    callId: string;
+    clientName: string;
+    reason: string;
+    summary: string;
+    contact: Record<string, string>;
+    status: 'open' | 'claimed' | 'resolved';
+    claimedBy: string | null;
+    createdAt: string;
+}
+
+export default function Escalations() {
+    const [items, setItems] = useState<Escalation[]>([]);
+    const [loading, setLoading] = useState(true);
+    const { showToast } = useToast();
+
+    useEffect(() => {
+        fetchQueue();
+        const interval = setInterval(fetchQueue, 15000);
+        return () => clearInterval(interval);
+    }, []);
+
+    const fetchQueue = async () => {
+        try {
+            const { data } = await api.get('/dashboard/escalations');
+            setItems(data || []);
+        } catch (error) {
+            console.error('Error fetching escalations', error);
+        } finally {
+            setLoading(false);
+        }
+    };
+
+    const updateStatus = async (id: number, status: Escalation['status']) => {
+        try {
+            await api.put(`/dashboard/escalations/item?id=${id}`, { status });
+            showToast(status === 'claimed' ? 'Ticket assumido.' : status === 'resolved' ? 'Ticket resolvido.' : 'Ticket devolvido à fila.', 'success');
+            fetchQueue();
+        } catch (error) {
+            showToast('Não foi possível atualizar o ticket.', 'error');
+        }
+    };
+
+    return (
+        <div className="relative h-full overflow-y-auto p-8">
+            <div className="flex flex-col gap-2 mb-6">
+                <h1 className="text-3xl font-bold flex items-center gap-2">
+                    <Headphones className="h-7 w-7 text-emerald-500" /> Fila de Transferências
+                </h1>
+                <p className="text-zinc-400">Atendimentos que o agente transferiu para um humano.</p>
+            </div>
+
+            <div className="glass-card rounded-2xl overflow-hidden">
+                {loading ? (
+                    <div className="flex items-center justify-center p-12"><Loader2 className="animate-spin h-6 w-6 text-zinc-500" /></div>
+                ) : (
+                    <table className="w-full text-left">
+                        <thead className="bg-white/5 text-xs uppercase text-zinc-400">
+                            <tr>
+                                <th className="p-4">Ticket</th>
+                                <th className="p-4">Motivo / Resumo</th>
+                                <th className="p-4">Contato</th>
+                                <th className="p-4">Status</th>
+                                <th className="p-4">Ações</th>
+                            </tr>
+                        </thead>
+                        <tbody className="divide-y divide-white/5">
+                            {items.map((item) => (
+                                <tr key={item.id} className="hover:bg-white/5 transition-colors align-top">
+                                    <td className="p-4 text-zinc-500">
+                                        #{item.id}
+                                        <div className="text-[10px] font-mono">{item.callId.substring(0, 8)}</div>
+                                        <div className="text-xs">{new Date(item.createdAt).toLocaleString()}</div>
+                                    </td>
+                                    <td className="p-4">
+                                        <div className="font-medium">{item.reason}</div>
+                                        <div className="text-sm text-zinc-400">{item.summary}</div>
+                                    </td>
+                                    <td className="p-4 text-sm text-zinc-300">
+                                        {Object.entries(item.contact || {}).map(([k, v]) => (
+                                            <div key={k}><span className="text-zinc-500">{k}:</span> {v}</div>
+                                        ))}
+                                    </td>
+                                    <td className="p-4">
+                                        <span className={cn(
+                                            "px-2 py-1 rounded-full text-[10px] font-bold border uppercase",
+                                            item.status === 'open' ? "bg-red-500/10 text-red-500 border-red-500/20" :
+                                                item.status === 'claimed' ? "bg-blue-500/10 text-blue-500 border-blue-500/20" :
+                                                    "bg-green-500/10 text-green-500 border-green-500/20"
+                                        )}>
+                                            {item.status}
+                                        </span>
+                                        {item.claimedBy && <div className="text-xs text-zinc-500 mt-1">{item.claimedBy}</div>}
+                                    </td>
+                                    <td className="p-4 space-x-3 text-sm font-medium">
+                                        {item.status === 'open' && (
+                                            <button onClick={() => updateStatus(item.id, 'claimed')} className="text-blue-400 hover:text-blue-300">Assumir</button>
+                                        )}
+                                        {item.status === 'claimed' && (
+                                            <>
+                                                <button onClick={() => updateStatus(item.id, 'resolved')} className="text-emerald-400 hover:text-emerald-300">Resolver</button>
+                                                <button onClick={() => updateStatus(item.id, 'open')} className="text-zinc-400 hover:text-zinc-300">Devolver</button>
+                                            </>
+                                        )}
+                                    </td>
+                                </tr>
+                            ))}
+                            {items.length === 0 && (
+                                <tr><td colSpan={5} className="p-8 text-center text-zinc-500">Nenhuma transferência pendente.</td></tr>
+                            )}
+                        </tbody>
+                    </table>
+                )}
+            </div>
+        </div>
+    );
+}
@@ -9,6 +9,11 @@ api.interceptors.request.use((config) => {
     if (token) {
         config.headers.Authorization = `Bearer ${token}`;
     }
+    // Cliente (tenant) selecionado; sem valor o servidor usa o primeiro cliente vinculado ao usuário
+    const client = localStorage.getItem('client');
+    if (client) {
+        config.headers['X-Client'] = client;
+    }
     return config;
 });
 
@@ -69,3 +69,375 @@ CREATE TABLE IF NOT EXISTS knowledge_base (
 INSERT INTO dashboard_users (email, password_hash, name, role)
 VALUES ('admin@exemplo.com', '$2a$10$uSjHFAk9k.iIpsY5MNd3MuA4cSSM4HauEkeN1Xc.8s0FJi.0LJ6LC', 'Administrador', 'admin')
 ON CONFLICT (email) DO NOTHING;
+
+-- Escalonamento para atendimento humano (transferir_para_humano)
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS docstring_tool_escalate TEXT DEFAULT 'Use esta ferramenta quando o usuário pedir para falar com uma pessoa ou quando você não conseguir resolver a solicitação. Informe o motivo, um resumo da conversa e os dados de contato já coletados.';
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS escalation_webhook_url TEXT DEFAULT '';
+ALTER TABLE aiVoice_calls ADD COLUMN IF NOT EXISTS escalated BOOLEAN DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS aiVoice_escalations (
+    id SERIAL PRIMARY KEY,
+    call_id UUID NOT NULL,
+    client_id INTEGER REFERENCES aiVoice_clients(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL DEFAULT '',
+    summary TEXT NOT NULL DEFAULT '',
+    contact JSONB DEFAULT '{}'::jsonb,
+    status TEXT NOT NULL DEFAULT 'open', -- open, claimed, resolved
+    claimed_by TEXT,
+    claimed_at TIMESTAMP WITH TIME ZONE,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_aivoice_escalations_status ON aiVoice_escalations(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_aivoice_escalations_call_id ON aiVoice_escalations(call_id);
+
+-- Captura de Leads (registrar_lead): schema de campos por cliente + tabela de leads
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS lead_schema JSONB DEFAULT '[]'::jsonb;
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS docstring_tool_lead TEXT DEFAULT 'Use esta ferramenta para registrar os dados de contato do usuário assim que ele os informar. Se a ferramenta retornar erros de validação, peça novamente apenas os campos indicados.';
+
+CREATE TABLE IF NOT EXISTS aiVoice_leads (
+    id SERIAL PRIMARY KEY,
+    call_id UUID NOT NULL,
+    client_id INTEGER REFERENCES aiVoice_clients(id) ON DELETE CASCADE,
+    data JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_aivoice_leads_client_created ON aiVoice_leads(client_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_aivoice_leads_call_id ON aiVoice_leads(call_id);
+
+-- Agendamento (consultar_horarios / agendar_horario)
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS scheduling_enabled BOOLEAN DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS aiVoice_schedule_resources (
+    id SERIAL PRIMARY KEY,
+    client_id INTEGER REFERENCES aiVoice_clients(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    slot_minutes INTEGER NOT NULL DEFAULT 30,
+    timezone TEXT NOT NULL DEFAULT 'America/Sao_Paulo',
+    active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS aiVoice_schedule_hours (
+    id SERIAL PRIMARY KEY,
+    resource_id INTEGER REFERENCES aiVoice_schedule_resources(id) ON DELETE CASCADE,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6), -- 0 = domingo
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL CHECK (end_time > start_time)
+);
+
+CREATE TABLE IF NOT EXISTS aiVoice_schedule_blackouts (
+    id SERIAL PRIMARY KEY,
+    client_id INTEGER REFERENCES aiVoice_clients(id) ON DELETE CASCADE,
+    resource_id INTEGER REFERENCES aiVoice_schedule_resources(id) ON DELETE CASCADE, -- NULL = todos os recursos
+    date DATE NOT NULL,
+    reason TEXT DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS aiVoice_bookings (
+    id SERIAL PRIMARY KEY,
+    resource_id INTEGER NOT NULL REFERENCES aiVoice_schedule_resources(id) ON DELETE CASCADE,
+    client_id INTEGER REFERENCES aiVoice_clients(id) ON DELETE CASCADE,
+    call_id UUID,
+    code TEXT NOT NULL UNIQUE,
+    customer_name TEXT DEFAULT '',
+    customer_phone TEXT DEFAULT '',
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status TEXT NOT NULL DEFAULT 'booked', -- booked, confirmed, cancelled
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    -- Proteção contra double-booking: nenhum par de reservas ativas do mesmo recurso pode se sobrepor
+    EXCLUDE USING gist (resource_id WITH =, tstzrange(starts_at, ends_at) WITH &&) WHERE (status <> 'cancelled')
+);
+
+CREATE INDEX IF NOT EXISTS idx_aivoice_bookings_resource_starts ON aiVoice_bookings(resource_id, starts_at);
+
+-- Notificações de saída (enviar_email / enviar_sms): modelos por cliente e log de entrega
+CREATE TABLE IF NOT EXISTS aiVoice_notification_templates (
+    id SERIAL PRIMARY KEY,
+    client_id INTEGER REFERENCES aiVoice_clients(id) ON DELETE CASCADE,
+    channel TEXT NOT NULL CHECK (channel IN ('email', 'sms')),
+    name TEXT NOT NULL,
+    subject TEXT DEFAULT '',
+    body TEXT NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (client_id, channel, name)
+);
+
+CREATE TABLE IF NOT EXISTS aiVoice_notifications (
+    id SERIAL PRIMARY KEY,
+    call_id UUID NOT NULL,
+    client_id INTEGER REFERENCES aiVoice_clients(id) ON DELETE CASCADE,
+    channel TEXT NOT NULL,
+    template TEXT NOT NULL,
+    recipient TEXT NOT NULL,
+    status TEXT NOT NULL, -- sent, failed
+    error TEXT DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_aivoice_notifications_call_id ON aiVoice_notifications(call_id);
+
+-- Múltiplos agentes por cliente (transferir_para_agente): cada linha de aiVoice_config passa a ser um agente
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS agent_name TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS agent_description TEXT DEFAULT '';
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS is_default BOOLEAN DEFAULT false;
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS enabled_tools TEXT[] DEFAULT '{}'; -- vazio = todas as ferramentas
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS kb_categories TEXT[] DEFAULT '{}'; -- vazio = todas as categorias
+ALTER TABLE aiVoice_config DROP CONSTRAINT IF EXISTS aivoice_config_client_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_aivoice_config_client_agent ON aiVoice_config(client_id, agent_name);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_aivoice_config_client_default ON aiVoice_config(client_id) WHERE is_default;
+
+-- Instalações existentes: a configuração única vira o agente padrão
+UPDATE aiVoice_config c SET is_default = true
+WHERE NOT EXISTS (SELECT 1 FROM aiVoice_config d WHERE d.client_id = c.client_id AND d.is_default)
+  AND c.id = (SELECT MIN(id) FROM aiVoice_config m WHERE m.client_id = c.client_id);
+
+ALTER TABLE aiVoice_calls ADD COLUMN IF NOT EXISTS agents TEXT[] DEFAULT '{}';
+
+-- Multi-tenancy: um deploy atende vários clientes
+-- Usuários do dashboard vinculados a um ou mais clientes
+CREATE TABLE IF NOT EXISTS dashboard_user_clients (
+    user_id INTEGER NOT NULL REFERENCES dashboard_users(id) ON DELETE CASCADE,
+    client_id INTEGER NOT NULL REFERENCES aiVoice_clients(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, client_id)
+);
+
+-- Chaves do widget: identificam o cliente na conexão com o orquestrador (apenas o hash SHA-256 é armazenado)
+CREATE TABLE IF NOT EXISTS aiVoice_client_keys (
+    id SERIAL PRIMARY KEY,
+    client_id INTEGER NOT NULL REFERENCES aiVoice_clients(id) ON DELETE CASCADE,
+    label TEXT DEFAULT '',
+    key_prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Base de conhecimento pertence a um cliente
+ALTER TABLE knowledge_base ADD COLUMN IF NOT EXISTS client_id INTEGER REFERENCES aiVoice_clients(id) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS idx_knowledge_base_client ON knowledge_base(client_id);
+CREATE INDEX IF NOT EXISTS idx_aivoice_escalations_client ON aiVoice_escalations(client_id, status);
+CREATE INDEX IF NOT EXISTS idx_aivoice_notifications_client ON aiVoice_notifications(client_id, created_at DESC);
+
+-- Provisionamento de clientes: templates de configuração inicial ({{.ClientName}} é substituído pelo nome do cliente)
+CREATE TABLE IF NOT EXISTS aiVoice_config_templates (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT DEFAULT '',
+    voice_name VARCHAR(100) DEFAULT 'Aoede',
+    language_code VARCHAR(50) DEFAULT 'pt-BR',
+    temperature FLOAT DEFAULT 0.7,
+    enable_affective_dialog BOOLEAN DEFAULT true,
+    proactive_audio BOOLEAN DEFAULT true,
+    system_prompt TEXT NOT NULL,
+    docstring_tool_knowledge TEXT,
+    docstring_tool_terminate TEXT,
+    docstring_tool_send_link TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO aiVoice_config_templates (name, description, system_prompt, docstring_tool_knowledge, docstring_tool_terminate, docstring_tool_send_link)
+VALUES (
+    'default',
+    'Assistente de voz genérico em português',
+    'Você é o {{.ClientName}}, um assistente de voz avançado criado pelo estúdio TkzM.',
+    'Invoque esta ferramenta sempre que o usuário tiver dúvidas que não estejam no seu System Prompt.',
+    'Use esta ferramenta para finalizar o atendimento educadamente.',
+    'Use esta ferramenta para enviar um link ao usuário. Você deve obrigatoriamente fornecer a ''url'' (completa com http/https) e o ''alias'' (texto curto que descreve o link).'
+)
+ON CONFLICT (name) DO NOTHING;
+
+-- Base de conhecimento isolada por cliente: categorias também pertencem a um cliente
+ALTER TABLE knowledge_categories ADD COLUMN IF NOT EXISTS client_id INTEGER REFERENCES aiVoice_clients(id) ON DELETE CASCADE;
+ALTER TABLE knowledge_categories DROP CONSTRAINT IF EXISTS knowledge_categories_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_knowledge_categories_client_name ON knowledge_categories(client_id, name);
+
+-- Histórico de versões da configuração (cada gravação gera uma versão imutável com autor e diff)
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE aiVoice_calls ADD COLUMN IF NOT EXISTS config_version INTEGER;
+
+CREATE TABLE IF NOT EXISTS aiVoice_config_versions (
+    id SERIAL PRIMARY KEY,
+    config_id INTEGER NOT NULL REFERENCES aiVoice_config(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    snapshot JSONB NOT NULL,
+    diff JSONB NOT NULL DEFAULT '[]'::jsonb,
+    author_id INTEGER REFERENCES dashboard_users(id) ON DELETE SET NULL,
+    author_email TEXT,
+    restored_from INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (config_id, version)
+);
+
+CREATE OR REPLACE FUNCTION aivoice_config_versions_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'aiVoice_config_versions rows are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_aivoice_config_versions_immutable ON aiVoice_config_versions;
+CREATE TRIGGER trg_aivoice_config_versions_immutable
+    BEFORE UPDATE ON aiVoice_config_versions
+    FOR EACH ROW EXECUTE FUNCTION aivoice_config_versions_immutable();
+
+-- Experimentos A/B de configuração (variantes com peso; atribuição determinística pelo call_id)
+CREATE TABLE IF NOT EXISTS aiVoice_experiments (
+    id SERIAL PRIMARY KEY,
+    client_id INTEGER NOT NULL REFERENCES aiVoice_clients(id) ON DELETE CASCADE,
+    agent_name TEXT NOT NULL,
+    name TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'running', -- running, stopped
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    ended_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Um experimento em andamento por agente
+CREATE UNIQUE INDEX IF NOT EXISTS idx_aivoice_experiments_running ON aiVoice_experiments(client_id, agent_name) WHERE status = 'running';
+
+CREATE TABLE IF NOT EXISTS aiVoice_experiment_variants (
+    id SERIAL PRIMARY KEY,
+    experiment_id INTEGER NOT NULL REFERENCES aiVoice_experiments(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    weight INTEGER NOT NULL CHECK (weight > 0),
+    overrides JSONB NOT NULL DEFAULT '{}'::jsonb,
+    UNIQUE (experiment_id, name)
+);
+
+ALTER TABLE aiVoice_calls ADD COLUMN IF NOT EXISTS experiment_id INTEGER REFERENCES aiVoice_experiments(id) ON DELETE SET NULL;
+ALTER TABLE aiVoice_calls ADD COLUMN IF NOT EXISTS experiment_variant TEXT;
+CREATE INDEX IF NOT EXISTS idx_aivoice_calls_experiment ON aiVoice_calls(experiment_id, experiment_variant);
+
+-- Template do prompt: fuso e horário de atendimento do agente ({{.Now}}, {{.BusinessHours.Status}})
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS timezone TEXT DEFAULT 'America/Sao_Paulo';
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS business_hours JSONB DEFAULT '[]'::jsonb;
+
+-- Contexto do visitante enviado no setup (allowlist por cliente; chaves customizadas como "custom.<chave>")
+ALTER TABLE aiVoice_clients ADD COLUMN IF NOT EXISTS context_fields TEXT[] DEFAULT ARRAY['pageUrl', 'referrer', 'locale'];
+ALTER TABLE aiVoice_calls ADD COLUMN IF NOT EXISTS caller_context JSONB DEFAULT '{}'::jsonb;
+CREATE INDEX IF NOT EXISTS idx_aivoice_calls_caller_context ON aiVoice_calls USING GIN (caller_context jsonb_path_ops);
+
+-- Memória de clientes recorrentes (identity = "user:<userId>" ou "caller:<caller ID>")
+ALTER TABLE aiVoice_clients ADD COLUMN IF NOT EXISTS caller_memory_enabled BOOLEAN DEFAULT false;
+ALTER TABLE aiVoice_clients ADD COLUMN IF NOT EXISTS caller_memory_retention_days INTEGER DEFAULT 90;
+
+CREATE TABLE IF NOT EXISTS aiVoice_caller_memory (
+    id SERIAL PRIMARY KEY,
+    client_id INTEGER NOT NULL REFERENCES aiVoice_clients(id) ON DELETE CASCADE,
+    identity TEXT NOT NULL,
+    key_facts TEXT[] NOT NULL DEFAULT '{}',
+    last_topic TEXT,
+    open_issues TEXT[] NOT NULL DEFAULT '{}',
+    call_count INTEGER NOT NULL DEFAULT 1,
+    last_call_id TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (client_id, identity)
+);
+CREATE INDEX IF NOT EXISTS idx_aivoice_caller_memory_updated ON aiVoice_caller_memory(client_id, updated_at);
+
+-- Opções nativas do Gemini Live por agente (0/vazio = padrão do modelo, exceto thinking_budget: -1 dinâmico = padrão, 0 desligado).
+-- Antes o 0 era o padrão do modelo: agentes gravados com o default antigo passam a -1 uma única vez (enquanto o default ainda é 0)
+DO $$
+BEGIN
+    IF (SELECT column_default FROM information_schema.columns
+        WHERE table_name = 'aivoice_config' AND column_name = 'thinking_budget') = '0' THEN
+        UPDATE aiVoice_config SET thinking_budget = -1 WHERE thinking_budget = 0;
+    END IF;
+END $$;
+ALTER TABLE aiVoice_config ALTER COLUMN thinking_budget SET DEFAULT -1;
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS top_p REAL DEFAULT 0;
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS top_k INTEGER DEFAULT 0;
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS max_output_tokens INTEGER DEFAULT 0;
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS vad_start_sensitivity TEXT;
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS vad_end_sensitivity TEXT;
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS vad_prefix_padding_ms INTEGER DEFAULT 0;
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS vad_silence_duration_ms INTEGER DEFAULT 0;
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS context_compression BOOLEAN DEFAULT false;
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS compression_trigger_tokens INTEGER DEFAULT 0;
+ALTER TABLE aiVoice_config ADD COLUMN IF NOT EXISTS compression_target_tokens INTEGER DEFAULT 0;
+
+-- Push-to-talk por cliente: desliga a detecção automática de voz do Gemini (widget envia activity_start/activity_end)
+ALTER TABLE aiVoice_clients ADD COLUMN IF NOT EXISTS push_to_talk BOOLEAN DEFAULT false;
+
+-- Modelo Gemini Live por cliente e cadeia de modelos reserva (tentados em ordem se o principal falhar)
+ALTER TABLE aiVoice_clients ADD COLUMN IF NOT EXISTS model TEXT;
+ALTER TABLE aiVoice_clients ADD COLUMN IF NOT EXISTS fallback_models TEXT[] DEFAULT '{}';
+ALTER TABLE aiVoice_calls ADD COLUMN IF NOT EXISTS model TEXT;
+
+-- Chave Gemini própria do cliente (BYOK), cifrada pelo dashboard (envelope, ver dashboard-server/secrets.go); NULL = pool da plataforma
+ALTER TABLE aiVoice_clients ADD COLUMN IF NOT EXISTS gemini_api_key TEXT;
+
+-- Consumo por chave Gemini em cada chamada (owner: platform | client), base da cobrança
+CREATE TABLE IF NOT EXISTS aiVoice_key_usage (
+    id SERIAL PRIMARY KEY,
+    call_id UUID NOT NULL REFERENCES aiVoice_calls(call_id) ON DELETE CASCADE,
+    client_id INTEGER REFERENCES aiVoice_clients(id) ON DELETE CASCADE,
+    key_id TEXT NOT NULL,
+    owner TEXT NOT NULL DEFAULT 'platform',
+    input_tokens INTEGER DEFAULT 0,
+    output_tokens INTEGER DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (call_id, key_id)
+);
+CREATE INDEX IF NOT EXISTS idx_aivoice_key_usage_client ON aiVoice_key_usage(client_id, owner);
+
+-- Modalidade da chamada (audio = voz, text = chat só por texto) e modelo dos chats por texto
+ALTER TABLE aiVoice_calls ADD COLUMN IF NOT EXISTS modality TEXT DEFAULT 'audio';
+CREATE INDEX IF NOT EXISTS idx_aivoice_calls_modality ON aiVoice_calls(client_id, modality);
+ALTER TABLE aiVoice_clients ADD COLUMN IF NOT EXISTS text_model TEXT;
+
+-- Tipo da chave do cliente: widget (pk_, pública) ou server (sk_, secreta, autentica a API de chat /v1/chat)
+ALTER TABLE aiVoice_client_keys ADD COLUMN IF NOT EXISTS kind TEXT DEFAULT 'widget';
+
+-- Canal WhatsApp (Cloud API): números do WhatsApp Business de cada cliente; access_token cifrado pelo dashboard (secrets.go)
+CREATE TABLE IF NOT EXISTS aiVoice_whatsapp_channels (
+    id SERIAL PRIMARY KEY,
+    client_id INTEGER NOT NULL REFERENCES aiVoice_clients(id) ON DELETE CASCADE,
+    phone_number_id TEXT NOT NULL UNIQUE,
+    display_phone TEXT,
+    access_token TEXT NOT NULL,
+    enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_aivoice_whatsapp_channels_client ON aiVoice_whatsapp_channels(client_id);
+-- Modelo generateContent que transcreve as notas de voz (NULL = TRANSCRIPTION_MODEL ou gemini-2.5-flash)
+ALTER TABLE aiVoice_clients ADD COLUMN IF NOT EXISTS transcription_model TEXT;
+
+-- Vídeo (compartilhamento de tela/câmera): liberação e limites por cliente (NULL = padrão do orquestrador)
+ALTER TABLE aiVoice_clients ADD COLUMN IF NOT EXISTS video_input BOOLEAN DEFAULT false;
+ALTER TABLE aiVoice_clients ADD COLUMN IF NOT EXISTS video_max_fps REAL;
+ALTER TABLE aiVoice_clients ADD COLUMN IF NOT EXISTS video_max_frame_kb INTEGER;
+ALTER TABLE aiVoice_calls ADD COLUMN IF NOT EXISTS video_used BOOLEAN DEFAULT false;
+
+-- Arquivos enviados pelo usuário durante a sessão (imagens e PDF): liberação e retenção por cliente
+-- (NULL = 30 dias) e a referência de cada arquivo guardado pelo orquestrador (UPLOAD_STORAGE)
+ALTER TABLE aiVoice_clients ADD COLUMN IF NOT EXISTS uploads_enabled BOOLEAN DEFAULT false;
+ALTER TABLE aiVoice_clients ADD COLUMN IF NOT EXISTS upload_retention_days INTEGER;
+CREATE TABLE IF NOT EXISTS aiVoice_uploads (
+    id TEXT PRIMARY KEY,
+    client_id INTEGER NOT NULL REFERENCES aiVoice_clients(id) ON DELETE CASCADE,
+    call_id TEXT NOT NULL,
+    storage_key TEXT NOT NULL,
+    file_name TEXT,
+    mime_type TEXT NOT NULL,
+    size_bytes INTEGER DEFAULT 0,
+    caption TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_aivoice_uploads_created ON aiVoice_uploads(created_at);
+CREATE INDEX IF NOT EXISTS idx_aivoice_uploads_call ON aiVoice_uploads(call_id);
+-- Arquivos de clientes excluídos: o dashboard enfileira as referências na exclusão do cliente (as linhas de
+-- aiVoice_uploads saem em cascata) e o orquestrador apaga os arquivos do armazenamento e a fila
+CREATE TABLE IF NOT EXISTS aiVoice_upload_deletions (
+    id TEXT PRIMARY KEY,
+    storage_key TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Segredo (cifrado) que assina os webhooks do cliente (X-AIVoice-Signature), gerado no primeiro uso
+ALTER TABLE aiVoice_clients ADD COLUMN IF NOT EXISTS webhook_secret TEXT;
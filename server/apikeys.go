@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"aivoice-v3/internal/keypool"
+)
+
+// Chaves da API Gemini: pool da plataforma (GEMINI_API_KEYS / GEMINI_API_KEY) ou a chave própria
+// do cliente (BYOK, aiVoice_clients.gemini_api_key). O consumo é atribuído por chave em cada chamada.
+
+const geminiLiveEndpoint = "wss://generativelanguage.googleapis.com/ws/google.ai.generativelanguage.v1alpha.GenerativeService.BidiGenerateContent"
+
+const (
+	// keyQuotaCooldown tira da rotação uma chave que estourou a cota, só para o modelo em que estourou
+	keyQuotaCooldown = time.Minute
+	// keyPermissionCooldown tira da rotação uma chave inválida, revogada ou sem permissão
+	keyPermissionCooldown = 15 * time.Minute
+)
+
+var apiKeys *keypool.Pool
+
+// KeyUsage é o consumo da chamada com uma chave (sincronizado com o dashboard para cobrança).
+type KeyUsage struct {
+	KeyID        string `json:"keyId"`
+	Owner        string `json:"owner"`
+	InputTokens  int    `json:"inputTokens"`
+	OutputTokens int    `json:"outputTokens"`
+}
+
+func geminiURL(key keypool.Key) string {
+	return geminiLiveEndpoint + "?key=" + url.QueryEscape(key.Secret)
+}
+
+// clientAPIKey devolve a chave própria do cliente, ou nil quando ele usa o pool da plataforma.
+func clientAPIKey(ctx context.Context, clientName string) *keypool.Key {
+	secret := configCache.APIKey(ctx, clientName)
+	if secret == "" {
+		return nil
+	}
+	key := keypool.ClientKey(clientName, secret)
+	return &key
+}
+
+// useAPIKey registra a chave da conexão recém-aberta com o Gemini (setup ou handoff).
+func (s *Session) useAPIKey(key keypool.Key) {
+	s.TranscriptLock.Lock()
+	defer s.TranscriptLock.Unlock()
+	s.APIKey = key
+	s.connInput, s.connOutput = 0, 0
+	s.baseInput, s.baseOutput = s.InputTokens, s.OutputTokens
+}
+
+// recordUsage atualiza os tokens da sessão e atribui o consumo novo à chave em uso.
+// O usageMetadata do Gemini é acumulado por conexão; só a diferença é somada à chave
+// e o total da sessão soma o que as conexões anteriores já consumiram.
+func (s *Session) recordUsage(input, output int) {
+	s.TranscriptLock.Lock()
+	s.InputTokens = s.baseInput + input
+	s.OutputTokens = s.baseOutput + output
+	dIn, dOut := input-s.connInput, output-s.connOutput
+	if dIn < 0 {
+		dIn = input
+	}
+	if dOut < 0 {
+		dOut = output
+	}
+	s.connInput, s.connOutput = input, output
+
+	key := s.APIKey
+	u := s.keyUsage(key)
+	u.InputTokens += dIn
+	u.OutputTokens += dOut
+	s.TranscriptLock.Unlock()
+
+	apiKeys.Record(key.ID, dIn+dOut)
+}
+
+// addRequestUsage soma à sessão o consumo de uma chamada avulsa à API fora da conexão Live (ex.: a
+// transcrição de uma nota de voz). Deve vir antes de abrir a conexão: useAPIKey parte do total já somado.
+func (s *Session) addRequestUsage(key keypool.Key, input, output int) {
+	if input+output == 0 {
+		return
+	}
+	s.TranscriptLock.Lock()
+	s.InputTokens += input
+	s.OutputTokens += output
+	s.baseInput += input
+	s.baseOutput += output
+	u := s.keyUsage(key)
+	u.InputTokens += input
+	u.OutputTokens += output
+	s.TranscriptLock.Unlock()
+
+	apiKeys.Record(key.ID, input+output)
+}
+
+// keyUsage devolve o consumo da sessão com a chave, criando-o no primeiro uso (chamar com TranscriptLock).
+func (s *Session) keyUsage(key keypool.Key) *KeyUsage {
+	if s.KeyUsage == nil {
+		s.KeyUsage = map[string]*KeyUsage{}
+	}
+	u := s.KeyUsage[key.ID]
+	if u == nil {
+		u = &KeyUsage{KeyID: key.ID, Owner: key.Owner}
+		s.KeyUsage[key.ID] = u
+	}
+	return u
+}
+
+// keyUsageList lista o consumo por chave (chamar com TranscriptLock).
+func (s *Session) keyUsageList() []KeyUsage {
+	var list []KeyUsage
+	for _, u := range s.KeyUsage {
+		if u.KeyID != "" {
+			list = append(list, *u)
+		}
+	}
+	return list
+}
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/jackc/pgx/v5"
+
+	"aivoice-v3/internal/orchestrator"
+	"aivoice-v3/internal/protocol"
+)
+
+// API de chat para integrações servidor a servidor (WhatsApp, apps dos parceiros): POST /v1/chat
+// recebe uma mensagem de uma conversa e devolve a resposta do agente em streaming (SSE).
+// Nada fica em memória entre as mensagens: cada uma abre uma sessão Gemini em modo texto, recarrega
+// o histórico da conversa em aiVoice_calls e executa o mesmo loop de ferramentas do /ws.
+
+const (
+	// chatTurnTimeout limita uma mensagem inteira (setup, ferramentas e resposta)
+	chatTurnTimeout = 2 * time.Minute
+	// chatToolGrace é a espera por mais conteúdo depois que as ferramentas terminam num turno já concluído
+	chatToolGrace        = 3 * time.Second
+	maxChatMessageLen    = 4000
+	maxConversationIDLen = 128
+)
+
+// chatNamespace deriva o call_id (UUID) de IDs de conversa que não são UUIDs (ex.: telefone no WhatsApp).
+var chatNamespace = uuid.MustParse("fbccd3c6-abff-4fe7-af6e-2214c29b7f3d")
+
+var (
+	errChatBusy             = errors.New("conversation has a message in progress")
+	errConversationNotFound = errors.New("conversation not found")
+)
+
+// activeChats marca as conversas com uma mensagem em andamento (uma por vez por conversa).
+var activeChats sync.Map
+
+// ChatRequest é o corpo aceito por POST /v1/chat.
+type ChatRequest struct {
+	ConversationID string                  `json:"conversationId"`
+	Message        string                  `json:"message"`
+	Agent          string                  `json:"agent,omitempty"`
+	Caller         orchestrator.CallerInfo `json:"caller"`
+	Context        json.RawMessage         `json:"context,omitempty"`
+}
+
+// chatEmitter recebe os eventos da resposta: delta, link, handoff, done e error.
+type chatEmitter func(event string, data interface{})
+
+// chatCallID mapeia o ID da conversa do parceiro para o call_id em aiVoice_calls.
+func chatCallID(clientName, conversationID string) string {
+	if id, err := uuid.Parse(conversationID); err == nil {
+		return id.String()
+	}
+	return uuid.NewSHA1(chatNamespace, []byte(clientName+":"+conversationID)).String()
+}
+
+func handleChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	clientName, err := resolveServerKey(r.Context(), r)
+	if err != nil {
+		http.Error(w, "Invalid or revoked server key", http.StatusUnauthorized)
+		return
+	}
+	if err := configCache.CheckClientActive(r.Context(), clientName); err != nil {
+		code, message := clientErrorInfo(err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"code": code, "message": message})
+		return
+	}
+
+	var req ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.ConversationID = strings.TrimSpace(req.ConversationID)
+	req.Message = strings.TrimSpace(req.Message)
+	if req.ConversationID == "" || len(req.ConversationID) > maxConversationIDLen {
+		http.Error(w, fmt.Sprintf("conversationId is required (max %d chars)", maxConversationIDLen), http.StatusBadRequest)
+		return
+	}
+	if req.Message == "" || len(req.Message) > maxChatMessageLen {
+		http.Error(w, fmt.Sprintf("message is required (max %d chars)", maxChatMessageLen), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	s, release, err := newChatSession(r.Context(), clientName, req)
+	switch {
+	case errors.Is(err, errChatBusy):
+		http.Error(w, "Conversation has a message in progress", http.StatusConflict)
+		return
+	case errors.Is(err, errConversationNotFound):
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	case err != nil:
+		log.Printf("❌ Chat API [%s]: erro carregando a conversa: %v", clientName, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer release()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Nginx/Traefik não devem segurar o stream
+	w.WriteHeader(http.StatusOK)
+
+	emit := func(event string, data interface{}) {
+		b, _ := json.Marshal(data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+		flusher.Flush()
+	}
+
+	log.Printf("💬 Chat API [%s]: mensagem na conversa %s (Client: %s)", s.ID, req.ConversationID, clientName)
+	if err := s.runChatTurn(req.Message, emit); err != nil {
+		log.Printf("⚠️ Chat API [%s]: %v", s.ID, err)
+		code, message := "chat_failed", "Não foi possível responder agora. Tente novamente em instantes."
+		switch {
+		case errors.Is(err, orchestrator.ErrClientSuspended) || errors.Is(err, orchestrator.ErrClientNotFound):
+			code, message = clientErrorInfo(err)
+		case errors.Is(err, errNoModelAvailable):
+			code = "model_unavailable"
+		}
+		emit("error", map[string]string{"code": code, "message": message})
+		return
+	}
+
+	s.TranscriptLock.Lock()
+	terminated, agent := s.ShouldTerm, s.AgentName
+	s.TranscriptLock.Unlock()
+	emit("done", map[string]interface{}{
+		"conversationId": req.ConversationID,
+		"callId":         s.ID,
+		"agent":          agent,
+		"terminated":     terminated,
+	})
+}
+
+// newChatSession monta a sessão de uma mensagem da API de chat com o estado salvo da conversa.
+// release libera a conversa para a próxima mensagem.
+func newChatSession(ctx context.Context, clientName string, req ChatRequest) (*Session, func(), error) {
+	callID := chatCallID(clientName, req.ConversationID)
+	if _, busy := activeChats.LoadOrStore(callID, true); busy {
+		return nil, nil, errChatBusy
+	}
+
+	sessCtx, cancel := context.WithTimeout(ctx, chatTurnTimeout)
+	release := func() {
+		cancel()
+		activeChats.Delete(callID)
+	}
+	s := &Session{
+		ID:         callID,
+		ClientName: clientName,
+		Context:    sessCtx,
+		Cancel:     cancel,
+		AgentName:  req.Agent,
+		ToGemini:   make(chan []byte, 64),
+		ToClient:   make(chan []byte, 64),
+		StartTime:  time.Now(),
+		Transcript: []map[string]interface{}{},
+		Status:     "Active",
+		Modality:   orchestrator.ModalityText,
+		Caller:     req.Caller,
+
+		geminiReady: make(chan struct{}),
+	}
+	if err := s.loadConversation(sessCtx); err != nil {
+		release()
+		return nil, nil, err
+	}
+	if len(req.Context) > 0 {
+		callCtx, dropped := orchestrator.ParseCallContext(req.Context, configCache.ContextFields(sessCtx, clientName))
+		if len(dropped) > 0 {
+			log.Printf("⚠️ Contexto [%s]: campos ignorados (fora da allowlist ou inválidos): %v", s.ID, dropped)
+		}
+		s.CallContext = callCtx
+	}
+	return s, release, nil
+}
+
+// loadConversation recarrega o estado salvo da conversa (transcrição, agente, tokens e consumo por chave).
+// Conversa inexistente não é erro: a primeira mensagem cria a chamada no próximo sync.
+func (s *Session) loadConversation(ctx context.Context) error {
+	if db == nil {
+		return nil
+	}
+	var (
+		owner      string
+		transcript []map[string]interface{}
+		rawContext []byte
+	)
+	err := db.QueryRow(ctx, `
+		SELECT COALESCE(cl.name, ''), COALESCE(c.transcript, '[]'::jsonb), COALESCE(c.agents, '{}'),
+		       COALESCE(c.input_tokens, 0), COALESCE(c.output_tokens, 0), COALESCE(c.escalated, false),
+		       COALESCE(c.config_version, 0), COALESCE(c.experiment_id, 0), COALESCE(c.experiment_variant, ''),
+		       COALESCE(c.caller_context, '{}'::jsonb), c.created_at
+		FROM aiVoice_calls c
+		LEFT JOIN aiVoice_clients cl ON c.client_id = cl.id
+		WHERE c.call_id = $1`, s.ID).Scan(
+		&owner, &transcript, &s.Agents,
+		&s.InputTokens, &s.OutputTokens, &s.Escalated,
+		&s.ConfigVersion, &s.ExperimentID, &s.Variant,
+		&rawContext, &s.StartTime)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if owner != s.ClientName {
+		return errConversationNotFound
+	}
+	if transcript != nil {
+		s.Transcript = transcript
+	}
+	if s.AgentName == "" && len(s.Agents) > 0 {
+		s.AgentName = s.Agents[len(s.Agents)-1] // Continua com o agente que assumiu a conversa (handoff)
+	}
+	json.Unmarshal(rawContext, &s.CallContext)
+
+	rows, err := db.Query(ctx, `
+		SELECT key_id, owner, input_tokens, output_tokens
+		FROM aiVoice_key_usage
+		WHERE call_id = $1`, s.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	s.KeyUsage = map[string]*KeyUsage{}
+	for rows.Next() {
+		var u KeyUsage
+		if err := rows.Scan(&u.KeyID, &u.Owner, &u.InputTokens, &u.OutputTokens); err == nil {
+			s.KeyUsage[u.KeyID] = &u
+		}
+	}
+	return rows.Err()
+}
+
+// historyTurns converte a transcrição salva nos turnos enviados ao Gemini como contexto.
+func historyTurns(transcript []map[string]interface{}) []protocol.Turn {
+	var turns []protocol.Turn
+	for _, entry := range transcript {
+		text, _ := entry["text"].(string)
+		if text == "" {
+			continue
+		}
+		switch entry["role"] {
+		case "user":
+			turns = append(turns, protocol.Turn{Role: "user", Parts: []protocol.Part{{Text: text}}})
+		case "agent":
+			turns = append(turns, protocol.Turn{Role: "model", Parts: []protocol.Part{{Text: text}}})
+		}
+	}
+	return turns
+}
+
+// runChatTurn abre a sessão com o Gemini, envia o histórico e a mensagem e repassa a resposta ao emit
+// até o fim do turno, executando as ferramentas como no /ws. A conversa é sincronizada no final.
+func (s *Session) runChatTurn(message string, emit chatEmitter) error {
+	ctx := s.Context
+
+	setupPayload, cfg, err := orchestrator.GetInitialSetup(ctx, configCache, s.setupRequest(s.AgentName))
+	if errors.Is(err, orchestrator.ErrClientSuspended) || errors.Is(err, orchestrator.ErrClientNotFound) {
+		return err
+	}
+	if err != nil {
+		log.Printf("⚠️ Erro no setup: %v", err)
+	}
+	s.applyAgentConfig(cfg)
+	s.TranscriptLock.Lock()
+	s.AlertSent = true // Sem aviso de tempo: a conversa não tem a duração de uma chamada
+	s.TranscriptLock.Unlock()
+
+	conn, model, key, err := connectGemini(ctx, s.ID, s.ClientName, s.Modality, setupPayload)
+	if err != nil {
+		return err
+	}
+	s.geminiLock.Lock()
+	s.GeminiConn = conn
+	s.geminiLock.Unlock()
+	defer s.closeGemini()
+	// Só conversas que chegaram ao Gemini são gravadas; falhas antes disso deixam a conversa intacta
+	defer s.persistChatTurn()
+	s.TranscriptLock.Lock()
+	s.Model = model
+	s.TranscriptLock.Unlock()
+	s.useAPIKey(key)
+	close(s.geminiReady)
+
+	// Histórico da conversa + mensagem nova num único clientContent
+	s.TranscriptLock.Lock()
+	turns := historyTurns(s.Transcript)
+	s.TurnUserText = message
+	s.TranscriptLock.Unlock()
+	turns = append(turns, protocol.Turn{Role: "user", Parts: []protocol.Part{{Text: message}}})
+	b, _ := json.Marshal(protocol.ClientMessage{
+		ClientContent: &protocol.ClientContent{Turns: turns, TurnComplete: true},
+	})
+	s.ToGemini <- b
+
+	stop := make(chan struct{})
+	defer close(stop)
+	frames := make(chan protocol.ServerMessage)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			conn := s.gemini()
+			_, raw, err := conn.ReadMessage()
+			if err != nil {
+				if conn != s.gemini() && ctx.Err() == nil {
+					continue // Handoff: passa a ler da nova conexão
+				}
+				readErr <- fmt.Errorf("Gemini Read error: %w", err)
+				return
+			}
+			var serverMsg protocol.ServerMessage
+			if err := json.Unmarshal(raw, &serverMsg); err != nil {
+				continue
+			}
+			select {
+			case frames <- serverMsg:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	toolDone := make(chan struct{}, 64)
+	pending := 0
+	turnComplete := false
+	var grace <-chan time.Time
+	for {
+		select {
+		case serverMsg := <-frames:
+			if serverMsg.UsageMetadata != nil {
+				s.recordUsage(serverMsg.UsageMetadata.PromptTokenCount, serverMsg.UsageMetadata.CandidatesTokenCount)
+			}
+			if serverMsg.ToolCall != nil {
+				for _, fc := range serverMsg.ToolCall.FunctionCalls {
+					pending++
+					turnComplete, grace = false, nil
+					s.ToolWG.Add(1)
+					go func(fc protocol.FunctionCall) {
+						defer s.ToolWG.Done()
+						s.handleToolCall(fc)
+						toolDone <- struct{}{}
+					}(fc)
+				}
+			}
+			if sc := serverMsg.ServerContent; sc != nil {
+				if sc.ModelTurn != nil {
+					grace = nil
+					for _, p := range sc.ModelTurn.Parts {
+						if p.Text != "" && !p.Thought {
+							emit("delta", map[string]string{"text": p.Text})
+						}
+					}
+				}
+				s.processServerContent(sc)
+				if sc.TurnComplete {
+					turnComplete = true
+					if pending == 0 {
+						s.drainChatFrames(emit)
+						return nil
+					}
+				}
+			}
+		case <-toolDone:
+			pending--
+			if pending == 0 && turnComplete {
+				// A resposta da ferramenta pode ou não gerar mais fala (ex.: sendLink é SILENT)
+				grace = time.After(chatToolGrace)
+			}
+		case <-grace:
+			s.drainChatFrames(emit)
+			return nil
+		case msg := <-s.ToGemini:
+			conn := s.gemini()
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil && conn == s.gemini() {
+				return fmt.Errorf("Gemini Write error: %w", err)
+			}
+		case frame := <-s.ToClient:
+			emitChatFrame(frame, emit)
+		case err := <-readErr:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// drainChatFrames repassa os eventos que as ferramentas deixaram para o cliente (links, handoff).
+func (s *Session) drainChatFrames(emit chatEmitter) {
+	for {
+		select {
+		case frame := <-s.ToClient:
+			emitChatFrame(frame, emit)
+		default:
+			return
+		}
+	}
+}
+
+// emitChatFrame traduz os frames do widget nos eventos da API de chat.
+func emitChatFrame(frame []byte, emit chatEmitter) {
+	var msg struct {
+		Type    string          `json:"type"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(frame, &msg); err != nil {
+		return
+	}
+	switch msg.Type {
+	case "link_bubble":
+		emit("link", msg.Payload)
+	case "agent_handoff":
+		emit("handoff", msg.Payload)
+	}
+}
+
+// persistChatTurn grava o turno (inclusive falas incompletas) e sincroniza a conversa com o dashboard.
+// É o único sync das sessões da API de chat e termina antes de a conversa aceitar outra mensagem.
+func (s *Session) persistChatTurn() {
+	s.TranscriptLock.Lock()
+	now := time.Now().Format(time.RFC3339)
+	if s.TurnUserText != "" {
+		s.Transcript = append(s.Transcript, map[string]interface{}{
+			"id": uuid.New().String()[:8], "role": "user", "text": s.TurnUserText, "timestamp": now,
+		})
+		s.TurnUserText = ""
+	}
+	if s.TurnAgentText != "" {
+		s.Transcript = append(s.Transcript, map[string]interface{}{
+			"id": uuid.New().String()[:8], "role": "agent", "text": s.TurnAgentText, "timestamp": now,
+		})
+		s.TurnAgentText = ""
+	}
+	snap := s.snapshot()
+	terminated := s.ShouldTerm
+	identity := orchestrator.CallerIdentity(s.Caller, s.CallContext)
+	s.TranscriptLock.Unlock()
+
+	s.sync(snap)
+	if terminated {
+		// Conversa encerrada pelo agente: atualiza a memória do cliente recorrente, como no fim de uma chamada
+		go updateCallerMemory(s.ClientName, identity, snap)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"aivoice-v3/internal/orchestrator"
+	"aivoice-v3/internal/protocol"
+)
+
+// handleEscalation abre um ticket de atendimento humano no dashboard-server
+// e marca a chamada como escalonada.
+func (s *Session) handleEscalation(fc protocol.FunctionCall) {
+	reason, _ := fc.Args["reason"].(string)
+	summary, _ := fc.Args["summary"].(string)
+	log.Printf("🙋 Tool: transferir_para_humano [%s] (Sessão: %s)", reason, s.ID)
+
+	contact := map[string]string{}
+	for _, key := range []string{"contact_name", "contact_phone", "contact_email"} {
+		if v, _ := fc.Args[key].(string); v != "" {
+			contact[key[len("contact_"):]] = v
+		}
+	}
+
+	s.TranscriptLock.Lock()
+	callCtx := callContextOrNil(s.CallContext)
+	s.TranscriptLock.Unlock()
+
+	ticketID, err := createEscalation(s.ID, s.ClientName, reason, summary, contact, callCtx)
+	if err != nil {
+		log.Printf("❌ Erro ao criar escalonamento [%s]: %v", s.ID, err)
+		s.respondTool(fc, map[string]interface{}{
+			"status":  "error",
+			"message": "Não foi possível registrar a transferência agora. Peça desculpas e ofereça outra forma de contato.",
+		})
+		return
+	}
+
+	s.TranscriptLock.Lock()
+	s.Escalated = true
+	s.TranscriptLock.Unlock()
+
+	s.respondTool(fc, map[string]interface{}{
+		"status":   "success",
+		"ticketId": ticketID,
+		"message":  "Transferência registrada. Informe ao usuário que um atendente humano dará continuidade.",
+	})
+}
+
+func createEscalation(callID, clientName, reason, summary string, contact map[string]string, callCtx *orchestrator.CallContext) (int, error) {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"callId":     callID,
+		"clientName": clientName,
+		"reason":     reason,
+		"summary":    summary,
+		"contact":    contact,
+		"context":    callCtx, // Repassado ao webhook de escalonamento do cliente
+	})
+
+	req, err := dashboardRequest("POST", "/api/escalations", payload)
+	if err != nil {
+		return 0, err
+	}
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return 0, fmt.Errorf("status: %d", resp.StatusCode)
+	}
+
+	var created struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return 0, err
+	}
+	return created.ID, nil
+}
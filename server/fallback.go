@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"aivoice-v3/internal/keypool"
+	"aivoice-v3/internal/orchestrator"
+	"aivoice-v3/internal/protocol"
+)
+
+// errNoModelAvailable indica que nenhum modelo da lista aceitou o setup.
+var errNoModelAvailable = errors.New("no model available")
+
+// connectGemini abre a sessão com o primeiro modelo do cliente que aceitar o setup.
+// Cota esgotada põe a chave em cooldown só para aquele modelo (a cota do Gemini é por modelo) e chave
+// recusada tira a chave de rotação; em ambos os casos tenta outra chave do pool e, sem chave livre para
+// o modelo, passa ao próximo com a mesma chave. As demais falhas (modelo indisponível, setup recusado)
+// passam direto para o próximo modelo.
+func connectGemini(ctx context.Context, sessionID, clientName, modality string, setup *protocol.Setup) (*websocket.Conn, string, keypool.Key, error) {
+	clientKey := clientAPIKey(ctx, clientName)
+	dialed := false
+	for i, model := range configCache.Models(ctx, clientName, modality) {
+		for {
+			key, err := apiKeys.AcquireFor(clientKey, model)
+			if err != nil {
+				log.Printf("⚠️ Nenhuma chave Gemini disponível [%s] para %s com o modelo %s", sessionID, clientName, model)
+				break
+			}
+			dialed = true
+			attempt := *setup
+			attempt.Model = model
+			conn, err := dialGeminiWithSetup(geminiURL(key), &attempt)
+			if err == nil {
+				if i > 0 {
+					log.Printf("🔁 Fallback [%s]: sessão aberta com o modelo %s", sessionID, model)
+				}
+				return conn, model, key, nil
+			}
+			reason, cooldown, perModel := classifyGeminiError(err)
+			log.Printf("⚠️ Modelo %s indisponível [%s] com a chave %s: %s", model, sessionID, key.ID, reason)
+			if cooldown == 0 {
+				break
+			}
+			coolDownKey(key, model, cooldown, perModel)
+		}
+	}
+	if !dialed {
+		log.Printf("❌ Nenhuma chave Gemini disponível [%s] para %s", sessionID, clientName)
+		return nil, "", keypool.Key{}, keypool.ErrNoKey
+	}
+	return nil, "", keypool.Key{}, errNoModelAvailable
+}
+
+// classifyGeminiError resume o motivo da falha (o close frame traz o código e a mensagem da API),
+// diz por quanto tempo a chave deve sair de rotação (0 = o problema não é da chave) e se o cooldown
+// vale só para o modelo tentado (cota) ou para a chave inteira (permissão).
+func classifyGeminiError(err error) (string, time.Duration, bool) {
+	var apiErr *orchestrator.APIError
+	if errors.As(err, &apiErr) {
+		return classifyAPIError(apiErr)
+	}
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		return err.Error(), 0, false
+	}
+	text := strings.ToLower(closeErr.Text)
+	switch {
+	case strings.Contains(text, "quota") || strings.Contains(text, "resource_exhausted") || strings.Contains(text, "rate limit"):
+		return fmt.Sprintf("cota esgotada (close %d: %s)", closeErr.Code, closeErr.Text), keyQuotaCooldown, true
+	case strings.Contains(text, "api key") || strings.Contains(text, "api_key") || strings.Contains(text, "permission") || strings.Contains(text, "unauthenticated"):
+		return fmt.Sprintf("chave recusada (close %d: %s)", closeErr.Code, closeErr.Text), keyPermissionCooldown, false
+	}
+	return fmt.Sprintf("setup recusado (close %d: %s)", closeErr.Code, closeErr.Text), 0, false
+}
+
+// classifyAPIError faz a mesma classificação para as chamadas REST (generateContent), pelo status HTTP
+// e pelo status da API no corpo do erro.
+func classifyAPIError(apiErr *orchestrator.APIError) (string, time.Duration, bool) {
+	message := strings.ToLower(apiErr.Message)
+	switch {
+	case apiErr.StatusCode == 429 || apiErr.Status == "RESOURCE_EXHAUSTED":
+		return fmt.Sprintf("cota esgotada (%d: %s)", apiErr.StatusCode, apiErr.Message), keyQuotaCooldown, true
+	case apiErr.StatusCode == 401 || apiErr.StatusCode == 403 || apiErr.Status == "PERMISSION_DENIED" ||
+		apiErr.Status == "UNAUTHENTICATED" || strings.Contains(message, "api key"):
+		return fmt.Sprintf("chave recusada (%d: %s)", apiErr.StatusCode, apiErr.Message), keyPermissionCooldown, false
+	}
+	return fmt.Sprintf("requisição recusada (%d: %s)", apiErr.StatusCode, apiErr.Message), 0, false
+}
+
+// coolDownKey tira a chave de rotação só para o modelo (cota) ou por inteiro (permissão).
+func coolDownKey(key keypool.Key, model string, cooldown time.Duration, perModel bool) {
+	if perModel {
+		apiKeys.CooldownModel(key.ID, model, cooldown)
+		log.Printf("🧊 Chave %s em cooldown por %s para o modelo %s", key.ID, cooldown, model)
+	} else {
+		apiKeys.Cooldown(key.ID, cooldown)
+		log.Printf("🧊 Chave %s em cooldown por %s", key.ID, cooldown)
+	}
+}
@@ -3,6 +3,8 @@ module aivoice-v3
 go 1.24.12
 
 require (
+	aivoice-shared v0.0.0
+	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/websocket v1.5.3
 	github.com/jackc/pgx/v5 v5.8.0
@@ -19,3 +21,5 @@ require (
 	golang.org/x/sys v0.0.0-20211110154304-99a53858aa08 // indirect
 	golang.org/x/text v0.29.0 // indirect
 )
+
+replace aivoice-shared => ../shared
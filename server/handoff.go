@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"aivoice-v3/internal/orchestrator"
+	"aivoice-v3/internal/protocol"
+)
+
+// geminiSetupTimeout limita a espera pelo setupComplete (setup inicial, fallback de modelo e handoff).
+const geminiSetupTimeout = 10 * time.Second
+
+// gemini retorna a conexão atual com o Gemini (pode mudar durante um handoff).
+func (s *Session) gemini() *websocket.Conn {
+	s.geminiLock.Lock()
+	defer s.geminiLock.Unlock()
+	return s.GeminiConn
+}
+
+// closeGemini fecha a conexão atual com o Gemini, se o setup já a abriu.
+func (s *Session) closeGemini() {
+	if conn := s.gemini(); conn != nil {
+		conn.Close()
+	}
+}
+
+// applyAgentConfig aplica à sessão os limites e dados do agente carregado no setup.
+func (s *Session) applyAgentConfig(cfg *orchestrator.AIConfig) {
+	if cfg == nil {
+		return
+	}
+	s.TranscriptLock.Lock()
+	defer s.TranscriptLock.Unlock()
+
+	s.DurationLimit = cfg.DurationLimit
+	s.TerminationAlertTime = cfg.TerminationAlertTime
+	s.AlertInstruction = cfg.ProactiveAlertInstruction
+	s.KBCategories = cfg.KBCategories
+	if s.ConfigVersion == 0 {
+		s.ConfigVersion = cfg.Version
+	}
+	if s.ExperimentID == 0 && cfg.ExperimentID != 0 {
+		s.ExperimentID, s.Variant = cfg.ExperimentID, cfg.Variant
+	}
+	if cfg.AgentName != "" {
+		s.AgentName = cfg.AgentName
+		if len(s.Agents) == 0 || s.Agents[len(s.Agents)-1] != cfg.AgentName {
+			s.Agents = append(s.Agents, cfg.AgentName)
+		}
+	}
+}
+
+// setupRequest descreve a sessão para o GetInitialSetup do agente informado.
+func (s *Session) setupRequest(agentName string) orchestrator.SetupRequest {
+	return orchestrator.SetupRequest{
+		ClientName: s.ClientName,
+		AgentName:  agentName,
+		CallID:     s.ID,
+		Caller:     s.Caller,
+		Page:       s.Page,
+		Context:    s.CallContext,
+		Modality:   s.Modality,
+	}
+}
+
+// callContextOrNil omite o contexto vazio na sincronização com o dashboard.
+func callContextOrNil(c orchestrator.CallContext) *orchestrator.CallContext {
+	if c.IsEmpty() {
+		return nil
+	}
+	return &c
+}
+
+func (s *Session) kbCategories() []string {
+	s.TranscriptLock.Lock()
+	defer s.TranscriptLock.Unlock()
+	return s.KBCategories
+}
+
+// handleAgentTransfer troca o agente no meio da chamada: abre uma nova sessão Gemini com a
+// persona do agente de destino e o resumo da conversa, e só então substitui a conexão atual.
+func (s *Session) handleAgentTransfer(fc protocol.FunctionCall) {
+	target, _ := fc.Args["agent"].(string)
+	summary, _ := fc.Args["summary"].(string)
+
+	s.TranscriptLock.Lock()
+	from := s.AgentName
+	s.TranscriptLock.Unlock()
+
+	if target == "" || target == from {
+		s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Informe um agente de destino diferente do atual."})
+		return
+	}
+
+	agents, err := configCache.Agents(s.Context, s.ClientName)
+	if err != nil {
+		log.Printf("❌ Handoff [%s]: erro listando agentes: %v", s.ID, err)
+		s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Não foi possível transferir agora. Continue o atendimento."})
+		return
+	}
+	found := false
+	for _, a := range agents {
+		if a.Name == target {
+			found = true
+			break
+		}
+	}
+	if !found {
+		s.respondTool(fc, map[string]interface{}{"status": "error", "message": fmt.Sprintf("Agente '%s' não existe.", target)})
+		return
+	}
+
+	setup, cfg, err := orchestrator.GetInitialSetup(s.Context, configCache, s.setupRequest(target))
+	if err != nil {
+		log.Printf("❌ Handoff [%s]: erro carregando agente %s: %v", s.ID, target, err)
+		s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Não foi possível transferir agora. Continue o atendimento."})
+		return
+	}
+	orchestrator.WithHandoffContext(setup, from, summary)
+
+	newConn, model, key, err := connectGemini(s.Context, s.ID, s.ClientName, s.Modality, setup)
+	if err != nil {
+		log.Printf("❌ Handoff [%s]: erro abrindo sessão do agente %s: %v", s.ID, target, err)
+		s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Não foi possível transferir agora. Continue o atendimento."})
+		return
+	}
+
+	log.Printf("🔀 Handoff [%s]: %s -> %s", s.ID, from, target)
+
+	// Troca a conexão; o reader/writer passam a usar a nova ao detectar a troca
+	s.geminiLock.Lock()
+	oldConn := s.GeminiConn
+	s.GeminiConn = newConn
+	s.geminiLock.Unlock()
+	oldConn.Close()
+
+	s.applyAgentConfig(cfg)
+	s.useAPIKey(key)
+
+	s.TranscriptLock.Lock()
+	s.Model = model
+	if s.TurnAgentText != "" {
+		s.Transcript = append(s.Transcript, map[string]interface{}{
+			"id": uuid.New().String()[:8], "role": "agent", "text": s.TurnAgentText, "timestamp": time.Now().Format(time.RFC3339),
+		})
+		s.TurnAgentText = ""
+	}
+	s.Transcript = append(s.Transcript, map[string]interface{}{
+		"id":        uuid.New().String()[:8],
+		"role":      "system",
+		"event":     "handoff",
+		"text":      fmt.Sprintf("Atendimento transferido de '%s' para '%s'", from, target),
+		"from":      from,
+		"to":        target,
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+	s.TranscriptLock.Unlock()
+
+	// Avisa o widget (ex.: trocar nome/avatar exibido)
+	notice, _ := json.Marshal(map[string]interface{}{
+		"type":    "agent_handoff",
+		"payload": map[string]interface{}{"from": from, "to": target},
+	})
+	s.ToClient <- notice
+
+	// Desperta o novo agente para que ele se apresente e continue de onde parou
+	greeting := protocol.ClientMessage{
+		ClientContent: &protocol.ClientContent{
+			Turns:        []protocol.Turn{{Role: "user", Parts: []protocol.Part{{Text: "SISTEMA: Você acaba de assumir este atendimento. Apresente-se brevemente e continue a conversa."}}}},
+			TurnComplete: true,
+		},
+	}
+	b, _ := json.Marshal(greeting)
+	select {
+	case s.ToGemini <- b:
+	case <-s.Context.Done():
+	}
+}
+
+// dialGeminiWithSetup abre uma nova conexão, envia o setup e aguarda o setupComplete.
+func dialGeminiWithSetup(geminiURL string, setup *protocol.Setup) (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(geminiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	b, _ := json.Marshal(protocol.ClientMessage{Setup: setup})
+	if err := conn.WriteMessage(websocket.TextMessage, b); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(geminiSetupTimeout))
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		var serverMsg protocol.ServerMessage
+		if err := json.Unmarshal(message, &serverMsg); err == nil && serverMsg.SetupComplete != nil {
+			break
+		}
+	}
+	conn.SetReadDeadline(time.Time{})
+	return conn, nil
+}
@@ -0,0 +1,68 @@
+// Package channel connects messaging platforms to the agent's text chat. Each adapter parses the
+// platform's inbound webhook into Messages and delivers the agent's replies through a Sender.
+package channel
+
+import (
+	"context"
+	"strings"
+)
+
+// Message types understood by the adapters.
+const (
+	TypeText  = "text"
+	TypeAudio = "audio" // voice notes and audio files; transcribed before reaching the agent
+)
+
+// Message is an inbound user message normalized across channels.
+type Message struct {
+	ID        string // platform message ID, used to drop redeliveries
+	AccountID string // the business account/number that received the message
+	From      string // the user's address on the platform (e.g. WhatsApp ID)
+	Name      string // the user's profile name, when the platform shares it
+	Type      string // TypeText, TypeAudio or the platform's own type for unsupported messages
+	Text      string
+	MediaID   string
+	MimeType  string
+}
+
+// Sender delivers text replies on a channel.
+type Sender interface {
+	SendText(ctx context.Context, accountID, to, text string) error
+}
+
+// MediaFetcher downloads media attached to inbound messages.
+type MediaFetcher interface {
+	FetchMedia(ctx context.Context, mediaID string) (data []byte, mimeType string, err error)
+}
+
+// SplitText breaks text into chunks of at most limit bytes, preferring paragraph, line and word
+// boundaries, for platforms that cap the size of a message.
+func SplitText(text string, limit int) []string {
+	var chunks []string
+	text = strings.TrimSpace(text)
+	for len(text) > limit {
+		cut := strings.LastIndex(text[:limit], "\n\n")
+		if cut <= 0 {
+			cut = strings.LastIndex(text[:limit], "\n")
+		}
+		if cut <= 0 {
+			cut = strings.LastIndex(text[:limit], " ")
+		}
+		if cut <= 0 {
+			cut = limit
+			for cut > 0 && !utf8Start(text[cut]) {
+				cut--
+			}
+		}
+		chunks = append(chunks, strings.TrimSpace(text[:cut]))
+		text = strings.TrimSpace(text[cut:])
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
+
+func utf8Start(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,257 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultGraphURL is the Meta Graph API base used by the WhatsApp Cloud API.
+	DefaultGraphURL = "https://graph.facebook.com/v21.0"
+	// SignatureHeader carries the HMAC-SHA256 of the webhook body, keyed by the app secret.
+	SignatureHeader = "X-Hub-Signature-256"
+	// WhatsAppTextLimit is the maximum length of a WhatsApp text message body.
+	WhatsAppTextLimit = 4096
+	// MaxMediaBytes caps media downloads (WhatsApp voice notes are far smaller).
+	MaxMediaBytes = 16 << 20
+)
+
+// VerifySignature checks the X-Hub-Signature-256 header ("sha256=<hex>") against the raw body.
+func VerifySignature(appSecret string, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok || appSecret == "" {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// VerifyChallenge answers Meta's webhook subscription check (GET hub.mode=subscribe). It returns
+// the challenge to echo back and whether the verify token matched.
+func VerifyChallenge(q url.Values, verifyToken string) (string, bool) {
+	if verifyToken == "" || q.Get("hub.mode") != "subscribe" {
+		return "", false
+	}
+	if !hmac.Equal([]byte(q.Get("hub.verify_token")), []byte(verifyToken)) {
+		return "", false
+	}
+	return q.Get("hub.challenge"), true
+}
+
+// ConversationID maps a WhatsApp conversation (business number + user) to a chat conversation ID.
+func ConversationID(phoneNumberID, waID string) string {
+	return "whatsapp:" + phoneNumberID + ":" + waID
+}
+
+type whatsAppWebhook struct {
+	Object string `json:"object"`
+	Entry  []struct {
+		Changes []struct {
+			Field string `json:"field"`
+			Value struct {
+				Metadata struct {
+					PhoneNumberID string `json:"phone_number_id"`
+				} `json:"metadata"`
+				Contacts []struct {
+					WaID    string `json:"wa_id"`
+					Profile struct {
+						Name string `json:"name"`
+					} `json:"profile"`
+				} `json:"contacts"`
+				Messages []struct {
+					ID   string `json:"id"`
+					From string `json:"from"`
+					Type string `json:"type"`
+					Text struct {
+						Body string `json:"body"`
+					} `json:"text"`
+					Audio struct {
+						ID       string `json:"id"`
+						MimeType string `json:"mime_type"`
+					} `json:"audio"`
+					Button struct {
+						Text string `json:"text"`
+					} `json:"button"`
+					Interactive struct {
+						ButtonReply struct {
+							Title string `json:"title"`
+						} `json:"button_reply"`
+						ListReply struct {
+							Title string `json:"title"`
+						} `json:"list_reply"`
+					} `json:"interactive"`
+				} `json:"messages"`
+			} `json:"value"`
+		} `json:"changes"`
+	} `json:"entry"`
+}
+
+// ParseWhatsAppWebhook extracts the user messages from a WhatsApp Cloud API webhook body.
+// Status updates (sent, delivered, read) carry no messages and yield an empty slice.
+func ParseWhatsAppWebhook(body []byte) ([]Message, error) {
+	var hook whatsAppWebhook
+	if err := json.Unmarshal(body, &hook); err != nil {
+		return nil, err
+	}
+	if hook.Object != "whatsapp_business_account" {
+		return nil, fmt.Errorf("unexpected webhook object %q", hook.Object)
+	}
+	var out []Message
+	for _, entry := range hook.Entry {
+		for _, change := range entry.Changes {
+			if change.Field != "messages" {
+				continue
+			}
+			v := change.Value
+			names := map[string]string{}
+			for _, c := range v.Contacts {
+				names[c.WaID] = c.Profile.Name
+			}
+			for _, m := range v.Messages {
+				msg := Message{
+					ID:        m.ID,
+					AccountID: v.Metadata.PhoneNumberID,
+					From:      m.From,
+					Name:      names[m.From],
+					Type:      m.Type,
+				}
+				switch m.Type {
+				case "text":
+					msg.Text = m.Text.Body
+				case "audio":
+					msg.MediaID, msg.MimeType = m.Audio.ID, m.Audio.MimeType
+				case "button":
+					msg.Type, msg.Text = TypeText, m.Button.Text
+				case "interactive":
+					msg.Type, msg.Text = TypeText, m.Interactive.ButtonReply.Title
+					if msg.Text == "" {
+						msg.Text = m.Interactive.ListReply.Title
+					}
+				}
+				out = append(out, msg)
+			}
+		}
+	}
+	return out, nil
+}
+
+// GraphClient talks to the WhatsApp Cloud API with a business access token. It implements Sender
+// and MediaFetcher; BaseURL can point to a local fake Graph API for testing.
+type GraphClient struct {
+	BaseURL string
+	Token   string
+	Client  *http.Client
+}
+
+// NewGraphClient builds a GraphClient; an empty baseURL means DefaultGraphURL.
+func NewGraphClient(baseURL, token string) *GraphClient {
+	if baseURL == "" {
+		baseURL = DefaultGraphURL
+	}
+	return &GraphClient{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   token,
+		Client:  &http.Client{Timeout: 20 * time.Second},
+	}
+}
+
+// SendText sends a text message from the business number accountID to the user.
+func (g *GraphClient) SendText(ctx context.Context, accountID, to, text string) error {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                to,
+		"type":              "text",
+		"text":              map[string]interface{}{"body": text, "preview_url": true},
+	})
+	req, err := http.NewRequestWithContext(ctx, "POST", g.BaseURL+"/"+url.PathEscape(accountID)+"/messages", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := g.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// FetchMedia resolves a media ID to its download URL and downloads it.
+func (g *GraphClient) FetchMedia(ctx context.Context, mediaID string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", g.BaseURL+"/"+url.PathEscape(mediaID), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := g.do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	var media struct {
+		URL      string `json:"url"`
+		MimeType string `json:"mime_type"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&media)
+	resp.Body.Close()
+	if err != nil {
+		return nil, "", err
+	}
+	if media.URL == "" {
+		return nil, "", errors.New("graph: media has no download URL")
+	}
+
+	req, err = http.NewRequestWithContext(ctx, "GET", media.URL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err = g.do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxMediaBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > MaxMediaBytes {
+		return nil, "", fmt.Errorf("graph: media larger than %d bytes", MaxMediaBytes)
+	}
+	return data, media.MimeType, nil
+}
+
+// do sends an authenticated request and turns non-2xx answers into errors with the API message.
+func (g *GraphClient) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", "Bearer "+g.Token)
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+				Code    int    `json:"code"`
+			} `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&apiErr)
+		return nil, fmt.Errorf("graph: status %d (code %d): %s", resp.StatusCode, apiErr.Error.Code, apiErr.Error.Message)
+	}
+	return resp, nil
+}
@@ -0,0 +1,218 @@
+package channel
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"object":"whatsapp_business_account"}`)
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		want   bool
+	}{
+		{"valid", "app-secret", sign("app-secret", body), true},
+		{"wrong secret", "app-secret", sign("other-secret", body), false},
+		{"tampered body", "app-secret", sign("app-secret", []byte(`{}`)), false},
+		{"missing header", "app-secret", "", false},
+		{"missing prefix", "app-secret", strings.TrimPrefix(sign("app-secret", body), "sha256="), false},
+		{"not hex", "app-secret", "sha256=zz", false},
+		{"no app secret", "", sign("", body), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifySignature(tt.secret, body, tt.header); got != tt.want {
+				t.Errorf("VerifySignature = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyChallenge(t *testing.T) {
+	q := func(mode, token string) url.Values {
+		return url.Values{"hub.mode": {mode}, "hub.verify_token": {token}, "hub.challenge": {"1158201444"}}
+	}
+	tests := []struct {
+		name          string
+		query         url.Values
+		verifyToken   string
+		wantChallenge string
+		wantOK        bool
+	}{
+		{"match", q("subscribe", "tok"), "tok", "1158201444", true},
+		{"wrong token", q("subscribe", "outro"), "tok", "", false},
+		{"wrong mode", q("unsubscribe", "tok"), "tok", "", false},
+		{"token not configured", q("subscribe", ""), "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenge, ok := VerifyChallenge(tt.query, tt.verifyToken)
+			if challenge != tt.wantChallenge || ok != tt.wantOK {
+				t.Errorf("VerifyChallenge = (%q, %v), want (%q, %v)", challenge, ok, tt.wantChallenge, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseWhatsAppWebhook(t *testing.T) {
+	body := []byte(`{
+		"object": "whatsapp_business_account",
+		"entry": [{"changes": [
+			{"field": "messages", "value": {
+				"metadata": {"phone_number_id": "1001"},
+				"contacts": [{"wa_id": "5511999990000", "profile": {"name": "Ana"}}],
+				"messages": [
+					{"id": "wamid.1", "from": "5511999990000", "type": "text", "text": {"body": "Oi"}},
+					{"id": "wamid.2", "from": "5511999990000", "type": "audio", "audio": {"id": "media-1", "mime_type": "audio/ogg; codecs=opus"}},
+					{"id": "wamid.3", "from": "5511999990000", "type": "button", "button": {"text": "Confirmar"}},
+					{"id": "wamid.4", "from": "5511999990000", "type": "interactive", "interactive": {"list_reply": {"title": "Plano anual"}}},
+					{"id": "wamid.5", "from": "5511888880000", "type": "sticker"}
+				]
+			}},
+			{"field": "account_update", "value": {"metadata": {"phone_number_id": "1001"}}}
+		]}]
+	}`)
+	got, err := ParseWhatsAppWebhook(body)
+	if err != nil {
+		t.Fatalf("ParseWhatsAppWebhook: %v", err)
+	}
+	want := []Message{
+		{ID: "wamid.1", AccountID: "1001", From: "5511999990000", Name: "Ana", Type: TypeText, Text: "Oi"},
+		{ID: "wamid.2", AccountID: "1001", From: "5511999990000", Name: "Ana", Type: TypeAudio, MediaID: "media-1", MimeType: "audio/ogg; codecs=opus"},
+		{ID: "wamid.3", AccountID: "1001", From: "5511999990000", Name: "Ana", Type: TypeText, Text: "Confirmar"},
+		{ID: "wamid.4", AccountID: "1001", From: "5511999990000", Name: "Ana", Type: TypeText, Text: "Plano anual"},
+		{ID: "wamid.5", AccountID: "1001", From: "5511888880000", Type: "sticker"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("message %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseWhatsAppWebhookStatusUpdate(t *testing.T) {
+	body := []byte(`{"object":"whatsapp_business_account","entry":[{"changes":[{"field":"messages","value":{"metadata":{"phone_number_id":"1001"},"statuses":[{"id":"wamid.1","status":"read"}]}}]}]}`)
+	got, err := ParseWhatsAppWebhook(body)
+	if err != nil {
+		t.Fatalf("ParseWhatsAppWebhook: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %+v, want no messages", got)
+	}
+}
+
+func TestParseWhatsAppWebhookErrors(t *testing.T) {
+	for _, body := range []string{`{"object":"page","entry":[]}`, `{not json`} {
+		if _, err := ParseWhatsAppWebhook([]byte(body)); err == nil {
+			t.Errorf("ParseWhatsAppWebhook(%s) = nil error", body)
+		}
+	}
+}
+
+func TestGraphClientSendText(t *testing.T) {
+	var path, auth string
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		path = r.URL.Path
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.Write([]byte(`{"messages":[{"id":"wamid.out"}]}`))
+	}))
+	defer srv.Close()
+
+	g := NewGraphClient(srv.URL+"/", "tok")
+	if err := g.SendText(context.Background(), "1001", "5511999990000", "Olá!"); err != nil {
+		t.Fatalf("SendText: %v", err)
+	}
+	if path != "/1001/messages" {
+		t.Errorf("path = %q", path)
+	}
+	if auth != "Bearer tok" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if got["to"] != "5511999990000" || got["type"] != "text" || got["messaging_product"] != "whatsapp" {
+		t.Errorf("body = %v", got)
+	}
+	if text, _ := got["text"].(map[string]interface{}); text["body"] != "Olá!" {
+		t.Errorf("text = %v", got["text"])
+	}
+}
+
+func TestGraphClientSendTextAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"message":"Invalid OAuth access token","code":190}}`))
+	}))
+	defer srv.Close()
+
+	err := NewGraphClient(srv.URL, "expired").SendText(context.Background(), "1001", "5511999990000", "Olá!")
+	if err == nil {
+		t.Fatal("expected an error for status 401")
+	}
+	for _, want := range []string{"401", "code 190", "Invalid OAuth access token"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q missing %q", err, want)
+		}
+	}
+}
+
+func TestGraphClientFetchMedia(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("%s: Authorization = %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/media-1":
+			json.NewEncoder(w).Encode(map[string]string{"url": srv.URL + "/download/media-1", "mime_type": "audio/ogg"})
+		case "/download/media-1":
+			w.Write([]byte("OggS-audio"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	data, mimeType, err := NewGraphClient(srv.URL, "tok").FetchMedia(context.Background(), "media-1")
+	if err != nil {
+		t.Fatalf("FetchMedia: %v", err)
+	}
+	if string(data) != "OggS-audio" || mimeType != "audio/ogg" {
+		t.Errorf("FetchMedia = (%q, %q)", data, mimeType)
+	}
+}
+
+func TestGraphClientFetchMediaWithoutURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"mime_type":"audio/ogg"}`))
+	}))
+	defer srv.Close()
+
+	if _, _, err := NewGraphClient(srv.URL, "tok").FetchMedia(context.Background(), "media-1"); err == nil {
+		t.Fatal("expected an error for media without a download URL")
+	}
+}
@@ -0,0 +1,265 @@
+// Package keypool selects the Gemini API key each session connects with: a pool of platform keys
+// (round-robin or by remaining per-minute quota) plus the clients' own keys (BYOK), with cooldowns
+// for keys Gemini rejected: per model for quota errors (Gemini quotas are per model), for the whole
+// key on permission errors.
+package keypool
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Owners of a key, recorded with its usage for billing.
+const (
+	OwnerPlatform = "platform"
+	OwnerClient   = "client"
+)
+
+// Selection strategies for platform keys.
+const (
+	RoundRobin = "round_robin"
+	Quota      = "quota" // most remaining tokens in the current minute (GEMINI_KEY_TPM)
+)
+
+// ErrNoKey is returned when every eligible key is missing or cooling down.
+var ErrNoKey = errors.New("no Gemini API key available")
+
+// Key is an API key with a stable, non-secret ID used in logs and usage records.
+type Key struct {
+	ID     string
+	Owner  string
+	Secret string
+}
+
+// Fingerprint identifies a secret without revealing it.
+func Fingerprint(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:4])
+}
+
+// ClientKey wraps a client's own key.
+func ClientKey(clientName, secret string) Key {
+	return Key{ID: "client:" + clientName + ":" + Fingerprint(secret), Owner: OwnerClient, Secret: secret}
+}
+
+type entry struct {
+	key            Key
+	cooldownUntil  time.Time
+	modelCooldowns map[string]time.Time // quota cooldowns by model
+	minute         time.Time            // start of the current usage window
+	minuteTokens   int
+}
+
+// available reports whether the key may be used now, for model when given.
+func (e *entry) available(model string, now time.Time) bool {
+	if now.Before(e.cooldownUntil) {
+		return false
+	}
+	return model == "" || !now.Before(e.modelCooldowns[model])
+}
+
+func (e *entry) usedThisMinute(now time.Time) int {
+	if now.Sub(e.minute) >= time.Minute {
+		return 0
+	}
+	return e.minuteTokens
+}
+
+// Pool hands out keys to new Gemini sessions. It is safe for concurrent use.
+type Pool struct {
+	mu       sync.Mutex
+	strategy string
+	quota    int // tokens per minute per platform key; 0 = unknown
+	platform []*entry
+	clients  map[string]*entry // BYOK keys by ID, kept to remember their cooldowns
+	next     int
+	clock    func() time.Time // time.Now; replaced in tests
+}
+
+// New creates a pool with the given platform keys.
+func New(keys []Key, strategy string, tokensPerMinute int) *Pool {
+	p := &Pool{strategy: strategy, quota: tokensPerMinute, clients: map[string]*entry{}, clock: time.Now}
+	seen := map[string]bool{}
+	for _, k := range keys {
+		if k.Secret == "" || seen[k.ID] {
+			continue
+		}
+		seen[k.ID] = true
+		k.Owner = OwnerPlatform
+		p.platform = append(p.platform, &entry{key: k})
+	}
+	return p
+}
+
+// FromEnv builds the platform pool from GEMINI_API_KEYS (comma-separated, each "label=key" or just
+// "key"), falling back to GEMINI_API_KEY. GEMINI_KEY_STRATEGY picks round_robin (default) or quota,
+// and GEMINI_KEY_TPM sets the per-key tokens-per-minute quota used by the quota strategy.
+func FromEnv() *Pool {
+	raw := os.Getenv("GEMINI_API_KEYS")
+	if raw == "" {
+		raw = os.Getenv("GEMINI_API_KEY")
+	}
+	var keys []Key
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		label, secret, ok := strings.Cut(item, "=")
+		if !ok {
+			label, secret = "", item
+		}
+		secret = strings.TrimSpace(secret)
+		id := strings.TrimSpace(label)
+		if id == "" {
+			id = "key-" + Fingerprint(secret)
+		}
+		keys = append(keys, Key{ID: id, Secret: secret})
+	}
+	strategy := os.Getenv("GEMINI_KEY_STRATEGY")
+	if strategy != Quota {
+		strategy = RoundRobin
+	}
+	tpm, _ := strconv.Atoi(os.Getenv("GEMINI_KEY_TPM"))
+	return New(keys, strategy, tpm)
+}
+
+// Len returns the number of platform keys.
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.platform)
+}
+
+// Strategy returns the platform key selection strategy.
+func (p *Pool) Strategy() string {
+	return p.strategy
+}
+
+// Acquire returns the key for a new session. A client key, when given, is used exclusively:
+// sessions of a BYOK client never fall back to platform keys.
+func (p *Pool) Acquire(clientKey *Key) (Key, error) {
+	return p.AcquireFor(clientKey, "")
+}
+
+// AcquireFor is Acquire for a specific model, also skipping keys whose quota for that model is cooling down.
+func (p *Pool) AcquireFor(clientKey *Key, model string) (Key, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+
+	if clientKey != nil {
+		e := p.clients[clientKey.ID]
+		if e == nil {
+			e = &entry{key: *clientKey}
+			p.clients[clientKey.ID] = e
+		}
+		if !e.available(model, now) {
+			return Key{}, ErrNoKey
+		}
+		return e.key, nil
+	}
+
+	var best *entry
+	bestRemaining := 0
+	for i := range p.platform {
+		e := p.platform[(p.next+i)%len(p.platform)]
+		if !e.available(model, now) {
+			continue
+		}
+		if p.strategy != Quota {
+			best = e
+			break
+		}
+		remaining := -e.usedThisMinute(now)
+		if p.quota > 0 {
+			remaining += p.quota
+			if remaining <= 0 {
+				continue
+			}
+		}
+		if best == nil || remaining > bestRemaining {
+			best, bestRemaining = e, remaining
+		}
+	}
+	if best == nil {
+		return Key{}, ErrNoKey
+	}
+	p.next = (p.indexOf(best) + 1) % len(p.platform)
+	return best.key, nil
+}
+
+func (p *Pool) now() time.Time {
+	return p.clock()
+}
+
+func (p *Pool) indexOf(e *entry) int {
+	for i, x := range p.platform {
+		if x == e {
+			return i
+		}
+	}
+	return 0
+}
+
+func (p *Pool) lookup(id string) *entry {
+	for _, e := range p.platform {
+		if e.key.ID == id {
+			return e
+		}
+	}
+	return p.clients[id]
+}
+
+// Cooldown takes the key out of rotation for d.
+func (p *Pool) Cooldown(id string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e := p.lookup(id); e != nil {
+		e.cooldownUntil = p.now().Add(d)
+	}
+}
+
+// CooldownModel takes the key out of rotation for d, only for sessions on model.
+func (p *Pool) CooldownModel(id, model string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.lookup(id)
+	if e == nil {
+		return
+	}
+	now := p.now()
+	if e.modelCooldowns == nil {
+		e.modelCooldowns = map[string]time.Time{}
+	}
+	for m, until := range e.modelCooldowns {
+		if !now.Before(until) {
+			delete(e.modelCooldowns, m)
+		}
+	}
+	e.modelCooldowns[model] = now.Add(d)
+}
+
+// Record adds tokens consumed with the key to its current per-minute window.
+func (p *Pool) Record(id string, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.lookup(id)
+	if e == nil {
+		return
+	}
+	now := p.now()
+	if now.Sub(e.minute) >= time.Minute {
+		e.minute, e.minuteTokens = now, 0
+	}
+	e.minuteTokens += tokens
+}
@@ -0,0 +1,235 @@
+package keypool
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock drives the pool's cooldowns and usage windows without sleeping.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestPool(strategy string, tpm int, ids ...string) (*Pool, *fakeClock) {
+	var keys []Key
+	for _, id := range ids {
+		keys = append(keys, Key{ID: id, Secret: "secret-" + id})
+	}
+	p := New(keys, strategy, tpm)
+	clock := &fakeClock{now: time.Date(2025, 3, 10, 14, 0, 0, 0, time.UTC)}
+	p.clock = func() time.Time { return clock.now }
+	return p, clock
+}
+
+// acquireIDs acquires n keys for model and returns their IDs ("-" for ErrNoKey).
+func acquireIDs(t *testing.T, p *Pool, model string, n int) []string {
+	t.Helper()
+	var ids []string
+	for i := 0; i < n; i++ {
+		k, err := p.AcquireFor(nil, model)
+		switch {
+		case errors.Is(err, ErrNoKey):
+			ids = append(ids, "-")
+		case err != nil:
+			t.Fatalf("AcquireFor: %v", err)
+		default:
+			ids = append(ids, k.ID)
+		}
+	}
+	return ids
+}
+
+func equalIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNew(t *testing.T) {
+	p := New([]Key{
+		{ID: "a", Secret: "sa", Owner: OwnerClient},
+		{ID: "a", Secret: "outra"},
+		{ID: "vazia"},
+		{ID: "b", Secret: "sb"},
+	}, RoundRobin, 0)
+	if p.Len() != 2 {
+		t.Fatalf("Len = %d, want 2 (duplicate and empty keys dropped)", p.Len())
+	}
+	k, err := p.Acquire(nil)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if k.ID != "a" || k.Secret != "sa" || k.Owner != OwnerPlatform {
+		t.Errorf("Acquire = %+v, want the first key a owned by the platform", k)
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(p *Pool)
+		want    []string
+	}{
+		{"cycles in order", func(p *Pool) {}, []string{"a", "b", "c", "a", "b"}},
+		{"skips a key cooling down", func(p *Pool) { p.Cooldown("b", time.Minute) }, []string{"a", "c", "a", "c"}},
+		{"skips a key cooling down for the model", func(p *Pool) { p.CooldownModel("a", "m1", time.Minute) }, []string{"b", "c", "b", "c"}},
+		{"ignores a cooldown for another model", func(p *Pool) { p.CooldownModel("a", "m2", time.Minute) }, []string{"a", "b", "c", "a"}},
+		{"no key left", func(p *Pool) {
+			for _, id := range []string{"a", "b", "c"} {
+				p.Cooldown(id, time.Minute)
+			}
+		}, []string{"-", "-"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := newTestPool(RoundRobin, 0, "a", "b", "c")
+			tt.prepare(p)
+			if got := acquireIDs(t, p, "m1", len(tt.want)); !equalIDs(got, tt.want) {
+				t.Errorf("keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaStrategy(t *testing.T) {
+	tests := []struct {
+		name  string
+		tpm   int
+		usage map[string]int
+		want  string
+	}{
+		{"most remaining tokens", 1000, map[string]int{"a": 500, "b": 100, "c": 300}, "b"},
+		{"unused key first", 1000, map[string]int{"a": 10, "b": 20}, "c"},
+		{"exhausted keys skipped", 1000, map[string]int{"a": 1000, "b": 1200, "c": 999}, "c"},
+		{"every key exhausted", 1000, map[string]int{"a": 1000, "b": 1000, "c": 1000}, "-"},
+		{"unknown quota picks least used", 0, map[string]int{"a": 5000, "b": 9000, "c": 7000}, "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := newTestPool(Quota, tt.tpm, "a", "b", "c")
+			for id, tokens := range tt.usage {
+				p.Record(id, tokens)
+			}
+			if got := acquireIDs(t, p, "m1", 1)[0]; got != tt.want {
+				t.Errorf("key = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaWindowResets(t *testing.T) {
+	p, clock := newTestPool(Quota, 1000, "a")
+	p.Record("a", 1000)
+	if got := acquireIDs(t, p, "m1", 1)[0]; got != "-" {
+		t.Fatalf("key = %s, want none while the quota is used up", got)
+	}
+	clock.advance(time.Minute)
+	if got := acquireIDs(t, p, "m1", 1)[0]; got != "a" {
+		t.Errorf("key = %s, want a once the minute window is over", got)
+	}
+}
+
+func TestModelCooldown(t *testing.T) {
+	p, clock := newTestPool(RoundRobin, 0, "a", "b")
+	p.CooldownModel("a", "m1", time.Minute)
+	p.CooldownModel("b", "m1", 2*time.Minute)
+
+	steps := []struct {
+		after time.Duration
+		model string
+		want  []string
+	}{
+		// Every key is cooling down for m1 but still free for m2 and for model-agnostic callers
+		{0, "m1", []string{"-"}},
+		{0, "m2", []string{"a", "b"}},
+		{0, "", []string{"a", "b"}},
+		// a's cooldown ends first, then b's joins the rotation again
+		{time.Minute, "m1", []string{"a", "a"}},
+		{time.Minute, "m1", []string{"b", "a"}},
+	}
+	for i, st := range steps {
+		clock.advance(st.after)
+		if got := acquireIDs(t, p, st.model, len(st.want)); !equalIDs(got, st.want) {
+			t.Errorf("step %d (%q): keys = %v, want %v", i, st.model, got, st.want)
+		}
+	}
+}
+
+func TestKeyCooldownCoversEveryModel(t *testing.T) {
+	p, clock := newTestPool(RoundRobin, 0, "a")
+	p.Cooldown("a", 15*time.Minute)
+	for _, model := range []string{"m1", "m2", ""} {
+		if got := acquireIDs(t, p, model, 1)[0]; got != "-" {
+			t.Errorf("model %q: key = %s, want none", model, got)
+		}
+	}
+	clock.advance(15 * time.Minute)
+	if got := acquireIDs(t, p, "m1", 1)[0]; got != "a" {
+		t.Errorf("key = %s, want a after the cooldown", got)
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	p, clock := newTestPool(RoundRobin, 0, "a")
+	ck := ClientKey("acme", "AIza-acme")
+
+	k, err := p.AcquireFor(&ck, "m1")
+	if err != nil || k.ID != ck.ID || k.Owner != OwnerClient {
+		t.Fatalf("AcquireFor(client) = %+v, %v", k, err)
+	}
+
+	// A cooling client key never falls back to the platform pool, and the cooldown is remembered
+	p.CooldownModel(ck.ID, "m1", time.Minute)
+	if _, err := p.AcquireFor(&ck, "m1"); !errors.Is(err, ErrNoKey) {
+		t.Errorf("AcquireFor(client, m1) = %v, want ErrNoKey", err)
+	}
+	if k, err := p.AcquireFor(&ck, "m2"); err != nil || k.ID != ck.ID {
+		t.Errorf("AcquireFor(client, m2) = %+v, %v", k, err)
+	}
+	clock.advance(time.Minute)
+	if _, err := p.AcquireFor(&ck, "m1"); err != nil {
+		t.Errorf("AcquireFor(client, m1) after cooldown = %v", err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("GEMINI_API_KEYS", "principal=AIza-1, AIza-2 ,,reserva= AIza-3")
+	t.Setenv("GEMINI_API_KEY", "ignorada")
+	t.Setenv("GEMINI_KEY_STRATEGY", "quota")
+	t.Setenv("GEMINI_KEY_TPM", "250000")
+
+	p := FromEnv()
+	if p.Len() != 3 || p.Strategy() != Quota || p.quota != 250000 {
+		t.Fatalf("pool = %d keys, %s, %d tpm", p.Len(), p.Strategy(), p.quota)
+	}
+	want := []Key{
+		{ID: "principal", Secret: "AIza-1"},
+		{ID: "key-" + Fingerprint("AIza-2"), Secret: "AIza-2"},
+		{ID: "reserva", Secret: "AIza-3"},
+	}
+	for i, w := range want {
+		if got := p.platform[i].key; got.ID != w.ID || got.Secret != w.Secret {
+			t.Errorf("key %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestFromEnvFallbacks(t *testing.T) {
+	t.Setenv("GEMINI_API_KEYS", "")
+	t.Setenv("GEMINI_API_KEY", "AIza-unica")
+	t.Setenv("GEMINI_KEY_STRATEGY", "desconhecida")
+
+	p := FromEnv()
+	if p.Len() != 1 || p.Strategy() != RoundRobin {
+		t.Errorf("pool = %d keys, %s; want the single GEMINI_API_KEY with round_robin", p.Len(), p.Strategy())
+	}
+}
@@ -0,0 +1,109 @@
+// Package notify sends outbound notifications (email, SMS) on behalf of the agent.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Message is a rendered notification ready to be delivered.
+type Message struct {
+	To      string
+	Subject string // ignored by SMS senders
+	Body    string
+}
+
+// Sender delivers a message through one channel ("email" or "sms").
+type Sender interface {
+	Channel() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// TemplateData holds the values available to notification templates.
+type TemplateData struct {
+	ClientName string
+	CallID     string
+	Name       string
+	Message    string
+	Code       string
+	When       string
+}
+
+// Template is a per-client notification template stored in aiVoice_notification_templates.
+type Template struct {
+	Name    string
+	Subject string
+	Body    string
+}
+
+// LoadTemplate fetches a template by client, channel and name.
+func LoadTemplate(ctx context.Context, db *pgxpool.Pool, clientName, channel, name string) (*Template, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database unavailable")
+	}
+	var t Template
+	err := db.QueryRow(ctx, `
+		SELECT t.name, COALESCE(t.subject, ''), t.body
+		FROM aiVoice_notification_templates t
+		JOIN aiVoice_clients cl ON t.client_id = cl.id
+		WHERE cl.name = $1 AND t.channel = $2 AND t.name = $3`, clientName, channel, name).Scan(&t.Name, &t.Subject, &t.Body)
+	if err != nil {
+		return nil, fmt.Errorf("template %s/%s not found: %w", channel, name, err)
+	}
+	return &t, nil
+}
+
+// Render executes the template's subject and body with data.
+func (t *Template) Render(to string, data TemplateData) (Message, error) {
+	subject, err := render(t.Subject, data)
+	if err != nil {
+		return Message{}, err
+	}
+	body, err := render(t.Body, data)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{To: to, Subject: subject, Body: body}, nil
+}
+
+func render(text string, data TemplateData) (string, error) {
+	tpl, err := template.New("notification").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// execer is the subset of *pgxpool.Pool used to write the delivery log.
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// LogDelivery records the delivery attempt against the call in aiVoice_notifications.
+func LogDelivery(ctx context.Context, db *pgxpool.Pool, callID, clientName, channel, templateName, to string, sendErr error) error {
+	if db == nil {
+		return nil
+	}
+	return logDelivery(ctx, db, callID, clientName, channel, templateName, to, sendErr)
+}
+
+func logDelivery(ctx context.Context, db execer, callID, clientName, channel, templateName, to string, sendErr error) error {
+	status, errText := "sent", ""
+	if sendErr != nil {
+		status, errText = "failed", sendErr.Error()
+	}
+	_, err := db.Exec(ctx, `
+		INSERT INTO aiVoice_notifications (call_id, client_id, channel, template, recipient, status, error)
+		VALUES ($1, (SELECT id FROM aiVoice_clients WHERE name = $2), $3, $4, $5, $6, $7)`,
+		callID, clientName, channel, templateName, to, status, errText)
+	return err
+}
@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestRender(t *testing.T) {
+	tpl := &Template{
+		Name:    "confirmacao",
+		Subject: "Reserva {{.Code}}",
+		Body:    "Olá {{.Name}}, sua reserva para {{.When}} está confirmada.{{if .Message}} {{.Message}}{{end}}",
+	}
+	msg, err := tpl.Render("ana@example.com", TemplateData{Name: "Ana", Code: "ABC123", When: "10/03 às 14:00"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if msg.To != "ana@example.com" {
+		t.Errorf("To = %q", msg.To)
+	}
+	if msg.Subject != "Reserva ABC123" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+	if want := "Olá Ana, sua reserva para 10/03 às 14:00 está confirmada."; msg.Body != want {
+		t.Errorf("Body = %q, want %q", msg.Body, want)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	// Sintaxe inválida e campo inexistente falham em vez de enviar texto quebrado
+	for _, body := range []string{"Olá {{.Name", "Olá {{.Unknown}}"} {
+		tpl := &Template{Name: "x", Body: body}
+		if _, err := tpl.Render("ana@example.com", TemplateData{Name: "Ana"}); err == nil {
+			t.Errorf("Render(%q) = nil error", body)
+		}
+	}
+}
+
+type execCall struct {
+	sql  string
+	args []any
+}
+
+type fakeExecer struct {
+	calls []execCall
+	err   error
+}
+
+func (f *fakeExecer) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	f.calls = append(f.calls, execCall{sql: sql, args: args})
+	return pgconn.CommandTag{}, f.err
+}
+
+func TestLogDelivery(t *testing.T) {
+	tests := []struct {
+		name       string
+		sendErr    error
+		wantStatus string
+		wantError  string
+	}{
+		{"sent", nil, "sent", ""},
+		{"failed", errors.New("sms provider status: 500"), "failed", "sms provider status: 500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeExecer{}
+			if err := logDelivery(context.Background(), db, "call-1", "acme", "sms", "lembrete", "+5511999990000", tt.sendErr); err != nil {
+				t.Fatalf("logDelivery: %v", err)
+			}
+			if len(db.calls) != 1 {
+				t.Fatalf("got %d inserts, want 1", len(db.calls))
+			}
+			call := db.calls[0]
+			if !strings.Contains(call.sql, "INSERT INTO aiVoice_notifications") {
+				t.Errorf("unexpected statement: %s", call.sql)
+			}
+			want := []any{"call-1", "acme", "sms", "lembrete", "+5511999990000", tt.wantStatus, tt.wantError}
+			if len(call.args) != len(want) {
+				t.Fatalf("args = %v, want %v", call.args, want)
+			}
+			for i := range want {
+				if call.args[i] != want[i] {
+					t.Errorf("arg %d = %v, want %v", i, call.args[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLogDeliveryPropagatesDBError(t *testing.T) {
+	db := &fakeExecer{err: errors.New("connection refused")}
+	if err := logDelivery(context.Background(), db, "call-1", "acme", "email", "t", "a@b.c", nil); err == nil {
+		t.Fatal("expected the insert error")
+	}
+}
+
+func TestLogDeliveryWithoutDB(t *testing.T) {
+	if err := LogDelivery(context.Background(), nil, "call-1", "acme", "email", "t", "a@b.c", nil); err != nil {
+		t.Fatalf("LogDelivery(nil db) = %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// HTTPSMSSender posts SMS messages as JSON ({"from","to","message"}) to a generic provider endpoint.
+type HTTPSMSSender struct {
+	URL    string
+	Token  string
+	From   string
+	Client *http.Client
+}
+
+// NewHTTPSMSSenderFromEnv builds an HTTPSMSSender from SMS_* variables, or returns nil if SMS_API_URL is unset.
+func NewHTTPSMSSenderFromEnv() *HTTPSMSSender {
+	endpoint := os.Getenv("SMS_API_URL")
+	if endpoint == "" {
+		return nil
+	}
+	return &HTTPSMSSender{
+		URL:    endpoint,
+		Token:  os.Getenv("SMS_API_TOKEN"),
+		From:   os.Getenv("SMS_FROM"),
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *HTTPSMSSender) Channel() string { return "sms" }
+
+func (s *HTTPSMSSender) Send(ctx context.Context, msg Message) error {
+	payload, _ := json.Marshal(map[string]string{
+		"from":    s.From,
+		"to":      msg.To,
+		"message": msg.Body,
+	})
+	req, err := http.NewRequestWithContext(ctx, "POST", s.URL, bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sms provider status: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPSMSSenderSend(t *testing.T) {
+	var got map[string]string
+	var auth, contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		auth = r.Header.Get("Authorization")
+		contentType = r.Header.Get("Content-Type")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	s := &HTTPSMSSender{URL: srv.URL, Token: "tok", From: "AIVOICE", Client: srv.Client()}
+	if err := s.Send(context.Background(), Message{To: "+5511999990000", Subject: "ignorado", Body: "Sua reserva ABC123"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if auth != "Bearer tok" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+	want := map[string]string{"from": "AIVOICE", "to": "+5511999990000", "message": "Sua reserva ABC123"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("body[%q] = %q, want %q", k, got[k], v)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("body = %v, want %v", got, want)
+	}
+}
+
+func TestHTTPSMSSenderWithoutToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h := r.Header.Get("Authorization"); h != "" {
+			t.Errorf("Authorization = %q, want none", h)
+		}
+	}))
+	defer srv.Close()
+
+	s := &HTTPSMSSender{URL: srv.URL, Client: srv.Client()}
+	if err := s.Send(context.Background(), Message{To: "+5511999990000", Body: "oi"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+}
+
+func TestHTTPSMSSenderProviderError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	s := &HTTPSMSSender{URL: srv.URL, Client: srv.Client()}
+	if err := s.Send(context.Background(), Message{To: "+5511999990000", Body: "oi"}); err == nil {
+		t.Fatal("expected an error for status 429")
+	}
+}
+
+func TestHTTPSMSSenderContextCanceled(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	s := &HTTPSMSSender{URL: srv.URL, Client: srv.Client()}
+	if err := s.Send(ctx, Message{To: "+5511999990000", Body: "oi"}); err == nil {
+		t.Fatal("expected the context deadline to abort the request")
+	}
+}
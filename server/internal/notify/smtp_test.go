@@ -0,0 +1,149 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpMessage is what the fake server received in one session.
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// startSMTPServer runs a minimal in-process SMTP server (no AUTH, no STARTTLS) that accepts one
+// message per connection and reports it on the returned channel.
+func startSMTPServer(t *testing.T) (host, port string, received <-chan smtpMessage) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan smtpMessage, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, ch)
+		}
+	}()
+	host, port, _ = net.SplitHostPort(ln.Addr().String())
+	return host, port, ch
+}
+
+func serveSMTP(conn net.Conn, ch chan<- smtpMessage) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	var msg smtpMessage
+	reply("220 localhost ESMTP test")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.to = append(msg.to, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			msg.data = data.String()
+			reply("250 OK")
+			ch <- msg
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPSenderSend(t *testing.T) {
+	host, port, received := startSMTPServer(t)
+	s := &SMTPSender{Host: host, Port: port, From: "no-reply@aivoice.local"}
+
+	err := s.Send(context.Background(), Message{To: "ana@example.com", Subject: "Reserva ABC123", Body: "Olá Ana, até amanhã."})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	select {
+	case msg := <-received:
+		if msg.from != "no-reply@aivoice.local" {
+			t.Errorf("MAIL FROM = %q", msg.from)
+		}
+		if len(msg.to) != 1 || msg.to[0] != "ana@example.com" {
+			t.Errorf("RCPT TO = %v", msg.to)
+		}
+		for _, want := range []string{
+			"From: no-reply@aivoice.local\r\n",
+			"To: ana@example.com\r\n",
+			"Subject: Reserva ABC123\r\n",
+			"Content-Type: text/plain; charset=UTF-8\r\n",
+			"\r\n\r\nOlá Ana, até amanhã.",
+		} {
+			if !strings.Contains(msg.data, want) {
+				t.Errorf("message missing %q:\n%s", want, msg.data)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP server received nothing")
+	}
+}
+
+func TestSMTPSenderRejectsHeaderInjection(t *testing.T) {
+	s := &SMTPSender{Host: "127.0.0.1", Port: "1", From: "no-reply@aivoice.local"}
+	for _, msg := range []Message{
+		{To: "ana@example.com\r\nBcc: todos@example.com", Subject: "oi", Body: "x"},
+		{To: "ana@example.com", Subject: "oi\nBcc: todos@example.com", Body: "x"},
+	} {
+		if err := s.Send(context.Background(), msg); err == nil || !strings.Contains(err.Error(), "invalid header") {
+			t.Errorf("Send(%q, %q) = %v, want invalid header", msg.To, msg.Subject, err)
+		}
+	}
+}
+
+func TestSMTPSenderServerDown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close()
+
+	s := &SMTPSender{Host: host, Port: port, From: "no-reply@aivoice.local"}
+	if err := s.Send(context.Background(), Message{To: "ana@example.com", Subject: "oi", Body: "x"}); err == nil {
+		t.Fatal("expected a connection error")
+	}
+}
@@ -0,0 +1,12 @@
+package orchestrator
+
+import "context"
+
+// APIKey returns the client's own Gemini API key (BYOK), or "" when the client uses the platform pool.
+func (c *ConfigCache) APIKey(ctx context.Context, clientName string) string {
+	cfg, err := c.Get(ctx, clientName)
+	if err != nil || cfg == nil {
+		return ""
+	}
+	return cfg.APIKey
+}
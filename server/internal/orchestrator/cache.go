@@ -0,0 +1,300 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/sync/singleflight"
+)
+
+// ConfigChannel is the Postgres NOTIFY channel dashboard-server uses to announce config changes.
+// The payload is the client name; an empty payload invalidates every client.
+const ConfigChannel = "aivoice_config"
+
+const (
+	// configMaxAge bounds how long an entry is trusted without a notification (safety net for missed NOTIFYs).
+	configMaxAge = 10 * time.Minute
+	// listenRetryDelay is the wait before re-opening the LISTEN connection after a failure.
+	listenRetryDelay = 5 * time.Second
+	// configLoadTimeout bounds a load, which runs detached from the callers waiting on it.
+	configLoadTimeout = 15 * time.Second
+)
+
+// ClientConfig is everything needed to build a client's setup. The settings stored on aiVoice_clients
+// come from a single row scan (loadClientRow); agents, categories, templates and experiments from
+// one query each.
+type ClientConfig struct {
+	Agents      []*AIConfig // Default agent first
+	Categories  []string
+	Templates   map[string][]string // Notification template names by channel
+	Experiments []Experiment        // Running experiments
+	// ContextFields is the allowlist for the setup "context" object
+	ContextFields []string
+	Memory        MemoryPolicy
+	Video         VideoPolicy
+	Uploads       UploadPolicy
+	PushToTalk    bool     // Automatic activity detection disabled; the widget sends activity_start/activity_end
+	Models        []string // Primary model then fallbacks, as stored; see ModelList
+	TextModel     string   // Model for text-only sessions (empty = DefaultTextModel)
+	APIKey        string   // Client's own Gemini API key (decrypted); empty = platform key pool
+	LoadedAt      time.Time
+	// TranscriptionModel transcribes voice notes from messaging channels (empty = TRANSCRIPTION_MODEL or
+	// DefaultTranscriptionModel; see TranscriptionModelList)
+	TranscriptionModel string
+}
+
+// Agent returns a copy of the named agent's config, or of the default agent when the name is empty or unknown.
+func (c *ClientConfig) Agent(name string) *AIConfig {
+	if len(c.Agents) == 0 {
+		return nil
+	}
+	selected := c.Agents[0]
+	for _, a := range c.Agents {
+		if a.AgentName == name {
+			selected = a
+			break
+		}
+	}
+	cfg := *selected
+	return &cfg
+}
+
+// AgentList summarizes the client's agents, default agent first.
+func (c *ClientConfig) AgentList() []AgentSummary {
+	agents := make([]AgentSummary, 0, len(c.Agents))
+	for _, a := range c.Agents {
+		agents = append(agents, AgentSummary{Name: a.AgentName, Description: a.AgentDescription})
+	}
+	return agents
+}
+
+type cacheEntry struct {
+	config *ClientConfig
+	stale  bool
+}
+
+// ConfigCache keeps each client's config in memory. Entries are invalidated through Postgres
+// LISTEN/NOTIFY and the last successfully loaded config is kept as a fallback while the database
+// is unreachable.
+type ConfigCache struct {
+	db      *pgxpool.Pool
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	loads   singleflight.Group
+	// Invalidation generations: a load only stores its result when no Invalidate for the client
+	// (gens) or for every client (allGen) arrived while it was reading.
+	gens   map[string]uint64
+	allGen uint64
+}
+
+// NewConfigCache creates a cache backed by db. A nil db yields a cache that never has entries.
+func NewConfigCache(db *pgxpool.Pool) *ConfigCache {
+	return &ConfigCache{db: db, entries: map[string]*cacheEntry{}, gens: map[string]uint64{}}
+}
+
+// Get returns the client's config, loading it when missing, invalidated or too old.
+// ErrClientSuspended and ErrClientNotFound are always returned as-is; other load errors fall back
+// to the last-known-good config when there is one. Concurrent callers share one load; each stops
+// waiting when its own ctx is done.
+func (c *ConfigCache) Get(ctx context.Context, clientName string) (*ClientConfig, error) {
+	if c == nil || c.db == nil {
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	entry := c.entries[clientName]
+	fresh := entry != nil && !entry.stale && time.Since(entry.config.LoadedAt) < configMaxAge
+	c.mu.Unlock()
+	if fresh {
+		return entry.config, nil
+	}
+
+	var res singleflight.Result
+	select {
+	case res = <-c.loads.DoChan(clientName, func() (interface{}, error) { return c.load(clientName) }):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	err := res.Err
+	if err == nil {
+		return res.Val.(*ClientConfig), nil
+	}
+
+	if errors.Is(err, ErrClientSuspended) || errors.Is(err, ErrClientNotFound) {
+		c.mu.Lock()
+		delete(c.entries, clientName)
+		c.mu.Unlock()
+		return nil, err
+	}
+	if entry != nil {
+		log.Printf("⚠️ Config de %s indisponível (%v); usando a última versão válida de %s", clientName, err, entry.config.LoadedAt.Format(time.RFC3339))
+		return entry.config, nil
+	}
+	return nil, err
+}
+
+// load reads the client's config and caches it unless an Invalidate arrived in the meantime (the
+// result would predate the change). It does not use the callers' contexts: one of them giving up
+// must not fail the others waiting on the same load.
+func (c *ConfigCache) load(clientName string) (*ClientConfig, error) {
+	c.mu.Lock()
+	gen := c.generation(clientName)
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), configLoadTimeout)
+	defer cancel()
+	cfg, err := loadClientConfig(ctx, c.db, clientName)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.generation(clientName) == gen {
+		c.entries[clientName] = &cacheEntry{config: cfg}
+	}
+	c.mu.Unlock()
+	return cfg, nil
+}
+
+// generation changes whenever the client's entry is invalidated; c.mu must be held.
+func (c *ConfigCache) generation(clientName string) uint64 {
+	return c.gens[clientName] + c.allGen
+}
+
+// CheckClientActive is the cached counterpart of the package-level CheckClientActive.
+func (c *ConfigCache) CheckClientActive(ctx context.Context, clientName string) error {
+	_, err := c.Get(ctx, clientName)
+	return err
+}
+
+// Agents lists the client's agents, default agent first.
+func (c *ConfigCache) Agents(ctx context.Context, clientName string) ([]AgentSummary, error) {
+	cfg, err := c.Get(ctx, clientName)
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+	return cfg.AgentList(), nil
+}
+
+// ContextFields returns the client's allowlist for the setup "context" object.
+func (c *ConfigCache) ContextFields(ctx context.Context, clientName string) []string {
+	cfg, err := c.Get(ctx, clientName)
+	if err != nil || cfg == nil {
+		return nil
+	}
+	return cfg.ContextFields
+}
+
+// MemoryPolicy returns the client's returning caller memory setting (disabled when unknown).
+func (c *ConfigCache) MemoryPolicy(ctx context.Context, clientName string) MemoryPolicy {
+	cfg, err := c.Get(ctx, clientName)
+	if err != nil || cfg == nil {
+		return MemoryPolicy{}
+	}
+	return cfg.Memory
+}
+
+// PushToTalk reports whether the client's sessions use manual activity signals instead of automatic VAD.
+func (c *ConfigCache) PushToTalk(ctx context.Context, clientName string) bool {
+	cfg, err := c.Get(ctx, clientName)
+	if err != nil || cfg == nil {
+		return false
+	}
+	return cfg.PushToTalk
+}
+
+// DB returns the pool the cache reads from (nil without a database).
+func (c *ConfigCache) DB() *pgxpool.Pool {
+	if c == nil {
+		return nil
+	}
+	return c.db
+}
+
+// Invalidate marks the client's entry as stale; it is kept as last-known-good until reloaded.
+// An empty name invalidates every client.
+func (c *ConfigCache) Invalidate(clientName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if clientName == "" {
+		c.allGen++
+	} else {
+		c.gens[clientName]++
+	}
+	for name, entry := range c.entries {
+		if clientName == "" || name == clientName {
+			entry.stale = true
+		}
+	}
+}
+
+// Listen subscribes to ConfigChannel and invalidates entries as notifications arrive, reconnecting
+// until ctx is done. Everything is invalidated after a reconnect since notifications may have been missed.
+func (c *ConfigCache) Listen(ctx context.Context) {
+	if c == nil || c.db == nil {
+		return
+	}
+	for {
+		err := c.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("⚠️ LISTEN %s interrompido: %v (nova tentativa em %s)", ConfigChannel, err, listenRetryDelay)
+		c.Invalidate("")
+		select {
+		case <-time.After(listenRetryDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *ConfigCache) listen(ctx context.Context) error {
+	conn, err := c.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+ConfigChannel); err != nil {
+		return err
+	}
+	// A fresh subscription may have missed changes made while disconnected
+	c.Invalidate("")
+
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			// The connection may still be subscribed; drop it instead of returning it to the pool
+			conn.Conn().Close(context.Background())
+			return err
+		}
+		c.Invalidate(n.Payload)
+	}
+}
+
+func loadClientConfig(ctx context.Context, db *pgxpool.Pool, clientName string) (*ClientConfig, error) {
+	cfg := &ClientConfig{}
+	if err := loadClientRow(ctx, db, clientName, cfg); err != nil {
+		return nil, err
+	}
+	var err error
+	if cfg.Agents, err = fetchAgentConfigs(ctx, db, clientName); err != nil {
+		return nil, err
+	}
+	if cfg.Categories, err = fetchAllCategories(ctx, db, clientName); err != nil {
+		return nil, err
+	}
+	if cfg.Templates, err = fetchTemplateNames(ctx, db, clientName); err != nil {
+		return nil, err
+	}
+	if cfg.Experiments, err = fetchRunningExperiments(ctx, db, clientName); err != nil {
+		return nil, err
+	}
+	cfg.LoadedAt = time.Now()
+	return cfg, nil
+}
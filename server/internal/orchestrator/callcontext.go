@@ -0,0 +1,116 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxContextValueLen  = 512
+	maxContextCustomKey = 20
+)
+
+var (
+	contextKeyPattern    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,39}$`)
+	contextLocalePattern = regexp.MustCompile(`^[A-Za-z]{2,3}([-_][A-Za-z0-9]{2,8})*$`)
+)
+
+// CallContext is the caller metadata sent by the widget in the setup message. Only fields in the
+// client's allowlist (aiVoice_clients.context_fields) are kept; custom keys are allowlisted as "custom.<key>".
+type CallContext struct {
+	PageURL  string            `json:"pageUrl,omitempty"`
+	Referrer string            `json:"referrer,omitempty"`
+	UserID   string            `json:"userId,omitempty"`
+	Locale   string            `json:"locale,omitempty"`
+	Custom   map[string]string `json:"custom,omitempty"`
+}
+
+// IsEmpty reports whether no field survived validation.
+func (c CallContext) IsEmpty() bool {
+	return c.PageURL == "" && c.Referrer == "" && c.UserID == "" && c.Locale == "" && len(c.Custom) == 0
+}
+
+// ParseCallContext validates the raw setup "context" object against the allowlist. Fields that are
+// not allowlisted or fail validation are dropped and returned by name so the caller can log them.
+func ParseCallContext(raw json.RawMessage, allowlist []string) (CallContext, []string) {
+	var ctx CallContext
+	if len(raw) == 0 || string(raw) == "null" {
+		return ctx, nil
+	}
+	var in struct {
+		PageURL  interface{}            `json:"pageUrl"`
+		Referrer interface{}            `json:"referrer"`
+		UserID   interface{}            `json:"userId"`
+		Locale   interface{}            `json:"locale"`
+		Custom   map[string]interface{} `json:"custom"`
+	}
+	if err := json.Unmarshal(raw, &in); err != nil {
+		return ctx, []string{"context"}
+	}
+
+	allowed := map[string]bool{}
+	for _, f := range allowlist {
+		allowed[f] = true
+	}
+	var dropped []string
+	keep := func(name string, v interface{}, valid func(string) bool) string {
+		if v == nil {
+			return ""
+		}
+		s, ok := contextValue(v)
+		if ok && s == "" {
+			return ""
+		}
+		if !ok || !allowed[name] || (valid != nil && !valid(s)) {
+			dropped = append(dropped, name)
+			return ""
+		}
+		return s
+	}
+
+	ctx.PageURL = keep("pageUrl", in.PageURL, isHTTPURL)
+	ctx.Referrer = keep("referrer", in.Referrer, isHTTPURL)
+	ctx.UserID = keep("userId", in.UserID, nil)
+	ctx.Locale = keep("locale", in.Locale, contextLocalePattern.MatchString)
+
+	for k, v := range in.Custom {
+		name := "custom." + k
+		if !contextKeyPattern.MatchString(k) || len(ctx.Custom) >= maxContextCustomKey {
+			dropped = append(dropped, name)
+			continue
+		}
+		if s := keep(name, v, nil); s != "" {
+			if ctx.Custom == nil {
+				ctx.Custom = map[string]string{}
+			}
+			ctx.Custom[k] = s
+		}
+	}
+	return ctx, dropped
+}
+
+// contextValue converts a JSON scalar to a bounded single-line string; objects and arrays are rejected.
+func contextValue(v interface{}) (string, bool) {
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = t
+	case float64, bool:
+		s = fmt.Sprint(t)
+	default:
+		return "", false
+	}
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > maxContextValueLen {
+		return "", false
+	}
+	return s, true
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,92 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"aivoice-shared/secrets"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	// ErrClientSuspended is returned for clients whose status is not 'active'.
+	ErrClientSuspended = errors.New("client suspended")
+	// ErrClientNotFound is returned when the client was never provisioned (or was deleted).
+	ErrClientNotFound = errors.New("client not found")
+)
+
+// CheckClientActive reports whether the client may open sessions.
+// Without a database the check is skipped so a bare orchestrator still works.
+func CheckClientActive(ctx context.Context, db *pgxpool.Pool, clientName string) error {
+	if db == nil {
+		return nil
+	}
+	var status string
+	err := db.QueryRow(ctx, "SELECT COALESCE(status, 'active') FROM aiVoice_clients WHERE name = $1", clientName).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrClientNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status != "active" {
+		return ErrClientSuspended
+	}
+	return nil
+}
+
+// loadClientRow fills cfg with every per-client setting stored on aiVoice_clients, read in a single
+// query that also checks the client's status:
+//   - Models is the primary model (DefaultModel when unset) followed by the fallbacks; TextModel and
+//     TranscriptionModel may be empty.
+//   - APIKey is the decrypted gemini_api_key (BYOK), empty when the client uses the platform pool.
+func loadClientRow(ctx context.Context, db *pgxpool.Pool, clientName string, cfg *ClientConfig) error {
+	var status, primary, encKey string
+	var fallbacks []string
+	var videoMaxKB int
+	err := db.QueryRow(ctx, `
+		SELECT COALESCE(status, 'active'), COALESCE(context_fields, '{}'),
+			COALESCE(caller_memory_enabled, false), COALESCE(caller_memory_retention_days, 0),
+			COALESCE(push_to_talk, false),
+			COALESCE(video_input, false), COALESCE(video_max_fps, 0), COALESCE(video_max_frame_kb, 0),
+			COALESCE(uploads_enabled, false),
+			COALESCE(model, ''), COALESCE(fallback_models, '{}'), COALESCE(text_model, ''),
+			COALESCE(transcription_model, ''),
+			COALESCE(gemini_api_key, '')
+		FROM aiVoice_clients WHERE name = $1`, clientName).Scan(
+		&status, &cfg.ContextFields,
+		&cfg.Memory.Enabled, &cfg.Memory.RetentionDays,
+		&cfg.PushToTalk,
+		&cfg.Video.Enabled, &cfg.Video.MaxFPS, &videoMaxKB,
+		&cfg.Uploads.Enabled,
+		&primary, &fallbacks, &cfg.TextModel,
+		&cfg.TranscriptionModel,
+		&encKey,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrClientNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status != "active" {
+		return ErrClientSuspended
+	}
+
+	cfg.Video.MaxFrameBytes = videoMaxKB << 10
+	cfg.Video = cfg.Video.withDefaults()
+	if primary == "" {
+		primary = DefaultModel
+	}
+	cfg.Models = append([]string{primary}, fallbacks...)
+	if encKey != "" {
+		key, err := secrets.Decrypt(encKey)
+		if err != nil {
+			return fmt.Errorf("decrypting Gemini API key: %w", err)
+		}
+		cfg.APIKey = key
+	}
+	return nil
+}
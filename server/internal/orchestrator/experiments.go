@@ -0,0 +1,108 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Experiment is a running A/B test over one of the client's agents.
+type Experiment struct {
+	ID        int
+	AgentName string
+	Variants  []Variant
+}
+
+// Variant is one arm of an experiment. Overrides is a partial AIConfig (JSON field names)
+// merged over the agent's config; an empty object keeps the agent's config as-is (control).
+type Variant struct {
+	Name      string
+	Weight    int
+	Overrides json.RawMessage
+}
+
+// Assign picks the variant for a call. The choice depends only on the experiment and the call ID,
+// so reconnects and handoffs within the same call always land on the same variant.
+func (e *Experiment) Assign(callID string) *Variant {
+	total := 0
+	for _, v := range e.Variants {
+		total += v.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d:%s", e.ID, callID)
+	bucket := int(h.Sum32() % uint32(total))
+	for i := range e.Variants {
+		bucket -= e.Variants[i].Weight
+		if bucket < 0 {
+			return &e.Variants[i]
+		}
+	}
+	return nil
+}
+
+// applyExperiment assigns the call to a variant of the agent's running experiment, if any,
+// and merges the variant's overrides into cfg.
+func applyExperiment(cfg *AIConfig, experiments []Experiment, callID string) {
+	if callID == "" {
+		return
+	}
+	for i := range experiments {
+		exp := &experiments[i]
+		if exp.AgentName != cfg.AgentName {
+			continue
+		}
+		v := exp.Assign(callID)
+		if v == nil {
+			return
+		}
+		if len(v.Overrides) > 0 {
+			merged := *cfg
+			if err := json.Unmarshal(v.Overrides, &merged); err == nil {
+				// Overrides never change which agent (or which config version) the call runs
+				merged.AgentName, merged.AgentDescription, merged.Version = cfg.AgentName, cfg.AgentDescription, cfg.Version
+				*cfg = merged
+			}
+		}
+		cfg.ExperimentID = exp.ID
+		cfg.Variant = v.Name
+		return
+	}
+}
+
+func fetchRunningExperiments(ctx context.Context, db *pgxpool.Pool, clientName string) ([]Experiment, error) {
+	if db == nil {
+		return nil, nil
+	}
+	rows, err := db.Query(ctx, `
+		SELECT e.id, e.agent_name, v.name, v.weight, v.overrides
+		FROM aiVoice_experiments e
+		JOIN aiVoice_clients cl ON e.client_id = cl.id
+		JOIN aiVoice_experiment_variants v ON v.experiment_id = e.id
+		WHERE cl.name = $1 AND e.status = 'running'
+		ORDER BY e.id, v.id`, clientName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var experiments []Experiment
+	for rows.Next() {
+		var id int
+		var agent string
+		var v Variant
+		if err := rows.Scan(&id, &agent, &v.Name, &v.Weight, &v.Overrides); err != nil {
+			return nil, err
+		}
+		if n := len(experiments); n == 0 || experiments[n-1].ID != id {
+			experiments = append(experiments, Experiment{ID: id, AgentName: agent})
+		}
+		experiments[len(experiments)-1].Variants = append(experiments[len(experiments)-1].Variants, v)
+	}
+	return experiments, rows.Err()
+}
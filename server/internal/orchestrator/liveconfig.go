@@ -0,0 +1,61 @@
+package orchestrator
+
+import "aivoice-v3/internal/protocol"
+
+// applyLiveConfig maps the agent's native Live API options (thinking, affective dialog, proactivity,
+// sampling, voice activity detection and context window compression) onto the setup message.
+// Zero values leave the model defaults in place.
+func applyLiveConfig(setup *protocol.Setup, cfg *AIConfig) {
+	gen := setup.GenerationConfig
+	gen.TopP = cfg.TopP
+	gen.TopK = cfg.TopK
+	gen.MaxOutputTokens = cfg.MaxOutputTokens
+	gen.EnableAffectiveDialog = cfg.EnableAffectiveDialog
+
+	budget := cfg.ThinkingBudget
+	gen.ThinkingConfig = &protocol.ThinkingConfig{IncludeThoughts: false, ThinkingBudget: &budget}
+
+	if cfg.ProactiveAudio {
+		setup.Proactivity = &protocol.ProactivityConfig{ProactiveAudio: true}
+	}
+
+	vad := &protocol.AutomaticActivityDetection{
+		StartOfSpeechSensitivity: sensitivity("START", cfg.VADStartSensitivity),
+		EndOfSpeechSensitivity:   sensitivity("END", cfg.VADEndSensitivity),
+		PrefixPaddingMs:          cfg.VADPrefixPaddingMs,
+		SilenceDurationMs:        cfg.VADSilenceDurationMs,
+	}
+	if *vad != (protocol.AutomaticActivityDetection{}) {
+		setup.RealtimeInputConfig = &protocol.RealtimeInputConfig{AutomaticActivityDetection: vad}
+	}
+
+	if cfg.ContextCompression {
+		setup.ContextWindowCompression = &protocol.ContextWindowCompression{
+			TriggerTokens: cfg.CompressionTriggerTokens,
+			SlidingWindow: &protocol.SlidingWindow{TargetTokens: cfg.CompressionTargetTokens},
+		}
+	}
+}
+
+// disableActivityDetection switches the session to push-to-talk: the widget marks each turn with
+// activity_start/activity_end, forwarded as realtimeInput activity signals.
+func disableActivityDetection(setup *protocol.Setup) {
+	if setup.RealtimeInputConfig == nil {
+		setup.RealtimeInputConfig = &protocol.RealtimeInputConfig{}
+	}
+	if setup.RealtimeInputConfig.AutomaticActivityDetection == nil {
+		setup.RealtimeInputConfig.AutomaticActivityDetection = &protocol.AutomaticActivityDetection{}
+	}
+	setup.RealtimeInputConfig.AutomaticActivityDetection.Disabled = true
+}
+
+// sensitivity converts "high"/"low" into the Live API enum (e.g. START_SENSITIVITY_HIGH); anything else is unset.
+func sensitivity(prefix, level string) string {
+	switch level {
+	case "high":
+		return prefix + "_SENSITIVITY_HIGH"
+	case "low":
+		return prefix + "_SENSITIVITY_LOW"
+	}
+	return ""
+}
@@ -0,0 +1,251 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"aivoice-v3/internal/protocol"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// DefaultMemoryModel summarizes transcripts into caller memory (override with MEMORY_MODEL).
+	DefaultMemoryModel = "gemini-2.5-flash"
+	// defaultMemoryRetentionDays applies when the client has memory enabled but no retention set.
+	defaultMemoryRetentionDays = 90
+	// maxMemoryItems bounds each list kept in a caller's memory.
+	maxMemoryItems = 10
+	// maxMemoryTranscriptChars bounds the transcript sent to the summarizer (most recent text is kept).
+	maxMemoryTranscriptChars = 20000
+)
+
+// MemoryPolicy is the client's returning caller memory setting (aiVoice_clients.caller_memory_*).
+type MemoryPolicy struct {
+	Enabled       bool
+	RetentionDays int
+}
+
+// CallerMemory is what the agent remembers about a returning caller.
+type CallerMemory struct {
+	Identity   string    `json:"identity"`
+	KeyFacts   []string  `json:"keyFacts"`
+	LastTopic  string    `json:"lastTopic"`
+	OpenIssues []string  `json:"openIssues"`
+	CallCount  int       `json:"callCount"`
+	LastCallID string    `json:"lastCallId"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// CallerIdentity returns the memory key for the session: the host site's authenticated user ID
+// when present, otherwise the caller ID. Empty means the caller is anonymous.
+func CallerIdentity(caller CallerInfo, callCtx CallContext) string {
+	if id := sanitizeCallerValue(callCtx.UserID); id != "" {
+		return "user:" + id
+	}
+	if id := sanitizeCallerValue(caller.ID); id != "" {
+		return "caller:" + id
+	}
+	return ""
+}
+
+func (p MemoryPolicy) retention() int {
+	if p.RetentionDays <= 0 {
+		return defaultMemoryRetentionDays
+	}
+	return p.RetentionDays
+}
+
+// WithCallerMemory appends the caller's memory to the system instruction.
+func WithCallerMemory(setup *protocol.Setup, mem *CallerMemory) {
+	if setup == nil || setup.SystemInstruction == nil || len(setup.SystemInstruction.Parts) == 0 || mem == nil {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n\n---\nMEMÓRIA DO CLIENTE: esta pessoa já falou com você antes (%d atendimento(s), último em %s).", mem.CallCount, mem.UpdatedAt.Format("02/01/2006"))
+	if mem.LastTopic != "" {
+		fmt.Fprintf(&b, "\nÚltimo assunto: %s", mem.LastTopic)
+	}
+	if len(mem.KeyFacts) > 0 {
+		fmt.Fprintf(&b, "\nFatos conhecidos:\n- %s", strings.Join(mem.KeyFacts, "\n- "))
+	}
+	if len(mem.OpenIssues) > 0 {
+		fmt.Fprintf(&b, "\nPendências em aberto:\n- %s", strings.Join(mem.OpenIssues, "\n- "))
+	}
+	b.WriteString("\nUse essas informações com naturalidade para dar continuidade ao atendimento, sem pedir de novo o que já sabe. Não recite a memória para o usuário.")
+	setup.SystemInstruction.Parts[0].Text += b.String()
+}
+
+// LoadCallerMemory returns the caller's memory, or nil when there is none within the retention window.
+func LoadCallerMemory(ctx context.Context, db *pgxpool.Pool, clientName, identity string, policy MemoryPolicy) (*CallerMemory, error) {
+	if db == nil || identity == "" || !policy.Enabled {
+		return nil, nil
+	}
+	mem := &CallerMemory{Identity: identity}
+	err := db.QueryRow(ctx, `
+		SELECT m.key_facts, COALESCE(m.last_topic, ''), m.open_issues, m.call_count, COALESCE(m.last_call_id, ''), m.updated_at
+		FROM aiVoice_caller_memory m
+		JOIN aiVoice_clients cl ON m.client_id = cl.id
+		WHERE cl.name = $1 AND m.identity = $2 AND m.updated_at > NOW() - make_interval(days => $3)`,
+		clientName, identity, policy.retention(),
+	).Scan(&mem.KeyFacts, &mem.LastTopic, &mem.OpenIssues, &mem.CallCount, &mem.LastCallID, &mem.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mem, nil
+}
+
+// SaveCallerMemory upserts the caller's memory and prunes the client's records past the retention window.
+func SaveCallerMemory(ctx context.Context, db *pgxpool.Pool, clientName string, mem *CallerMemory, policy MemoryPolicy) error {
+	if db == nil || mem == nil || mem.Identity == "" {
+		return nil
+	}
+	_, err := db.Exec(ctx, `
+		INSERT INTO aiVoice_caller_memory (client_id, identity, key_facts, last_topic, open_issues, call_count, last_call_id, updated_at)
+		VALUES ((SELECT id FROM aiVoice_clients WHERE name = $1), $2, $3, $4, $5, 1, $6, NOW())
+		ON CONFLICT (client_id, identity) DO UPDATE SET
+			key_facts = EXCLUDED.key_facts,
+			last_topic = EXCLUDED.last_topic,
+			open_issues = EXCLUDED.open_issues,
+			call_count = aiVoice_caller_memory.call_count + 1,
+			last_call_id = EXCLUDED.last_call_id,
+			updated_at = NOW()`,
+		clientName, mem.Identity, nonNil(mem.KeyFacts), mem.LastTopic, nonNil(mem.OpenIssues), mem.LastCallID,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(ctx, `
+		DELETE FROM aiVoice_caller_memory
+		WHERE client_id = (SELECT id FROM aiVoice_clients WHERE name = $1) AND updated_at < NOW() - make_interval(days => $2)`,
+		clientName, policy.retention())
+	return err
+}
+
+// SummarizeCallerMemory asks Gemini to merge the final transcript into the caller's previous memory.
+// previous may be nil for a first call.
+func SummarizeCallerMemory(ctx context.Context, apiKey string, previous *CallerMemory, transcript []map[string]interface{}) (*CallerMemory, error) {
+	var conv strings.Builder
+	for _, m := range transcript {
+		text, _ := m["text"].(string)
+		if text == "" || (m["role"] != "user" && m["role"] != "agent") {
+			continue
+		}
+		role := "Agente"
+		if m["role"] == "user" {
+			role = "Usuário"
+		}
+		fmt.Fprintf(&conv, "%s: %s\n", role, text)
+	}
+	text := conv.String()
+	if strings.TrimSpace(text) == "" {
+		return nil, errors.New("empty transcript")
+	}
+	if len(text) > maxMemoryTranscriptChars {
+		text = strings.ToValidUTF8(text[len(text)-maxMemoryTranscriptChars:], "")
+	}
+
+	prev := "(nenhuma)"
+	if previous != nil {
+		b, _ := json.Marshal(map[string]interface{}{"keyFacts": previous.KeyFacts, "lastTopic": previous.LastTopic, "openIssues": previous.OpenIssues})
+		prev = string(b)
+	}
+	prompt := fmt.Sprintf(`Você mantém a memória de um cliente entre atendimentos.
+Atualize a memória anterior com base na nova conversa:
+- keyFacts: fatos duradouros e úteis sobre o cliente (preferências, produtos, dados já informados). Remova o que a conversa contradisser.
+- lastTopic: uma frase com o assunto principal desta conversa.
+- openIssues: pendências ainda não resolvidas ao fim desta conversa (remova as que foram resolvidas).
+No máximo %d itens por lista. Não inclua senhas, números de cartão ou documentos completos.
+
+Memória anterior: %s
+
+Nova conversa:
+%s`, maxMemoryItems, prev, text)
+
+	model := os.Getenv("MEMORY_MODEL")
+	if model == "" {
+		model = DefaultMemoryModel
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"contents": []map[string]interface{}{{"role": "user", "parts": []map[string]string{{"text": prompt}}}},
+		"generationConfig": map[string]interface{}{
+			"temperature":      0.2,
+			"responseMimeType": "application/json",
+			"responseSchema": map[string]interface{}{
+				"type": "OBJECT",
+				"properties": map[string]interface{}{
+					"keyFacts":   map[string]interface{}{"type": "ARRAY", "items": map[string]string{"type": "STRING"}},
+					"lastTopic":  map[string]interface{}{"type": "STRING"},
+					"openIssues": map[string]interface{}{"type": "ARRAY", "items": map[string]string{"type": "STRING"}},
+				},
+				"required": []string{"keyFacts", "lastTopic", "openIssues"},
+			},
+		},
+	})
+
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", model, apiKey)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("generateContent: status %d", resp.StatusCode)
+	}
+
+	var out struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+		} `json:"candidates"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	if len(out.Candidates) == 0 || len(out.Candidates[0].Content.Parts) == 0 {
+		return nil, errors.New("generateContent: empty response")
+	}
+	var mem CallerMemory
+	if err := json.Unmarshal([]byte(out.Candidates[0].Content.Parts[0].Text), &mem); err != nil {
+		return nil, fmt.Errorf("generateContent: invalid memory JSON: %w", err)
+	}
+	mem.KeyFacts = trimMemoryList(mem.KeyFacts)
+	mem.OpenIssues = trimMemoryList(mem.OpenIssues)
+	mem.LastTopic = sanitizeCallerValue(mem.LastTopic)
+	return &mem, nil
+}
+
+func trimMemoryList(items []string) []string {
+	out := []string{}
+	for _, it := range items {
+		if it = sanitizeCallerValue(it); it != "" && len(out) < maxMemoryItems {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+func nonNil(items []string) []string {
+	if items == nil {
+		return []string{}
+	}
+	return items
+}
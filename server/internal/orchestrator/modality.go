@@ -0,0 +1,38 @@
+package orchestrator
+
+import (
+	"strings"
+
+	"aivoice-v3/internal/protocol"
+)
+
+// Session modalities chosen by the widget at setup.
+const (
+	ModalityAudio = "audio"
+	ModalityText  = "text"
+)
+
+// DefaultTextModel serves text-only sessions when the client has no text model configured
+// (the native audio models only answer with audio).
+const DefaultTextModel = "models/gemini-live-2.5-flash-preview"
+
+// NormalizeModality maps the setup "modality" field to ModalityAudio (default) or ModalityText.
+func NormalizeModality(m string) string {
+	if strings.EqualFold(strings.TrimSpace(m), ModalityText) {
+		return ModalityText
+	}
+	return ModalityAudio
+}
+
+// applyTextModality turns the setup into a text chat with the same prompt and tools: text responses
+// streamed as modelTurn parts, and no speech, audio transcription, voice activity or audio-only options.
+func applyTextModality(setup *protocol.Setup) {
+	gen := setup.GenerationConfig
+	gen.ResponseModalities = []string{"TEXT"}
+	gen.SpeechConfig = nil
+	gen.EnableAffectiveDialog = false
+	setup.InputAudioTranscription = nil
+	setup.OutputAudioTranscription = nil
+	setup.RealtimeInputConfig = nil
+	setup.Proactivity = nil
+}
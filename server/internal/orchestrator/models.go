@@ -0,0 +1,49 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+)
+
+// DefaultModel is used when the client has no model configured.
+const DefaultModel = "models/gemini-2.5-flash-native-audio-preview-12-2025"
+
+// ModelList returns the client's models for the modality in the order they should be tried, without
+// duplicates: the primary model followed by the fallbacks for audio, the text model followed by
+// DefaultTextModel for text. It is never empty.
+func (c *ClientConfig) ModelList(modality string) []string {
+	candidates := c.Models
+	if modality == ModalityText {
+		candidates = []string{c.TextModel, DefaultTextModel}
+	}
+	var models []string
+	seen := map[string]bool{}
+	for _, m := range candidates {
+		if m = normalizeModel(m); m != "" && !seen[m] {
+			seen[m] = true
+			models = append(models, m)
+		}
+	}
+	if len(models) == 0 {
+		return []string{DefaultModel}
+	}
+	return models
+}
+
+// Models returns the models to try for the client's sessions of the modality, primary first.
+func (c *ConfigCache) Models(ctx context.Context, clientName, modality string) []string {
+	cfg, err := c.Get(ctx, clientName)
+	if err != nil || cfg == nil {
+		cfg = &ClientConfig{}
+	}
+	return cfg.ModelList(modality)
+}
+
+// normalizeModel adds the "models/" prefix expected by the Live API.
+func normalizeModel(m string) string {
+	m = strings.TrimSpace(m)
+	if m == "" || strings.HasPrefix(m, "models/") {
+		return m
+	}
+	return "models/" + m
+}
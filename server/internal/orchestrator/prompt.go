@@ -0,0 +1,144 @@
+package orchestrator
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultTimezone is used when the agent has no (or an invalid) timezone configured.
+const DefaultTimezone = "America/Sao_Paulo"
+
+// maxCallerValueLen bounds widget-supplied values injected into the prompt.
+const maxCallerValueLen = 200
+
+var weekdayNames = [...]string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"}
+
+// CallerInfo is what the widget knows about the person on the call.
+type CallerInfo struct {
+	Name string `json:"name"`
+	ID   string `json:"id,omitempty"` // Caller ID (e.g. phone number); keys returning caller memory
+}
+
+// PageInfo describes the page hosting the widget.
+type PageInfo struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// BusinessHoursRule is one opening interval, e.g. {1, "09:00", "18:00"} for Mondays. Weekday 0 is Sunday.
+type BusinessHoursRule struct {
+	Weekday int    `json:"weekday"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+}
+
+// BusinessHoursStatus is the business hours view exposed to templates.
+type BusinessHoursStatus struct {
+	Configured bool
+	Open       bool
+	Status     string // "aberto", "fechado" or "não informado"
+	Today      string // e.g. "09:00–12:00, 13:00–18:00" or "fechado hoje"
+}
+
+// PromptData is the data available to system prompt templates ({{.ClientName}}, {{.Caller.Name}}, ...).
+// Values the widget did not send are replaced by neutral fallbacks, so templates never render empty gaps.
+type PromptData struct {
+	ClientName    string
+	AgentName     string
+	Now           string // "segunda-feira, 19/10/2026 14:05"
+	Date          string // "19/10/2026"
+	Time          string // "14:05"
+	Weekday       string
+	Caller        CallerInfo
+	Page          PageInfo
+	BusinessHours BusinessHoursStatus
+	Context       CallContext // {{.Context.UserID}}, {{.Context.Locale}}, {{index .Context.Custom "plano"}}
+}
+
+// NewPromptData builds the template data for a session at the given instant.
+func NewPromptData(cfg *AIConfig, req SetupRequest, now time.Time) PromptData {
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if cfg.Timezone == "" || err != nil {
+		loc, _ = time.LoadLocation(DefaultTimezone)
+	}
+	if loc != nil {
+		now = now.In(loc)
+	}
+
+	pageURL := req.Page.URL
+	if pageURL == "" {
+		pageURL = req.Context.PageURL
+	}
+	return PromptData{
+		ClientName:    req.ClientName,
+		AgentName:     cfg.AgentName,
+		Now:           fmt.Sprintf("%s, %s", weekdayNames[now.Weekday()], now.Format("02/01/2006 15:04")),
+		Date:          now.Format("02/01/2006"),
+		Time:          now.Format("15:04"),
+		Weekday:       weekdayNames[now.Weekday()],
+		Caller:        CallerInfo{Name: fallback(req.Caller.Name, "não informado")},
+		Page:          PageInfo{URL: fallback(pageURL, "não informada"), Title: fallback(req.Page.Title, "não informado")},
+		BusinessHours: businessHoursStatus(cfg.BusinessHours, now),
+		Context:       req.Context,
+	}
+}
+
+// RenderPrompt executes text as a text/template with data. Text without actions is returned unchanged.
+func RenderPrompt(text string, data PromptData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tpl, err := template.New("prompt").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func businessHoursStatus(rules []BusinessHoursRule, now time.Time) BusinessHoursStatus {
+	if len(rules) == 0 {
+		return BusinessHoursStatus{Status: "não informado", Today: "não informado"}
+	}
+	status := BusinessHoursStatus{Configured: true, Status: "fechado"}
+	clock := now.Format("15:04")
+	var today []string
+	for _, r := range rules {
+		if r.Weekday != int(now.Weekday()) {
+			continue
+		}
+		today = append(today, r.Start+"–"+r.End)
+		// "HH:MM" strings compare chronologically
+		if clock >= r.Start && clock < r.End {
+			status.Open = true
+			status.Status = "aberto"
+		}
+	}
+	status.Today = "fechado hoje"
+	if len(today) > 0 {
+		status.Today = strings.Join(today, ", ")
+	}
+	return status
+}
+
+// sanitizeCallerValue keeps widget-supplied text on a single, bounded line before it reaches the prompt.
+func sanitizeCallerValue(v string) string {
+	v = strings.Join(strings.Fields(v), " ")
+	if r := []rune(v); len(r) > maxCallerValueLen {
+		v = string(r[:maxCallerValueLen])
+	}
+	return v
+}
+
+func fallback(v, def string) string {
+	if v = sanitizeCallerValue(v); v == "" {
+		return def
+	}
+	return v
+}
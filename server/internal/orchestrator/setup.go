@@ -2,7 +2,12 @@ package orchestrator
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"aivoice-v3/internal/protocol"
@@ -10,79 +15,130 @@ import (
 
 // AIConfig defines the configuration for the AI agent
 type AIConfig struct {
-	VoiceName                 string  `json:"voiceName"`
-	LanguageCode              string  `json:"languageCode"`
-	Temperature               float64 `json:"temperature"`
-	ThinkingBudget            int     `json:"thinkingBudget"`
-	EnableAffectiveDialog     bool    `json:"enableAffectiveDialog"`
-	ProactiveAudio            bool    `json:"proactiveAudio"`
-	SystemPrompt              string  `json:"systemPrompt,omitempty"`
-	DocstringToolKnowledge    string  `json:"docstringToolKnowledge"`
-	DocstringToolTerminate    string  `json:"docstringToolTerminate"`
-	DocstringToolSendLink     string  `json:"docstringToolSendLink"`
-	DurationLimit             int     `json:"durationLimit"`
-	TerminationAlertTime      int     `json:"terminationAlertTime"`
-	ProactiveAlertInstruction string  `json:"proactiveAlertInstruction"`
+	VoiceName                 string              `json:"voiceName"`
+	LanguageCode              string              `json:"languageCode"`
+	Temperature               float64             `json:"temperature"`
+	ThinkingBudget            int                 `json:"thinkingBudget"`
+	EnableAffectiveDialog     bool                `json:"enableAffectiveDialog"`
+	ProactiveAudio            bool                `json:"proactiveAudio"`
+	SystemPrompt              string              `json:"systemPrompt,omitempty"`
+	DocstringToolKnowledge    string              `json:"docstringToolKnowledge"`
+	DocstringToolTerminate    string              `json:"docstringToolTerminate"`
+	DocstringToolSendLink     string              `json:"docstringToolSendLink"`
+	DurationLimit             int                 `json:"durationLimit"`
+	TerminationAlertTime      int                 `json:"terminationAlertTime"`
+	ProactiveAlertInstruction string              `json:"proactiveAlertInstruction"`
+	DocstringToolEscalate     string              `json:"docstringToolEscalate"`
+	DocstringToolLead         string              `json:"docstringToolLead"`
+	LeadSchema                []LeadField         `json:"leadSchema"`
+	SchedulingEnabled         bool                `json:"schedulingEnabled"`
+	AgentName                 string              `json:"agentName"`
+	AgentDescription          string              `json:"agentDescription"`
+	EnabledTools              []string            `json:"enabledTools"`
+	KBCategories              []string            `json:"kbCategories"`
+	Version                   int                 `json:"version"`
+	Timezone                  string              `json:"timezone"`
+	BusinessHours             []BusinessHoursRule `json:"businessHours"`
+	TopP                      float64             `json:"topP"`                // 0 = model default
+	TopK                      int                 `json:"topK"`                // 0 = model default
+	MaxOutputTokens           int                 `json:"maxOutputTokens"`     // 0 = model default
+	VADStartSensitivity       string              `json:"vadStartSensitivity"` // "", "high" or "low"
+	VADEndSensitivity         string              `json:"vadEndSensitivity"`   // "", "high" or "low"
+	VADPrefixPaddingMs        int                 `json:"vadPrefixPaddingMs"`
+	VADSilenceDurationMs      int                 `json:"vadSilenceDurationMs"`
+	ContextCompression        bool                `json:"contextCompression"`
+	CompressionTriggerTokens  int                 `json:"compressionTriggerTokens"`
+	CompressionTargetTokens   int                 `json:"compressionTargetTokens"`
+
+	// Set by GetInitialSetup when the call was assigned to an experiment variant
+	ExperimentID int    `json:"-"`
+	Variant      string `json:"-"`
 }
 
-// GetInitialSetup orchestrates the fetching of configuration and construction of the setup payload
-func GetInitialSetup(ctx context.Context, db *pgxpool.Pool, clientName string) (*protocol.Setup, error) {
-	cfg, err := fetchConfig(ctx, db, clientName)
-	if err != nil {
-		// Fallback safe defaults if config fetch fails, or handle error upstack
-		// For robustness, if DB fails, we might want to return default or error.
-		// Returning error allows the caller to decide.
-		// However, given the "Robustez" rule, let's try to proceed with default if just not found, 
-        // but if DB is down `fetchConfig` returns error.
-        // Let's assume we return defaults if we can't find specific config but DB is up?
-        // Actually, main.go had a fallback: if cfg == nil { default }
-        // Let's keep that logic here.
-        if cfg == nil {
-             cfg = &AIConfig{
-				VoiceName: "Aoede", 
-				LanguageCode: "pt-BR", 
-				Temperature: 0.7,
-				SystemPrompt: fmt.Sprintf("Você é o %s, um assistente de voz avançado criado pelo estúdio TkzM.", clientName),
-				DocstringToolKnowledge: fmt.Sprintf("Invoque esta ferramenta sempre que o usuário tiver dúvidas sobre o %s.", clientName),
-			 }
-        } else {
-             return nil, err
-        }
-	}
-    
-    // Double check if cfg is nil (e.g. valid query but no rows found)
-    if cfg == nil {
-        cfg = &AIConfig{
-			VoiceName: "Aoede", 
-			LanguageCode: "pt-BR", 
-			Temperature: 0.7,
-			SystemPrompt: fmt.Sprintf("Você é o %s, um assistente de voz avançado criado pelo estúdio TkzM.", clientName),
+// SetupRequest identifies the session a setup is built for.
+type SetupRequest struct {
+	ClientName string
+	AgentName  string // Empty selects the client's default agent
+	CallID     string // Used for deterministic experiment assignment
+	Caller     CallerInfo
+	Page       PageInfo
+	Context    CallContext // Already validated against the client's allowlist
+	Modality   string      // ModalityAudio (default) or ModalityText
+}
+
+// AgentSummary identifies one of the client's agents (used by transferir_para_agente).
+type AgentSummary struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// LeadField describes one field of the client's lead capture form (registrar_lead).
+type LeadField struct {
+	Name        string `json:"name"`
+	Label       string `json:"label"`
+	Type        string `json:"type"` // string, number, boolean, email, phone
+	Required    bool   `json:"required"`
+	Pattern     string `json:"pattern,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+const defaultEscalateDoc = "Use esta ferramenta quando o usuário pedir para falar com uma pessoa ou quando você não conseguir resolver a solicitação. Informe o motivo, um resumo da conversa e os dados de contato já coletados."
+
+// GetInitialSetup orchestrates the fetching of configuration and construction of the setup payload.
+// req.AgentName selects one of the client's agents; empty (or unknown) falls back to the default agent.
+// The resolved config (including any experiment variant) is returned so the session can apply limits
+// and agent-specific settings.
+// Config is read through the cache; only ErrClientSuspended and ErrClientNotFound are returned.
+func GetInitialSetup(ctx context.Context, cache *ConfigCache, req SetupRequest) (*protocol.Setup, *AIConfig, error) {
+	clientName := req.ClientName
+	cc, err := cache.Get(ctx, clientName)
+	if errors.Is(err, ErrClientSuspended) || errors.Is(err, ErrClientNotFound) {
+		// Suspended or unknown clients never fall back to the default persona
+		return nil, nil, err
+	}
+	if cc == nil {
+		// No database (or unreachable with nothing cached yet): proceed with safe defaults
+		cc = &ClientConfig{}
+	}
+
+	cfg := cc.Agent(req.AgentName)
+	if cfg == nil {
+		cfg = &AIConfig{
+			VoiceName:              "Aoede",
+			LanguageCode:           "pt-BR",
+			Temperature:            0.7,
+			ThinkingBudget:         -1, // dynamic (model default); 0 would turn thinking off
+			SystemPrompt:           fmt.Sprintf("Você é o %s, um assistente de voz avançado criado pelo estúdio TkzM.", clientName),
 			DocstringToolKnowledge: fmt.Sprintf("Invoque esta ferramenta sempre que o usuário tiver dúvidas sobre o %s.", clientName),
 		}
-    }
+	}
+	applyExperiment(cfg, cc.Experiments, req.CallID)
 
-	cats, err := fetchAllCategories(ctx, db)
-	// If categories fail, we can just use "all" or empty. Non-critical.
-	if err != nil {
-		cats = []string{}
+	cats := cc.Categories
+	if len(cfg.KBCategories) > 0 {
+		cats = cfg.KBCategories
 	}
 
 	catList := "all"
 	for _, c := range cats {
 		catList += ", " + c
 	}
+	escalateDoc := cfg.DocstringToolEscalate
+	if escalateDoc == "" {
+		escalateDoc = defaultEscalateDoc
+	}
+
 	dynamicKnowledgeDoc := fmt.Sprintf("%s\n\n---\n⚠️ INJEÇÃO DINÂMICA (Categorias Ativas): [%s]\nUse o parâmetro 'category' com uma das opções acima para filtrar a busca, ou 'all' para busca global.", cfg.DocstringToolKnowledge, catList)
 
-	finalPrompt := cfg.SystemPrompt
-	if cfg.EnableAffectiveDialog {
-		finalPrompt = "MODO AFETIVO ATIVADO: Use um tom de voz empático, expressivo e humano. Adapte sua entonação e prosódia às emoções detectadas na conversa.\n\n" + finalPrompt
-	}
-	if cfg.ProactiveAudio {
-		finalPrompt = "MODO PROATIVO ATIVADO: Seja proativa. Não hesite em tomar a iniciativa, sugerir caminhos ou fazer perguntas para manter a fluidez, especialmente se o usuário parecer em dúvida.\n\n" + finalPrompt
+	finalPrompt, err := RenderPrompt(cfg.SystemPrompt, NewPromptData(cfg, req, time.Now()))
+	if err != nil {
+		// A broken template must not take the agent down: fall back to the raw text
+		log.Printf("⚠️ Template do prompt de %s/%s inválido: %v", clientName, cfg.AgentName, err)
+		finalPrompt = cfg.SystemPrompt
 	}
 
 	setupBody := &protocol.Setup{
-		Model: "models/gemini-2.5-flash-native-audio-preview-12-2025",
+		Model: cc.ModelList(req.Modality)[0], // Fallbacks are tried by the caller when dialing (ConfigCache.Models)
 		GenerationConfig: &protocol.GenerationConfig{
 			ResponseModalities: []string{"AUDIO"},
 			SpeechConfig: &protocol.SpeechConfig{
@@ -92,9 +148,6 @@ func GetInitialSetup(ctx context.Context, db *pgxpool.Pool, clientName string) (
 				LanguageCode: cfg.LanguageCode,
 			},
 			Temperature: cfg.Temperature,
-			ThinkingConfig: &protocol.ThinkingConfig{
-				IncludeThoughts: false,
-			},
 		},
 		// Explicitly enable transcriptions (Required by Gemini Live API)
 		InputAudioTranscription:  map[string]interface{}{},
@@ -138,40 +191,296 @@ func GetInitialSetup(ctx context.Context, db *pgxpool.Pool, clientName string) (
 							"required": []string{"url", "alias"},
 						},
 					},
+					{
+						Name:        "transferir_para_humano",
+						Description: escalateDoc,
+						Parameters: map[string]interface{}{
+							"type": "OBJECT",
+							"properties": map[string]interface{}{
+								"reason":        map[string]interface{}{"type": "STRING", "description": "Motivo da transferência"},
+								"summary":       map[string]interface{}{"type": "STRING", "description": "Resumo da conversa até agora"},
+								"contact_name":  map[string]interface{}{"type": "STRING", "description": "Nome do usuário, se informado"},
+								"contact_phone": map[string]interface{}{"type": "STRING", "description": "Telefone do usuário, se informado"},
+								"contact_email": map[string]interface{}{"type": "STRING", "description": "Email do usuário, se informado"},
+							},
+							"required": []string{"reason", "summary"},
+						},
+					},
 				},
 			},
 		},
 	}
 
-	return setupBody, nil
+	applyLiveConfig(setupBody, cfg)
+	if req.Modality == ModalityText {
+		applyTextModality(setupBody)
+	} else if cc.PushToTalk {
+		disableActivityDetection(setupBody)
+	}
+
+	if len(cfg.LeadSchema) > 0 {
+		setupBody.Tools[0].FunctionDeclarations = append(setupBody.Tools[0].FunctionDeclarations, buildLeadTool(cfg))
+	}
+	for _, channel := range []string{"email", "sms"} {
+		if names := cc.Templates[channel]; len(names) > 0 {
+			setupBody.Tools[0].FunctionDeclarations = append(setupBody.Tools[0].FunctionDeclarations, notificationTool(channel, names))
+		}
+	}
+	if cfg.SchedulingEnabled {
+		setupBody.Tools[0].FunctionDeclarations = append(setupBody.Tools[0].FunctionDeclarations, schedulingTools()...)
+	}
+	if len(cfg.EnabledTools) > 0 {
+		setupBody.Tools[0].FunctionDeclarations = filterTools(setupBody.Tools[0].FunctionDeclarations, cfg.EnabledTools)
+	}
+	if agents := cc.AgentList(); len(agents) > 1 {
+		setupBody.Tools[0].FunctionDeclarations = append(setupBody.Tools[0].FunctionDeclarations, transferAgentTool(cfg.AgentName, agents))
+	}
+
+	// Returning caller: continue from what previous calls left behind
+	if identity := CallerIdentity(req.Caller, req.Context); identity != "" && cc.Memory.Enabled {
+		mem, err := LoadCallerMemory(ctx, cache.DB(), clientName, identity, cc.Memory)
+		if err != nil {
+			log.Printf("⚠️ Memória do cliente indisponível (%s/%s): %v", clientName, identity, err)
+		}
+		WithCallerMemory(setupBody, mem)
+	}
+
+	return setupBody, cfg, nil
+}
+
+// WithHandoffContext appends the conversation summary carried over from another agent to the system instruction.
+func WithHandoffContext(setup *protocol.Setup, fromAgent, summary string) {
+	if setup == nil || setup.SystemInstruction == nil || len(setup.SystemInstruction.Parts) == 0 {
+		return
+	}
+	setup.SystemInstruction.Parts[0].Text += fmt.Sprintf("\n\n---\nCONTEXTO DA TRANSFERÊNCIA: esta conversa foi transferida pelo agente '%s'. Resumo até aqui: %s\nContinue o atendimento sem pedir novamente informações já fornecidas.", fromAgent, summary)
+}
+
+// filterTools keeps only the enabled declarations; finalizar_atendimento is always kept.
+func filterTools(decls []protocol.FunctionDeclaration, enabled []string) []protocol.FunctionDeclaration {
+	allowed := map[string]bool{"finalizar_atendimento": true}
+	for _, name := range enabled {
+		allowed[name] = true
+	}
+	filtered := []protocol.FunctionDeclaration{}
+	for _, d := range decls {
+		if allowed[d.Name] {
+			filtered = append(filtered, d)
+		}
+	}
+	return filtered
 }
 
-func fetchConfig(ctx context.Context, db *pgxpool.Pool, clientName string) (*AIConfig, error) {
+// transferAgentTool declares transferir_para_agente listing the client's other agents.
+func transferAgentTool(current string, agents []AgentSummary) protocol.FunctionDeclaration {
+	options := []string{}
+	for _, a := range agents {
+		if a.Name == current {
+			continue
+		}
+		if a.Description != "" {
+			options = append(options, fmt.Sprintf("'%s' (%s)", a.Name, a.Description))
+		} else {
+			options = append(options, fmt.Sprintf("'%s'", a.Name))
+		}
+	}
+	return protocol.FunctionDeclaration{
+		Name:        "transferir_para_agente",
+		Description: fmt.Sprintf("Transfere a conversa para outro agente especializado quando o assunto for da área dele. Avise o usuário antes de transferir. Agentes disponíveis: %s.", strings.Join(options, ", ")),
+		Parameters: map[string]interface{}{
+			"type": "OBJECT",
+			"properties": map[string]interface{}{
+				"agent":   map[string]interface{}{"type": "STRING", "description": "Nome do agente de destino"},
+				"summary": map[string]interface{}{"type": "STRING", "description": "Resumo da conversa até agora, incluindo dados já coletados"},
+			},
+			"required": []string{"agent", "summary"},
+		},
+	}
+}
+
+// notificationTool declares enviar_email / enviar_sms, listing the client's templates for the channel.
+func notificationTool(channel string, templates []string) protocol.FunctionDeclaration {
+	name, target, toDesc := "enviar_email", "um email", "Endereço de email do destinatário"
+	if channel == "sms" {
+		name, target, toDesc = "enviar_sms", "um SMS", "Telefone do destinatário com DDD"
+	}
+	return protocol.FunctionDeclaration{
+		Name:        name,
+		Description: fmt.Sprintf("Envia %s de confirmação ao usuário usando um modelo pré-definido. Confirme o destinatário com o usuário antes de enviar. Modelos disponíveis: [%s].", target, strings.Join(templates, ", ")),
+		Parameters: map[string]interface{}{
+			"type": "OBJECT",
+			"properties": map[string]interface{}{
+				"to":       map[string]interface{}{"type": "STRING", "description": toDesc},
+				"template": map[string]interface{}{"type": "STRING", "description": "Nome do modelo"},
+				"name":     map[string]interface{}{"type": "STRING", "description": "Nome do usuário"},
+				"message":  map[string]interface{}{"type": "STRING", "description": "Texto livre a incluir na mensagem"},
+				"code":     map[string]interface{}{"type": "STRING", "description": "Código de referência (ex: código da reserva)"},
+				"when":     map[string]interface{}{"type": "STRING", "description": "Data/horário de referência"},
+			},
+			"required": []string{"to", "template"},
+		},
+	}
+}
+
+// schedulingTools declares the availability lookup, booking, confirmation and cancellation tools backed by dashboard-server.
+func schedulingTools() []protocol.FunctionDeclaration {
+	return []protocol.FunctionDeclaration{
+		{
+			Name:        "consultar_horarios",
+			Description: "Consulta os horários livres da agenda em uma data. Use antes de oferecer horários ao usuário e nunca invente horários.",
+			Parameters: map[string]interface{}{
+				"type": "OBJECT",
+				"properties": map[string]interface{}{
+					"date":        map[string]interface{}{"type": "STRING", "description": "Data no formato AAAA-MM-DD"},
+					"resource_id": map[string]interface{}{"type": "NUMBER", "description": "ID do profissional/recurso (opcional; omita para consultar todos)"},
+				},
+				"required": []string{"date"},
+			},
+		},
+		{
+			Name:        "agendar_horario",
+			Description: "Reserva um horário livre retornado por consultar_horarios. Confirme data, horário e nome com o usuário antes de chamar. Informe ao usuário o código da reserva retornado.",
+			Parameters: map[string]interface{}{
+				"type": "OBJECT",
+				"properties": map[string]interface{}{
+					"resource_id":    map[string]interface{}{"type": "NUMBER", "description": "ID do profissional/recurso"},
+					"start":          map[string]interface{}{"type": "STRING", "description": "Início no formato AAAA-MM-DDTHH:MM (horário local)"},
+					"customer_name":  map[string]interface{}{"type": "STRING", "description": "Nome do usuário"},
+					"customer_phone": map[string]interface{}{"type": "STRING", "description": "Telefone do usuário"},
+				},
+				"required": []string{"resource_id", "start", "customer_name"},
+			},
+		},
+		bookingCodeTool("confirmar_reserva", "Confirma a presença em uma reserva existente pelo código informado pelo usuário."),
+		bookingCodeTool("cancelar_reserva", "Cancela uma reserva existente pelo código informado pelo usuário. Confirme com o usuário antes de chamar."),
+	}
+}
+
+func bookingCodeTool(name, description string) protocol.FunctionDeclaration {
+	return protocol.FunctionDeclaration{
+		Name:        name,
+		Description: description,
+		Parameters: map[string]interface{}{
+			"type": "OBJECT",
+			"properties": map[string]interface{}{
+				"code": map[string]interface{}{"type": "STRING", "description": "Código da reserva (6 caracteres)"},
+			},
+			"required": []string{"code"},
+		},
+	}
+}
+
+// buildLeadTool derives the registrar_lead declaration from the client's field schema.
+func buildLeadTool(cfg *AIConfig) protocol.FunctionDeclaration {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for _, f := range cfg.LeadSchema {
+		paramType := "STRING"
+		switch f.Type {
+		case "number":
+			paramType = "NUMBER"
+		case "boolean":
+			paramType = "BOOLEAN"
+		}
+		desc := f.Label
+		if f.Description != "" {
+			desc = fmt.Sprintf("%s. %s", f.Label, f.Description)
+		}
+		properties[f.Name] = map[string]interface{}{"type": paramType, "description": desc}
+		if f.Required {
+			required = append(required, f.Name)
+		}
+	}
+
+	doc := cfg.DocstringToolLead
+	if doc == "" {
+		doc = "Use esta ferramenta para registrar os dados de contato do usuário assim que ele os informar. Se a ferramenta retornar erros de validação, peça novamente apenas os campos indicados."
+	}
+
+	params := map[string]interface{}{
+		"type":       "OBJECT",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		params["required"] = required
+	}
+	return protocol.FunctionDeclaration{Name: "registrar_lead", Description: doc, Parameters: params}
+}
+
+// fetchAgentConfigs loads every agent of an active client, default agent first.
+func fetchAgentConfigs(ctx context.Context, db *pgxpool.Pool, clientName string) ([]*AIConfig, error) {
 	if db == nil {
 		return nil, nil
 	}
-	var cfg AIConfig
 	query := `
-		SELECT c.voice_name, c.language_code, c.temperature, c.thinking_budget, COALESCE(c.enable_affective_dialog, false), COALESCE(c.proactive_audio, false), COALESCE(c.system_prompt, ''), COALESCE(c.docstring_tool_knowledge, ''), COALESCE(c.docstring_tool_terminate, ''), c.duration_limit, c.termination_alert_time, COALESCE(c.proactive_alert_instruction, ''), COALESCE(c.docstring_tool_send_link, '')
+		SELECT c.voice_name, c.language_code, c.temperature, c.thinking_budget, COALESCE(c.enable_affective_dialog, false), COALESCE(c.proactive_audio, false), COALESCE(c.system_prompt, ''), COALESCE(c.docstring_tool_knowledge, ''), COALESCE(c.docstring_tool_terminate, ''), c.duration_limit, c.termination_alert_time, COALESCE(c.proactive_alert_instruction, ''), COALESCE(c.docstring_tool_send_link, ''), COALESCE(c.docstring_tool_escalate, ''), COALESCE(c.docstring_tool_lead, ''), COALESCE(c.lead_schema, '[]'::jsonb), COALESCE(c.scheduling_enabled, false), c.agent_name, COALESCE(c.agent_description, ''), COALESCE(c.enabled_tools, '{}'), COALESCE(c.kb_categories, '{}'), c.version, COALESCE(c.timezone, ''), COALESCE(c.business_hours, '[]'::jsonb),
+			COALESCE(c.top_p, 0), COALESCE(c.top_k, 0), COALESCE(c.max_output_tokens, 0), COALESCE(c.vad_start_sensitivity, ''), COALESCE(c.vad_end_sensitivity, ''), COALESCE(c.vad_prefix_padding_ms, 0), COALESCE(c.vad_silence_duration_ms, 0), COALESCE(c.context_compression, false), COALESCE(c.compression_trigger_tokens, 0), COALESCE(c.compression_target_tokens, 0)
 		FROM aiVoice_config c
 		JOIN aiVoice_clients cl ON c.client_id = cl.id
 		WHERE cl.name = $1 AND cl.status = 'active'
-		LIMIT 1
+		ORDER BY c.is_default DESC, c.id ASC
 	`
-	err := db.QueryRow(ctx, query, clientName).Scan(
-		&cfg.VoiceName, &cfg.LanguageCode, &cfg.Temperature, &cfg.ThinkingBudget, &cfg.EnableAffectiveDialog, &cfg.ProactiveAudio, &cfg.SystemPrompt, &cfg.DocstringToolKnowledge, &cfg.DocstringToolTerminate, &cfg.DurationLimit, &cfg.TerminationAlertTime, &cfg.ProactiveAlertInstruction, &cfg.DocstringToolSendLink,
-	)
+	rows, err := db.Query(ctx, query, clientName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var agents []*AIConfig
+	for rows.Next() {
+		var cfg AIConfig
+		var leadSchema, businessHours []byte
+		err := rows.Scan(
+			&cfg.VoiceName, &cfg.LanguageCode, &cfg.Temperature, &cfg.ThinkingBudget, &cfg.EnableAffectiveDialog, &cfg.ProactiveAudio, &cfg.SystemPrompt, &cfg.DocstringToolKnowledge, &cfg.DocstringToolTerminate, &cfg.DurationLimit, &cfg.TerminationAlertTime, &cfg.ProactiveAlertInstruction, &cfg.DocstringToolSendLink, &cfg.DocstringToolEscalate, &cfg.DocstringToolLead, &leadSchema, &cfg.SchedulingEnabled, &cfg.AgentName, &cfg.AgentDescription, &cfg.EnabledTools, &cfg.KBCategories, &cfg.Version, &cfg.Timezone, &businessHours,
+			&cfg.TopP, &cfg.TopK, &cfg.MaxOutputTokens, &cfg.VADStartSensitivity, &cfg.VADEndSensitivity, &cfg.VADPrefixPaddingMs, &cfg.VADSilenceDurationMs, &cfg.ContextCompression, &cfg.CompressionTriggerTokens, &cfg.CompressionTargetTokens,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(leadSchema, &cfg.LeadSchema); err != nil {
+			cfg.LeadSchema = nil
+		}
+		if err := json.Unmarshal(businessHours, &cfg.BusinessHours); err != nil {
+			cfg.BusinessHours = nil
+		}
+		agents = append(agents, &cfg)
+	}
+	return agents, rows.Err()
+}
+
+func fetchTemplateNames(ctx context.Context, db *pgxpool.Pool, clientName string) (map[string][]string, error) {
+	if db == nil {
+		return nil, nil
+	}
+	rows, err := db.Query(ctx, `
+		SELECT t.channel, t.name
+		FROM aiVoice_notification_templates t
+		JOIN aiVoice_clients cl ON t.client_id = cl.id
+		WHERE cl.name = $1
+		ORDER BY t.name`, clientName)
 	if err != nil {
 		return nil, err
 	}
-	return &cfg, nil
+	defer rows.Close()
+	templates := map[string][]string{}
+	for rows.Next() {
+		var channel, name string
+		if err := rows.Scan(&channel, &name); err == nil {
+			templates[channel] = append(templates[channel], name)
+		}
+	}
+	return templates, nil
 }
 
-func fetchAllCategories(ctx context.Context, db *pgxpool.Pool) ([]string, error) {
+func fetchAllCategories(ctx context.Context, db *pgxpool.Pool, clientName string) ([]string, error) {
     if db == nil {
         return nil, nil
     }
-	rows, err := db.Query(ctx, "SELECT name FROM knowledge_categories ORDER BY name ASC")
+	rows, err := db.Query(ctx, `
+		SELECT kc.name FROM knowledge_categories kc
+		JOIN aiVoice_clients cl ON kc.client_id = cl.id
+		WHERE cl.name = $1
+		ORDER BY kc.name ASC`, clientName)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,136 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultTranscriptionModel transcribes voice notes from messaging channels when neither the client
+// (transcription_model) nor TRANSCRIPTION_MODEL sets one. Live API models don't serve generateContent.
+const DefaultTranscriptionModel = "gemini-2.5-flash"
+
+// ErrEmptyTranscription is returned when the audio has no intelligible speech.
+var ErrEmptyTranscription = errors.New("empty transcription")
+
+// APIError is a non-200 answer from generateContent, kept so callers can tell quota and key errors apart.
+type APIError struct {
+	StatusCode int
+	Status     string // google.rpc status, e.g. RESOURCE_EXHAUSTED
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("generateContent: status %d %s: %s", e.StatusCode, e.Status, e.Message)
+}
+
+// TokenUsage is the usageMetadata of a generateContent call.
+type TokenUsage struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+// TranscriptionModelList returns the models to try for voice notes, without duplicates: the client's
+// transcription model, then TRANSCRIPTION_MODEL, then DefaultTranscriptionModel. It is never empty.
+func (c *ClientConfig) TranscriptionModelList() []string {
+	var models []string
+	seen := map[string]bool{}
+	for _, m := range []string{c.TranscriptionModel, os.Getenv("TRANSCRIPTION_MODEL"), DefaultTranscriptionModel} {
+		// generateContent takes the bare name in the URL path
+		if m = strings.TrimPrefix(strings.TrimSpace(m), "models/"); m != "" && !seen[m] {
+			seen[m] = true
+			models = append(models, m)
+		}
+	}
+	return models
+}
+
+// TranscriptionModels returns the models to try for the client's voice notes, preferred first.
+func (c *ConfigCache) TranscriptionModels(ctx context.Context, clientName string) []string {
+	cfg, err := c.Get(ctx, clientName)
+	if err != nil || cfg == nil {
+		cfg = &ClientConfig{}
+	}
+	return cfg.TranscriptionModelList()
+}
+
+// TranscribeAudio sends a recorded audio message (e.g. a WhatsApp voice note, audio/ogg) to the model
+// and returns what the user said, so the message can continue through the text chat, along with the
+// tokens the call consumed. Non-200 answers are returned as *APIError.
+func TranscribeAudio(ctx context.Context, apiKey, model, mimeType string, audio []byte) (string, TokenUsage, error) {
+	var usage TokenUsage
+	if mimeType == "" {
+		mimeType = "audio/ogg"
+	}
+	// WhatsApp sends "audio/ogg; codecs=opus"; the API wants the bare MIME type
+	mimeType = strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0])
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"contents": []map[string]interface{}{{
+			"role": "user",
+			"parts": []map[string]interface{}{
+				{"text": "Transcreva literalmente a fala deste áudio, no idioma falado. Responda só com a transcrição, sem comentários. Se não houver fala inteligível, responda com uma linha vazia."},
+				{"inlineData": map[string]string{"mimeType": mimeType, "data": base64.StdEncoding.EncodeToString(audio)}},
+			},
+		}},
+		"generationConfig": map[string]interface{}{"temperature": 0},
+	})
+
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", model, apiKey)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return "", usage, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := (&http.Client{Timeout: 60 * time.Second}).Do(req)
+	if err != nil {
+		return "", usage, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+				Status  string `json:"status"`
+			} `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&apiErr)
+		return "", usage, &APIError{StatusCode: resp.StatusCode, Status: apiErr.Error.Status, Message: apiErr.Error.Message}
+	}
+
+	var out struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+		} `json:"candidates"`
+		UsageMetadata struct {
+			PromptTokenCount     int `json:"promptTokenCount"`
+			CandidatesTokenCount int `json:"candidatesTokenCount"`
+		} `json:"usageMetadata"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", usage, err
+	}
+	usage = TokenUsage{InputTokens: out.UsageMetadata.PromptTokenCount, OutputTokens: out.UsageMetadata.CandidatesTokenCount}
+	var text strings.Builder
+	if len(out.Candidates) > 0 {
+		for _, p := range out.Candidates[0].Content.Parts {
+			text.WriteString(p.Text)
+		}
+	}
+	if strings.TrimSpace(text.String()) == "" {
+		return "", usage, ErrEmptyTranscription
+	}
+	return strings.TrimSpace(text.String()), usage, nil
+}
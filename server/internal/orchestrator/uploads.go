@@ -0,0 +1,144 @@
+package orchestrator
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// MaxUploadBytes bounds a file sent by the user during a session (it travels inline to Gemini).
+	MaxUploadBytes = 5 << 20
+	// defaultUploadRetentionDays applies when the client has uploads enabled but no retention set.
+	defaultUploadRetentionDays = 30
+)
+
+// uploadTypes is the allowlist of file types, detected from the content rather than the filename.
+var uploadTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// UploadPolicy is the client's file upload setting (aiVoice_clients.uploads_enabled). Retention
+// (upload_retention_days) is applied by ExpiredUploads directly in SQL.
+type UploadPolicy struct {
+	Enabled bool
+}
+
+// Upload is a file the user sent during a session, kept in the upload store under StorageKey.
+type Upload struct {
+	ID         string
+	ClientName string
+	CallID     string
+	StorageKey string
+	FileName   string
+	MimeType   string
+	SizeBytes  int
+	Caption    string
+	CreatedAt  time.Time
+}
+
+// DetectUploadType sniffs the file content and returns its MIME type and extension,
+// or ok=false when the type is not on the allowlist.
+func DetectUploadType(data []byte) (mimeType, ext string, ok bool) {
+	mimeType = http.DetectContentType(data)
+	ext, ok = uploadTypes[mimeType]
+	return mimeType, ext, ok
+}
+
+// UploadPolicy returns the client's upload setting (disabled when the config can't be loaded).
+func (c *ConfigCache) UploadPolicy(ctx context.Context, clientName string) UploadPolicy {
+	cfg, err := c.Get(ctx, clientName)
+	if err != nil || cfg == nil {
+		return UploadPolicy{}
+	}
+	return cfg.Uploads
+}
+
+// RecordUpload stores the upload's reference; the file itself is already in the upload store.
+func RecordUpload(ctx context.Context, db *pgxpool.Pool, u Upload) error {
+	if db == nil {
+		return nil
+	}
+	_, err := db.Exec(ctx, `
+		INSERT INTO aiVoice_uploads (id, client_id, call_id, storage_key, file_name, mime_type, size_bytes, caption)
+		VALUES ($1, (SELECT id FROM aiVoice_clients WHERE name = $2), $3, $4, $5, $6, $7, NULLIF($8, ''))`,
+		u.ID, u.ClientName, u.CallID, u.StorageKey, u.FileName, u.MimeType, u.SizeBytes, u.Caption)
+	return err
+}
+
+// ExpiredUploads returns up to limit uploads past their client's retention period, oldest first.
+func ExpiredUploads(ctx context.Context, db *pgxpool.Pool, limit int) ([]Upload, error) {
+	if db == nil {
+		return nil, nil
+	}
+	rows, err := db.Query(ctx, `
+		SELECT u.id, cl.name, u.call_id, u.storage_key, u.created_at
+		FROM aiVoice_uploads u
+		JOIN aiVoice_clients cl ON u.client_id = cl.id
+		WHERE u.created_at < NOW() - make_interval(days => COALESCE(NULLIF(cl.upload_retention_days, 0), $1))
+		ORDER BY u.created_at
+		LIMIT $2`, defaultUploadRetentionDays, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Upload
+	for rows.Next() {
+		var u Upload
+		if err := rows.Scan(&u.ID, &u.ClientName, &u.CallID, &u.StorageKey, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// DeleteUploadRecord removes the upload's reference once its file is gone from the store.
+func DeleteUploadRecord(ctx context.Context, db *pgxpool.Pool, id string) error {
+	if db == nil {
+		return nil
+	}
+	_, err := db.Exec(ctx, "DELETE FROM aiVoice_uploads WHERE id = $1", id)
+	return err
+}
+
+// PendingUploadDeletions returns up to limit files whose upload rows are already gone (the dashboard
+// queues them in aiVoice_upload_deletions when it deletes a client) and still have to be removed from
+// the store. Only ID and StorageKey are set.
+func PendingUploadDeletions(ctx context.Context, db *pgxpool.Pool, limit int) ([]Upload, error) {
+	if db == nil {
+		return nil, nil
+	}
+	rows, err := db.Query(ctx, `
+		SELECT id, storage_key, created_at
+		FROM aiVoice_upload_deletions
+		ORDER BY created_at
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Upload
+	for rows.Next() {
+		var u Upload
+		if err := rows.Scan(&u.ID, &u.StorageKey, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// DeletePendingUpload removes a queued deletion once its file is gone from the store.
+func DeletePendingUpload(ctx context.Context, db *pgxpool.Pool, id string) error {
+	if db == nil {
+		return nil
+	}
+	_, err := db.Exec(ctx, "DELETE FROM aiVoice_upload_deletions WHERE id = $1", id)
+	return err
+}
@@ -0,0 +1,45 @@
+package orchestrator
+
+import "context"
+
+// Limits for video input (screen share or camera JPEG frames forwarded as realtimeInput.video).
+// The Live API samples video at about one frame per second, so higher rates only cost tokens.
+const (
+	DefaultVideoFPS        = 1.0
+	MaxVideoFPS            = 2.0
+	DefaultVideoFrameBytes = 256 << 10
+	MaxVideoFrameBytes     = 1 << 20
+)
+
+// VideoPolicy is the client's video input setting (aiVoice_clients.video_*).
+type VideoPolicy struct {
+	Enabled       bool    `json:"enabled"`
+	MaxFPS        float64 `json:"maxFps"`
+	MaxFrameBytes int     `json:"maxFrameBytes"`
+}
+
+// withDefaults fills unset limits and clamps them to the platform caps.
+func (p VideoPolicy) withDefaults() VideoPolicy {
+	if p.MaxFPS <= 0 {
+		p.MaxFPS = DefaultVideoFPS
+	}
+	if p.MaxFPS > MaxVideoFPS {
+		p.MaxFPS = MaxVideoFPS
+	}
+	if p.MaxFrameBytes <= 0 {
+		p.MaxFrameBytes = DefaultVideoFrameBytes
+	}
+	if p.MaxFrameBytes > MaxVideoFrameBytes {
+		p.MaxFrameBytes = MaxVideoFrameBytes
+	}
+	return p
+}
+
+// VideoPolicy returns the client's video input setting (disabled when the config can't be loaded).
+func (c *ConfigCache) VideoPolicy(ctx context.Context, clientName string) VideoPolicy {
+	cfg, err := c.Get(ctx, clientName)
+	if err != nil || cfg == nil {
+		return VideoPolicy{}.withDefaults()
+	}
+	return cfg.Video
+}
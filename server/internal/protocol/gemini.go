@@ -10,19 +10,26 @@ type ClientMessage struct {
 }
 
 type Setup struct {
-	Model                    string            `json:"model"`
-	GenerationConfig         *GenerationConfig `json:"generationConfig,omitempty"`
-	SystemInstruction        *SystemInstruction `json:"systemInstruction,omitempty"`
-	Tools                    []Tool            `json:"tools,omitempty"`
-	InputAudioTranscription  interface{}       `json:"inputAudioTranscription,omitempty"`
-	OutputAudioTranscription interface{}       `json:"outputAudioTranscription,omitempty"`
+	Model                    string                    `json:"model"`
+	GenerationConfig         *GenerationConfig         `json:"generationConfig,omitempty"`
+	SystemInstruction        *SystemInstruction        `json:"systemInstruction,omitempty"`
+	Tools                    []Tool                    `json:"tools,omitempty"`
+	InputAudioTranscription  interface{}               `json:"inputAudioTranscription,omitempty"`
+	OutputAudioTranscription interface{}               `json:"outputAudioTranscription,omitempty"`
+	RealtimeInputConfig      *RealtimeInputConfig      `json:"realtimeInputConfig,omitempty"`
+	ContextWindowCompression *ContextWindowCompression `json:"contextWindowCompression,omitempty"`
+	Proactivity              *ProactivityConfig        `json:"proactivity,omitempty"`
 }
 
 type GenerationConfig struct {
-	ResponseModalities []string        `json:"responseModalities,omitempty"`
-	SpeechConfig       *SpeechConfig   `json:"speechConfig,omitempty"`
-	Temperature        float64         `json:"temperature,omitempty"`
-	ThinkingConfig     *ThinkingConfig `json:"thinkingConfig,omitempty"`
+	ResponseModalities    []string        `json:"responseModalities,omitempty"`
+	SpeechConfig          *SpeechConfig   `json:"speechConfig,omitempty"`
+	Temperature           float64         `json:"temperature,omitempty"`
+	TopP                  float64         `json:"topP,omitempty"`
+	TopK                  int             `json:"topK,omitempty"`
+	MaxOutputTokens       int             `json:"maxOutputTokens,omitempty"`
+	ThinkingConfig        *ThinkingConfig `json:"thinkingConfig,omitempty"`
+	EnableAffectiveDialog bool            `json:"enableAffectiveDialog,omitempty"`
 }
 
 type SpeechConfig struct {
@@ -40,6 +47,34 @@ type PrebuiltVoiceConfig struct {
 
 type ThinkingConfig struct {
 	IncludeThoughts bool `json:"includeThoughts"`
+	ThinkingBudget  *int `json:"thinkingBudget,omitempty"` // -1 = dynamic, 0 = off
+}
+
+// RealtimeInputConfig controls Gemini-side voice activity detection.
+type RealtimeInputConfig struct {
+	AutomaticActivityDetection *AutomaticActivityDetection `json:"automaticActivityDetection,omitempty"`
+}
+
+type AutomaticActivityDetection struct {
+	Disabled                 bool   `json:"disabled,omitempty"`
+	StartOfSpeechSensitivity string `json:"startOfSpeechSensitivity,omitempty"` // START_SENSITIVITY_HIGH | START_SENSITIVITY_LOW
+	EndOfSpeechSensitivity   string `json:"endOfSpeechSensitivity,omitempty"`   // END_SENSITIVITY_HIGH | END_SENSITIVITY_LOW
+	PrefixPaddingMs          int    `json:"prefixPaddingMs,omitempty"`
+	SilenceDurationMs        int    `json:"silenceDurationMs,omitempty"`
+}
+
+// ContextWindowCompression drops the oldest turns once the context exceeds TriggerTokens.
+type ContextWindowCompression struct {
+	TriggerTokens int            `json:"triggerTokens,omitempty"`
+	SlidingWindow *SlidingWindow `json:"slidingWindow"`
+}
+
+type SlidingWindow struct {
+	TargetTokens int `json:"targetTokens,omitempty"`
+}
+
+type ProactivityConfig struct {
+	ProactiveAudio bool `json:"proactiveAudio"`
 }
 
 type SystemInstruction struct {
@@ -79,7 +114,10 @@ type InlineData struct {
 }
 
 type RealtimeInput struct {
-	MediaChunks []InlineData `json:"mediaChunks"`
+	MediaChunks   []InlineData `json:"mediaChunks,omitempty"`
+	Video         *InlineData  `json:"video,omitempty"`         // Screen share / camera frame (image/jpeg)
+	ActivityStart *struct{}    `json:"activityStart,omitempty"` // Manual turn start (automatic activity detection disabled)
+	ActivityEnd   *struct{}    `json:"activityEnd,omitempty"`
 }
 
 type ToolResponse struct {
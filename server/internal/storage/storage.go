@@ -0,0 +1,102 @@
+// Package storage keeps files users upload during a session (screenshots, receipts, bills).
+// Backends implement Store; the local-disk backend is the default and the only one built in.
+//
+// The backend comes from UPLOAD_STORAGE ("local") and the local root from UPLOAD_DIR
+// (default ./data/uploads).
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultLocalDir is the local backend root when UPLOAD_DIR is unset.
+const DefaultLocalDir = "./data/uploads"
+
+// ErrInvalidKey is returned for keys that are empty, absolute or escape the store root.
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Store saves and removes files by key ("client/call/file.jpg").
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes the file; deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// FromEnv builds the store selected by UPLOAD_STORAGE.
+func FromEnv() (Store, error) {
+	switch backend := os.Getenv("UPLOAD_STORAGE"); backend {
+	case "", "local":
+		dir := os.Getenv("UPLOAD_DIR")
+		if dir == "" {
+			dir = DefaultLocalDir
+		}
+		return NewLocal(dir)
+	default:
+		return nil, fmt.Errorf("unknown UPLOAD_STORAGE %q", backend)
+	}
+}
+
+// Local stores files under a directory on the orchestrator's disk.
+type Local struct {
+	root string
+}
+
+// NewLocal creates the root directory if needed.
+func NewLocal(root string) (*Local, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, err
+	}
+	return &Local{root: root}, nil
+}
+
+func (l *Local) path(key string) (string, error) {
+	clean := path.Clean(key)
+	if key == "" || path.IsAbs(clean) || clean == "." || strings.HasPrefix(clean, "../") || clean == ".." {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(l.root, filepath.FromSlash(clean)), nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, data []byte) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		return err
+	}
+	// Written to a temporary name first so a crash never leaves a truncated file under the key
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func (l *Local) Get(ctx context.Context, key string) ([]byte, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p)
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	// Removes the call directory once its last file is gone (fails harmlessly while not empty)
+	os.Remove(filepath.Dir(p))
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"aivoice-v3/internal/protocol"
+)
+
+// handleLead envia os dados coletados ao dashboard-server, que valida contra o
+// schema do cliente. Erros de validação voltam ao modelo para que ele pergunte de novo.
+func (s *Session) handleLead(fc protocol.FunctionCall) {
+	log.Printf("📝 Tool: registrar_lead (Sessão: %s)", s.ID)
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"callId":     s.ID,
+		"clientName": s.ClientName,
+		"data":       fc.Args,
+	})
+
+	req, err := dashboardRequest("POST", "/api/leads", payload)
+	if err != nil {
+		log.Printf("❌ Erro ao registrar lead [%s]: %v", s.ID, err)
+		s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Não foi possível registrar os dados agora."})
+		return
+	}
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("❌ Erro ao registrar lead [%s]: %v", s.ID, err)
+		s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Não foi possível registrar os dados agora."})
+		return
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		var created struct {
+			ID int `json:"id"`
+		}
+		json.NewDecoder(resp.Body).Decode(&created)
+		s.respondTool(fc, map[string]interface{}{"status": "success", "leadId": created.ID, "message": "Dados registrados com sucesso."})
+	case http.StatusUnprocessableEntity:
+		var invalid struct {
+			Errors map[string]string `json:"errors"`
+		}
+		json.NewDecoder(resp.Body).Decode(&invalid)
+		log.Printf("⚠️ Lead inválido [%s]: %v", s.ID, invalid.Errors)
+		s.respondTool(fc, map[string]interface{}{
+			"status":  "invalid",
+			"errors":  invalid.Errors,
+			"message": "Alguns campos estão ausentes ou inválidos. Peça ao usuário para informá-los novamente e chame a ferramenta outra vez.",
+		})
+	default:
+		log.Printf("❌ Erro ao registrar lead [%s]: status %d", s.ID, resp.StatusCode)
+		s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Não foi possível registrar os dados agora."})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -18,6 +19,8 @@ import (
 	"github.com/joho/godotenv"
 	"golang.org/x/sync/errgroup"
 
+	"aivoice-shared/secrets"
+	"aivoice-v3/internal/keypool"
 	"aivoice-v3/internal/orchestrator"
 	"aivoice-v3/internal/protocol"
 )
@@ -27,6 +30,8 @@ var (
 		CheckOrigin: func(r *http.Request) bool { return true },
 	}
 	db *pgxpool.Pool
+	// Cache de configuração por cliente, invalidado via LISTEN/NOTIFY pelo dashboard-server
+	configCache *orchestrator.ConfigCache
 
 	bufferPool = sync.Pool{
 		New: func() interface{} {
@@ -36,6 +41,8 @@ var (
 	
 	// Mapa global para rastrear sessões ativas: map[string]*Session
 	activeSessions sync.Map
+
+	errSenderNotConfigured = errors.New("sender not configured")
 )
 
 type Session struct {
@@ -45,6 +52,15 @@ type Session struct {
 	Cancel     context.CancelFunc
 	ClientConn *websocket.Conn
 	GeminiConn *websocket.Conn
+	geminiLock sync.Mutex // Protege GeminiConn durante a troca de agente (handoff)
+	// Fechado quando a sessão com o Gemini é aberta no setup (com fallback de modelo)
+	geminiReady chan struct{}
+	Model       string // Modelo efetivamente em uso
+	// Chave da API em uso e consumo atribuído a cada chave usada na chamada
+	APIKey                keypool.Key
+	KeyUsage              map[string]*KeyUsage
+	connInput, connOutput int // usageMetadata já contabilizado na conexão atual
+	baseInput, baseOutput int // tokens de conexões anteriores (handoff, mensagens anteriores da API de chat)
 
 	ToGemini chan []byte
 	ToClient chan []byte
@@ -58,15 +74,50 @@ type Session struct {
 	StartTime      time.Time
 	WasGraceful    bool
 	ShouldTerm     bool
+	Escalated      bool
 
 	TurnAgentText string
 	TurnUserText  string
-	Status        string // Active, Completed, Interrupted
+	Status        string // Active, Completed, Interrupted, TakenOver
 
 	DurationLimit        int
 	TerminationAlertTime int
 	AlertInstruction     string
 	AlertSent            bool
+
+	// Multi-agente: agente atual, histórico de agentes da chamada e categorias da KB do agente
+	AgentName    string
+	Agents       []string
+	KBCategories []string
+
+	// Versão da configuração do agente que iniciou a chamada (aiVoice_config.version)
+	ConfigVersion int
+	// Experimento A/B e variante atribuídos à chamada
+	ExperimentID int
+	Variant      string
+
+	// Dados enviados pelo widget no setup (variáveis do template do prompt)
+	Caller      orchestrator.CallerInfo
+	Page        orchestrator.PageInfo
+	CallContext orchestrator.CallContext
+
+	// Push-to-talk: detecção automática de voz desligada; o widget marca início/fim de cada fala
+	PushToTalk   bool
+	ActivityOpen bool
+	// "audio" (voz) ou "text" (chat só por texto), escolhido pelo widget no setup
+	Modality string
+
+	// Vídeo (tela ou câmera): limites do cliente, último quadro repassado e quadros descartados
+	Video          orchestrator.VideoPolicy
+	VideoUsed      bool
+	lastVideoFrame time.Time
+	droppedFrames  int
+
+	// Sincronização com o dashboard: cada snapshot recebe um número crescente e os envios são
+	// serializados; um snapshot mais antigo que o último enviado é descartado (ver sync)
+	snapshotSeq int
+	syncLock    sync.Mutex
+	syncedSeq   int
 }
 
 func main() {
@@ -77,10 +128,34 @@ func main() {
 	if db != nil {
 		defer db.Close()
 	}
+	configCache = orchestrator.NewConfigCache(db)
+	apiKeys = keypool.FromEnv()
+	if _, err := secrets.Default(); err != nil {
+		log.Printf("⚠️ Chaves Gemini próprias dos clientes indisponíveis: %v", err)
+	}
+	if apiKeys.Len() == 0 {
+		log.Println("⚠️ Nenhuma chave em GEMINI_API_KEYS/GEMINI_API_KEY: só clientes com chave própria conseguirão conectar")
+	} else {
+		log.Printf("🔑 Pool de chaves Gemini: %d chave(s), estratégia %s", apiKeys.Len(), apiKeys.Strategy())
+	}
+	go configCache.Listen(context.Background())
+	initSenders()
+	initUploadStore()
 
 	http.HandleFunc("/ws", handleWebSocket)
 	// Endpoint para terminação forçada (beacon)
 	http.HandleFunc("/terminate", handleTerminate)
+	// Intervenção de supervisor (whisper / takeover) autenticada via JWT do Dashboard
+	http.HandleFunc("/supervisor/intervene", supervisorAuth(handleSupervisorIntervene))
+	// API de chat (texto, SSE) para integrações servidor a servidor, autenticada por chave de servidor (sk_)
+	http.HandleFunc("/v1/chat", handleChat)
+	// Webhook do WhatsApp Cloud API (mensagens de texto e notas de voz pelo mesmo fluxo da API de chat)
+	http.HandleFunc("/webhooks/whatsapp", handleWhatsAppWebhook)
+	if os.Getenv("WHATSAPP_APP_SECRET") != "" {
+		log.Println("💬 Canal WhatsApp habilitado (/webhooks/whatsapp)")
+	}
+	// Arquivos enviados pelo usuário durante a sessão (imagens e PDF), com as credenciais do /ws
+	http.HandleFunc("/upload", handleUpload)
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprint(w, "OK")
@@ -111,53 +186,7 @@ func initDB() {
 		return
 	}
 	log.Println("✅ Conectado ao PostgreSQL.")
-	
-	// Provisionamento automático do cliente se necessário
-	ensureClientExists()
-}
-
-func ensureClientExists() {
-	clientName := os.Getenv("INSTANCE_CLIENT_NAME")
-	if clientName == "" {
-		clientName = "aiVoice"
-	}
-
-	ctx := context.Background()
-	var exists bool
-	err := db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM aiVoice_clients WHERE name=$1)", clientName).Scan(&exists)
-	if err != nil {
-		log.Printf("⚠️ Erro ao verificar cliente: %v", err)
-		return
-	}
-
-	if !exists {
-		log.Printf("🆕 Criando semente automática para o cliente: %s", clientName)
-		var clientID int
-		err = db.QueryRow(ctx, "INSERT INTO aiVoice_clients (name) VALUES ($1) RETURNING id", clientName).Scan(&clientID)
-		if err != nil {
-			log.Printf("❌ Erro ao criar cliente inicial: %v", err)
-			return
-		}
-
-		// Configuração Padrão Robusta
-		defaultPrompt := fmt.Sprintf("Você é o %s, um assistente de voz avançado criado pelo estúdio TkzM.", clientName)
-		defaultKnowledge := fmt.Sprintf("Invoque esta ferramenta sempre que o usuário tiver dúvidas sobre preços, prazos ou funcionalidades específicas do %s.", clientName)
-
-		_, err = db.Exec(ctx, `
-			INSERT INTO aiVoice_config (
-				client_id, voice_name, language_code, temperature, 
-				system_prompt, docstring_tool_knowledge, 
-				docstring_tool_terminate, docstring_tool_send_link
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
-			clientID, "Aoede", "pt-BR", 0.7,
-			defaultPrompt, defaultKnowledge,
-			"Invoque esta ferramenta para encerrar a sessão de forma amigável.",
-			"Use esta ferramenta para enviar links úteis ao usuário.",
-		)
-		if err != nil {
-			log.Printf("❌ Erro ao criar configuração inicial: %v", err)
-		}
-	}
+	// O provisionamento de clientes é feito pelo dashboard-server (/api/admin/clients)
 }
 
 func handleWebSocket(w http.ResponseWriter, r *http.Request) {
@@ -167,32 +196,30 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		log.Println("❌ GEMINI_API_KEY não encontrada")
-		clientConn.Close()
+	// Tenant resolvido a partir da chave do widget (ou JWT do dashboard); sem credencial = cliente padrão
+	clientName, err := resolveClient(r.Context(), r)
+	if err != nil {
+		log.Printf("🚫 Conexão recusada: %v", err)
+		rejectConnection(clientConn, "invalid_client_key", "Chave de cliente inválida ou revogada.")
 		return
 	}
-
-	clientName := os.Getenv("INSTANCE_CLIENT_NAME")
-	if clientName == "" {
-		clientName = r.URL.Query().Get("client")
-		if clientName == "" {
-			clientName = "aiVoice"
-		}
-	}
-
-	geminiURL := fmt.Sprintf("wss://generativelanguage.googleapis.com/ws/google.ai.generativelanguage.v1alpha.GenerativeService.BidiGenerateContent?key=%s", apiKey)
-	geminiConn, _, err := websocket.DefaultDialer.Dial(geminiURL, nil)
-	if err != nil {
-		log.Printf("❌ Gemini Dial error: %v", err)
-		clientConn.Close()
+	if err := configCache.CheckClientActive(r.Context(), clientName); err != nil {
+		log.Printf("🚫 Conexão recusada para %s: %v", clientName, err)
+		rejectClientError(clientConn, err)
 		return
 	}
 
+	// A conexão com o Gemini só é aberta no setup, quando o modelo e a chave do cliente são conhecidos
+	// O callId vira parte das chaves de armazenamento (uploads): só UUIDs, na forma canônica
 	sessionID := uuid.New().String()
 	if cid := r.URL.Query().Get("callId"); cid != "" {
-		sessionID = cid
+		id, err := uuid.Parse(cid)
+		if err != nil {
+			log.Printf("🚫 Conexão recusada para %s: callId inválido %q", clientName, cid)
+			rejectConnection(clientConn, "invalid_call_id", "callId deve ser um UUID.")
+			return
+		}
+		sessionID = id.String()
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -202,24 +229,42 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		Context:    ctx,
 		Cancel:     cancel,
 		ClientConn: clientConn,
-		GeminiConn: geminiConn,
+		AgentName:  r.URL.Query().Get("agent"),
 		ToGemini:   make(chan []byte, 512),
 		ToClient:   make(chan []byte, 512),
 		StartTime:  time.Now(),
 		Transcript: []map[string]interface{}{}, // Inicialização explícita para evitar nulo
 		Status:     "Active",
+
+		geminiReady: make(chan struct{}),
 	}
 
+	// Um callId já ativo não pode ser assumido por outra conexão (terminate, uploads e supervisão o usam como chave)
+	if _, loaded := activeSessions.LoadOrStore(s.ID, s); loaded {
+		log.Printf("🚫 Conexão recusada para %s: callId %s já está em uso", clientName, s.ID)
+		rejectConnection(clientConn, "call_id_in_use", "Já existe uma sessão ativa com este callId.")
+		cancel()
+		return
+	}
 	log.Printf("🔗 Sessão iniciada: %s (Client: %s)", s.ID, s.ClientName)
-	activeSessions.Store(s.ID, s)
 
 	g, ctx := errgroup.WithContext(ctx)
 
 	g.Go(func() error {
+		// Mensagens anteriores ao setup (ex.: primeiros chunks de áudio) aguardam a sessão com o Gemini
+		select {
+		case <-s.geminiReady:
+		case <-ctx.Done():
+			return nil
+		}
 		for {
 			select {
 			case msg := <-s.ToGemini:
-				if err := s.GeminiConn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				conn := s.gemini()
+				if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+					if conn != s.gemini() {
+						continue // Conexão trocada durante um handoff; descarta o chunk
+					}
 					return fmt.Errorf("Gemini Write error: %w", err)
 				}
 			case <-ctx.Done():
@@ -234,7 +279,7 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		// Forçar fechamento das conexões para desbloquear as goroutines de leitura
 		// Isso garante que g.Wait() retorne e s.Cleanup() seja executado.
 		s.ClientConn.Close()
-		s.GeminiConn.Close()
+		s.closeGemini()
 	}()
 
 	g.Go(func() error {
@@ -275,36 +320,121 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 			var clientMsg protocol.ClientMessage
 			switch msg.Type {
 			case "setup":
-				setupPayload, err := orchestrator.GetInitialSetup(ctx, db, s.ClientName)
+				select {
+				case <-s.geminiReady:
+					log.Printf("⚠️ Setup repetido ignorado [%s]", s.ID)
+					continue
+				default:
+				}
+				// O widget pode escolher o agente no setup ({"agent": "vendas"}); vazio = agente padrão.
+				// caller/page alimentam as variáveis do template do prompt ({{.Caller.Name}}, {{.Page.URL}});
+				// caller.id (ou context.userId) identifica o cliente recorrente para a memória entre sessões
+				// modality "text" abre um chat só por texto com o mesmo agente (padrão: voz)
+				var setupReq struct {
+					Agent    string                  `json:"agent"`
+					Caller   orchestrator.CallerInfo `json:"caller"`
+					Page     orchestrator.PageInfo   `json:"page"`
+					Context  json.RawMessage         `json:"context"`
+					Modality string                  `json:"modality"`
+				}
+				json.Unmarshal(msg.Payload, &setupReq)
+				if setupReq.Agent != "" {
+					s.AgentName = setupReq.Agent
+				}
+				// Contexto do visitante: só os campos liberados para o cliente são aceitos
+				callCtx, dropped := orchestrator.ParseCallContext(setupReq.Context, configCache.ContextFields(ctx, s.ClientName))
+				if len(dropped) > 0 {
+					log.Printf("⚠️ Contexto [%s]: campos ignorados (fora da allowlist ou inválidos): %v", s.ID, dropped)
+				}
+				s.TranscriptLock.Lock()
+				s.Caller, s.Page, s.CallContext = setupReq.Caller, setupReq.Page, callCtx
+				s.Modality = orchestrator.NormalizeModality(setupReq.Modality)
+				s.TranscriptLock.Unlock()
+
+				setupPayload, cfg, err := orchestrator.GetInitialSetup(ctx, configCache, s.setupRequest(s.AgentName))
+				if errors.Is(err, orchestrator.ErrClientSuspended) || errors.Is(err, orchestrator.ErrClientNotFound) {
+					// Cliente suspenso durante a conexão: avisa o widget e encerra após o envio
+					log.Printf("🚫 Setup recusado para %s: %v", s.ClientName, err)
+					s.ToClient <- errorFrame(clientErrorInfo(err))
+					time.AfterFunc(time.Second, s.Cancel)
+					continue
+				}
 				if err != nil {
 					log.Printf("⚠️ Erro no setup: %v", err)
 				}
-				
-				// Extrai limites para a sessão (caso tenhamos carregado)
-				// NOTA: Para ser mais limpo, setup.go poderia retornar o config também, mas vamos simplificar buscando novamente ou aceitando que setupPayload já tem o necessário.
-				// Como GetInitialSetup já buscou do DB, vamos garantir que a s.Session tenha os limites.
-				// Para evitar redundância, vamos buscar os limites extras aqui ou alterar GetInitialSetup.
-				// Vamos buscar rapidamente os limites extras.
-				var dLimit, tAlert int
-				var pAlert string
-				db.QueryRow(ctx, "SELECT duration_limit, termination_alert_time, COALESCE(proactive_alert_instruction, '') FROM aiVoice_config c JOIN aiVoice_clients cl ON c.client_id = cl.id WHERE cl.name = $1", s.ClientName).Scan(&dLimit, &tAlert, &pAlert)
-				s.DurationLimit = dLimit
-				s.TerminationAlertTime = tAlert
-				s.AlertInstruction = pAlert
-
-				clientMsg.Setup = setupPayload
+				s.applyAgentConfig(cfg)
+
+				// Informa o widget se o cliente usa push-to-talk (botão de falar em vez de VAD) e a modalidade aceita
+				// e se pode enviar quadros de tela/câmera (com a taxa e o tamanho máximos) e arquivos (/upload)
+				pushToTalk := s.Modality == orchestrator.ModalityAudio && configCache.PushToTalk(ctx, s.ClientName)
+				video := configCache.VideoPolicy(ctx, s.ClientName)
+				uploads := configCache.UploadPolicy(ctx, s.ClientName).Enabled && uploadStore != nil
+				s.TranscriptLock.Lock()
+				s.PushToTalk = pushToTalk
+				s.Video = video
+				s.TranscriptLock.Unlock()
+				sessionCfg, _ := json.Marshal(map[string]interface{}{
+					"type":    "session_config",
+					"payload": map[string]interface{}{"pushToTalk": pushToTalk, "modality": s.Modality, "video": video, "uploads": uploads},
+				})
+				s.ToClient <- sessionCfg
+
+				// Abre a sessão com o Gemini tentando o modelo principal e depois os de fallback
+				conn, model, key, err := connectGemini(ctx, s.ID, s.ClientName, s.Modality, setupPayload)
+				if err != nil {
+					log.Printf("❌ Setup [%s]: nenhum modelo disponível para %s", s.ID, s.ClientName)
+					s.ToClient <- errorFrame("model_unavailable", "O atendimento está indisponível no momento. Tente novamente em instantes.")
+					time.AfterFunc(time.Second, s.Cancel)
+					continue
+				}
+				s.geminiLock.Lock()
+				s.GeminiConn = conn
+				s.geminiLock.Unlock()
+				s.TranscriptLock.Lock()
+				s.Model = model
+				s.TranscriptLock.Unlock()
+				s.useAPIKey(key)
+				close(s.geminiReady)
+				s.ToClient <- []byte(`{"setupComplete":{}}`)
+
+				// Injeção Proativa Silenciosa (Despertar do Agente após Setup)
+				log.Printf("✨ Setup Complete do Gemini (%s). Enviando saudação proativa silenciosa...", model)
+				proactiveMsg, _ := json.Marshal(protocol.ClientMessage{
+					ClientContent: &protocol.ClientContent{
+						Turns:        []protocol.Turn{{Role: "user", Parts: []protocol.Part{{Text: "Olá"}}}},
+						TurnComplete: true,
+					},
+				})
+				s.ToGemini <- proactiveMsg
 			case "realtimeInput", "realtime_input":
 				var data struct {
 					Audio struct {
 						Data     string `json:"data"`
 						MimeType string `json:"mimeType"`
 					} `json:"audio"`
+					// Quadro JPEG de compartilhamento de tela ou câmera (source: "screen" | "camera")
+					Video *struct {
+						Data     string `json:"data"`
+						MimeType string `json:"mimeType"`
+						Source   string `json:"source"`
+					} `json:"video"`
+				}
+				if err := json.Unmarshal(msg.Payload, &data); err != nil {
+					continue
 				}
-				if err := json.Unmarshal(msg.Payload, &data); err == nil {
+				if data.Video != nil {
+					clientMsg.RealtimeInput = s.videoFrame(data.Video.MimeType, data.Video.Data, data.Video.Source)
+				} else if s.Modality == orchestrator.ModalityText {
+					continue // Chat por texto: o modelo não recebe áudio
+				} else {
 					clientMsg.RealtimeInput = &protocol.RealtimeInput{
 						MediaChunks: []protocol.InlineData{{MimeType: data.Audio.MimeType, Data: data.Audio.Data}},
 					}
 				}
+			case "activity_start", "activityStart":
+				clientMsg.RealtimeInput = s.activitySignal(true)
+			case "activity_end", "activityEnd":
+				clientMsg.RealtimeInput = s.activitySignal(false)
 			case "clientContent", "client_content":
 				var content protocol.ClientContent
 				if err := json.Unmarshal(msg.Payload, &content); err == nil {
@@ -348,9 +478,18 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	})
 
 	g.Go(func() error {
+		select {
+		case <-s.geminiReady:
+		case <-ctx.Done():
+			return nil
+		}
 		for {
-			_, message, err := s.GeminiConn.ReadMessage()
+			conn := s.gemini()
+			_, message, err := conn.ReadMessage()
 			if err != nil {
+				if conn != s.gemini() && ctx.Err() == nil {
+					continue // Handoff: passa a ler da nova conexão
+				}
 				return fmt.Errorf("Gemini Read error: %w", err)
 			}
 
@@ -363,21 +502,6 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
-			// Injeção Proativa Silenciosa (Despertar do Agente após Setup)
-			if serverMsg.SetupComplete != nil {
-				log.Printf("✨ Setup Complete do Gemini. Enviando saudação proativa silenciosa...")
-				go func() {
-					proactiveMsg := protocol.ClientMessage{
-						ClientContent: &protocol.ClientContent{
-							Turns:        []protocol.Turn{{Role: "user", Parts: []protocol.Part{{Text: "Olá"}}}},
-							TurnComplete: true,
-						},
-					}
-					b, _ := json.Marshal(proactiveMsg)
-					s.ToGemini <- b
-				}()
-			}
-
 			s.ToClient <- message
 
 			if serverMsg.ToolCall != nil {
@@ -396,10 +520,7 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 			}
 
 			if serverMsg.UsageMetadata != nil {
-				s.TranscriptLock.Lock()
-				s.InputTokens = serverMsg.UsageMetadata.PromptTokenCount
-				s.OutputTokens = serverMsg.UsageMetadata.CandidatesTokenCount
-				s.TranscriptLock.Unlock()
+				s.recordUsage(serverMsg.UsageMetadata.PromptTokenCount, serverMsg.UsageMetadata.CandidatesTokenCount)
 			}
 		}
 	})
@@ -408,7 +529,7 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		log.Printf("🔌 Sessão terminada: %v", err)
 	}
 
-	activeSessions.Delete(s.ID)
+	activeSessions.CompareAndDelete(s.ID, s)
 	s.Cleanup()
 }
 
@@ -417,7 +538,7 @@ func (s *Session) handleToolCall(fc protocol.FunctionCall) {
 	if fc.Name == "consultar_base_conhecimento" {
 		query, _ := fc.Args["query"].(string)
 		category, _ := fc.Args["category"].(string)
-		toolResp, _ := callRAG(query, category)
+		toolResp, _ := callRAG(s.ClientName, query, category, s.kbCategories())
 		
 		resp := protocol.ClientMessage{
 			ToolResponse: &protocol.ToolResponse{
@@ -444,11 +565,33 @@ func (s *Session) handleToolCall(fc protocol.FunctionCall) {
 
 		s.TranscriptLock.Lock()
 		s.WasGraceful = true
-		s.Status = "Completed"
+		if s.Status == "Active" {
+			s.Status = "Completed" // Preserva "TakenOver" quando o supervisor assumiu
+		}
 		s.ShouldTerm = true
 		s.TranscriptLock.Unlock()
 	}
 
+	if fc.Name == "transferir_para_agente" {
+		s.handleAgentTransfer(fc)
+	}
+
+	if fc.Name == "transferir_para_humano" {
+		s.handleEscalation(fc)
+	}
+
+	if fc.Name == "registrar_lead" {
+		s.handleLead(fc)
+	}
+
+	if fc.Name == "consultar_horarios" || fc.Name == "agendar_horario" || fc.Name == "confirmar_reserva" || fc.Name == "cancelar_reserva" {
+		s.handleScheduling(fc)
+	}
+
+	if fc.Name == "enviar_email" || fc.Name == "enviar_sms" {
+		s.handleNotification(fc)
+	}
+
 	if fc.Name == "sendLink" {
 		url, _ := fc.Args["url"].(string)
 		alias, _ := fc.Args["alias"].(string)
@@ -510,7 +653,7 @@ func (s *Session) processServerContent(sc *protocol.ServerContent) {
 
 	if sc.ModelTurn != nil {
 		for _, p := range sc.ModelTurn.Parts {
-			if p.Text != "" {
+			if p.Text != "" && !p.Thought {
 				s.TurnAgentText += p.Text
 			}
 		}
@@ -551,11 +694,12 @@ func (s *Session) processServerContent(sc *protocol.ServerContent) {
 			}
 		}
 
-		// CHECKPOINT: Sincroniza o histórico, tokens e duração a cada fim de turno
-		go func(id string, cName string, t []map[string]interface{}, it, ot int, st time.Time, status string) {
-			duration := int(time.Since(st).Seconds())
-			syncWithDashboard(id, cName, t, duration, it, ot, status)
-		}(s.ID, s.ClientName, append([]map[string]interface{}{}, s.Transcript...), s.InputTokens, s.OutputTokens, s.StartTime, s.Status)
+		// CHECKPOINT: Sincroniza o histórico, tokens e duração a cada fim de turno. Na API de chat (sem
+		// ClientConn) quem sincroniza é persistChatTurn, antes de liberar a conversa para a próxima mensagem;
+		// um checkpoint assíncrono ali poderia chegar depois e gravar um histórico mais antigo.
+		if s.ClientConn != nil {
+			go s.sync(s.snapshot())
+		}
 		
 		if s.ShouldTerm {
 			log.Printf("👋 Encerrando sessão amigavelmente (TurnComplete detectado): %s", s.ID)
@@ -627,50 +771,107 @@ func handleTerminate(w http.ResponseWriter, r *http.Request) {
 func (s *Session) Cleanup() {
 	s.Cancel()
 	s.ClientConn.Close()
-	s.GeminiConn.Close()
+	s.closeGemini()
 
 	s.TranscriptLock.Lock()
-	duration := int(time.Since(s.StartTime).Seconds())
 	if s.Status == "Active" {
 		s.Status = "Interrupted"
 	}
-	currentStatus := s.Status
-	currentTranscript := append([]map[string]interface{}{}, s.Transcript...)
-	inputTokens := s.InputTokens
-	outputTokens := s.OutputTokens
+	snap := s.snapshot()
+	identity := orchestrator.CallerIdentity(s.Caller, s.CallContext)
+	dropped := s.droppedFrames
 	s.TranscriptLock.Unlock()
 
-	log.Printf("🏁 Cleanup Sessão: %s | Status: %s | Msgs: %d", s.ID, currentStatus, len(currentTranscript))
-	syncWithDashboard(s.ID, s.ClientName, currentTranscript, duration, inputTokens, outputTokens, currentStatus)
+	log.Printf("🏁 Cleanup Sessão: %s | Status: %s | Msgs: %d", s.ID, snap.Status, len(snap.Transcript))
+	if dropped > 0 {
+		log.Printf("🖥️ Sessão %s: %d quadro(s) de vídeo descartado(s) (limite de taxa/tamanho)", s.ID, dropped)
+	}
+	s.sync(snap)
+	// Memória do cliente recorrente (resumo via Gemini; não bloqueia o encerramento)
+	go updateCallerMemory(s.ClientName, identity, snap)
+}
+
+// CallSnapshot é o estado da chamada enviado ao Dashboard em cada checkpoint.
+type CallSnapshot struct {
+	CallID          string                   `json:"callId"`
+	ClientName      string                   `json:"clientName"`
+	Transcript      []map[string]interface{} `json:"newTranscript"`
+	DurationSeconds int                      `json:"durationSeconds"`
+	InputTokens     int                      `json:"inputTokens"`
+	OutputTokens    int                      `json:"outputTokens"`
+	Status          string                   `json:"status"`
+	Escalated       bool                     `json:"escalated"`
+	Agents          []string                 `json:"agents"`
+	ConfigVersion   int                      `json:"configVersion"`
+	ExperimentID    int                      `json:"experimentId,omitempty"`
+	Variant         string                   `json:"variant,omitempty"`
+	Model           string                   `json:"model,omitempty"`
+	Modality        string                   `json:"modality,omitempty"`
+	Video           bool                     `json:"video,omitempty"`
+	KeyUsage        []KeyUsage               `json:"keyUsage,omitempty"`
+	Context         *orchestrator.CallContext `json:"context,omitempty"`
+
+	seq int // ordem do snapshot na sessão (não enviado)
 }
 
-func syncWithDashboard(sessionID string, clientName string, transcript []map[string]interface{}, duration, inputTokens, outputTokens int, status string) {
-	dashboardURL := os.Getenv("DASHBOARD_INTERNAL_URL")
-	if dashboardURL == "" {
-		dashboardURL = "http://dashboard-server:8081"
+// snapshot copia o estado atual da sessão (o chamador deve deter TranscriptLock).
+func (s *Session) snapshot() CallSnapshot {
+	s.snapshotSeq++
+	return CallSnapshot{
+		CallID:          s.ID,
+		ClientName:      s.ClientName,
+		Transcript:      append([]map[string]interface{}{}, s.Transcript...),
+		DurationSeconds: int(time.Since(s.StartTime).Seconds()),
+		InputTokens:     s.InputTokens,
+		OutputTokens:    s.OutputTokens,
+		Status:          s.Status,
+		Escalated:       s.Escalated,
+		Agents:          append([]string{}, s.Agents...),
+		ConfigVersion:   s.ConfigVersion,
+		ExperimentID:    s.ExperimentID,
+		Variant:         s.Variant,
+		Model:           s.Model,
+		Modality:        s.Modality,
+		Video:           s.VideoUsed,
+		KeyUsage:        s.keyUsageList(),
+		Context:         callContextOrNil(s.CallContext),
+		seq:             s.snapshotSeq,
 	}
-	
-	if transcript == nil {
-		transcript = []map[string]interface{}{}
+}
+
+// sync envia o snapshot ao dashboard na ordem em que foram tirados: o checkpoint assíncrono de fim de
+// turno pode chegar depois do sync final (chat ou encerramento) e, nesse caso, é descartado em vez de
+// sobrescrever o histórico com uma versão mais antiga.
+func (s *Session) sync(snap CallSnapshot) {
+	s.syncLock.Lock()
+	defer s.syncLock.Unlock()
+	if snap.seq <= s.syncedSeq {
+		log.Printf("⏭️ Dash Sync [%s]: snapshot %d ignorado (já enviado o %d)", s.ID, snap.seq, s.syncedSeq)
+		return
 	}
+	syncWithDashboard(snap)
+	s.syncedSeq = snap.seq
+}
+
+func syncWithDashboard(snap CallSnapshot) {
+	sessionID := snap.CallID
 
-	syncPayload := map[string]interface{}{
-		"callId":          sessionID,
-		"clientName":      clientName,
-		"newTranscript":   transcript,
-		"durationSeconds": duration,
-		"inputTokens":     inputTokens,
-		"outputTokens":    outputTokens,
-		"status":          status,
+	if snap.Transcript == nil {
+		snap.Transcript = []map[string]interface{}{}
 	}
 
-	payloadBytes, _ := json.Marshal(syncPayload)
+	payloadBytes, _ := json.Marshal(snap)
 	
 	client := http.Client{
 		Timeout: 5 * time.Second,
 	}
 	
-	resp, err := client.Post(dashboardURL+"/api/calls/sync", "application/json", bytes.NewBuffer(payloadBytes))
+	req, err := dashboardRequest("POST", "/api/calls/sync", payloadBytes)
+	if err != nil {
+		log.Printf("❌ Dash Error [%s]: %v", sessionID, err)
+		return
+	}
+	resp, err := client.Do(req)
 	if err == nil {
 		defer resp.Body.Close()
 		log.Printf("✅ Dash Sync [%s]: OK (Status API: %d)", sessionID, resp.StatusCode)
@@ -679,23 +880,65 @@ func syncWithDashboard(sessionID string, clientName string, transcript []map[str
 	}
 }
 
-func callRAG(query, category string) (map[string]interface{}, error) {
-	dashboardURL := os.Getenv("DASHBOARD_INTERNAL_URL")
-	if dashboardURL == "" {
-		dashboardURL = "http://dashboard-server:8080"
+// dashboardInternalURL resolve o endereço interno do dashboard-server (rede Docker).
+func dashboardInternalURL() string {
+	if u := os.Getenv("DASHBOARD_INTERNAL_URL"); u != "" {
+		return u
 	}
+	return "http://dashboard-server:8081"
+}
 
+// dashboardRequest monta uma chamada à API interna do dashboard-server; o cabeçalho X-Internal-Secret
+// (INTERNAL_API_SECRET, o mesmo nos dois serviços) é o que separa o orquestrador de um chamador externo.
+func dashboardRequest(method, path string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(method, dashboardInternalURL()+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("X-Internal-Secret", os.Getenv("INTERNAL_API_SECRET"))
+	return req, nil
+}
+
+// respondTool envia a resposta de uma ferramenta de volta ao Gemini.
+func (s *Session) respondTool(fc protocol.FunctionCall, response map[string]interface{}) {
+	resp := protocol.ClientMessage{
+		ToolResponse: &protocol.ToolResponse{
+			FunctionResponses: []protocol.FunctionResponse{
+				{Name: fc.Name, ID: fc.ID, Response: response},
+			},
+		},
+	}
+	b, _ := json.Marshal(resp)
+	s.ToGemini <- b
+}
+
+// callRAG consulta a base de conhecimento do cliente da sessão. agentCategories restringe a busca às categorias do agente atual.
+func callRAG(clientName, query, category string, agentCategories []string) (map[string]interface{}, error) {
 	if category == "" {
 		category = "all"
 	}
-	searchURL := fmt.Sprintf("%s/api/knowledge/search?q=%s&category=%s", dashboardURL, url.QueryEscape(query), url.QueryEscape(category))
+	q := url.Values{"client": {clientName}, "q": {query}, "category": {category}}
+	for _, c := range agentCategories {
+		q.Add("categories", c)
+	}
 
-	resp, err := http.Get(searchURL)
+	req, err := dashboardRequest("GET", "/api/knowledge/search?"+q.Encode(), nil)
+	if err != nil {
+		return map[string]interface{}{"error": "Erro de conexão"}, err
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
 	if err != nil {
 		return map[string]interface{}{"error": "Erro de conexão"}, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusBadRequest {
+		return map[string]interface{}{"error": "Categoria inválida. Busque novamente com uma das categorias disponíveis ou sem categoria."}, fmt.Errorf("status: %d", resp.StatusCode)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return map[string]interface{}{"error": "Erro na busca"}, fmt.Errorf("status: %d", resp.StatusCode)
 	}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"aivoice-v3/internal/orchestrator"
+)
+
+// updateCallerMemory resume a transcrição final e atualiza a memória do cliente recorrente
+// (chamado no Cleanup; só para clientes com memória ativada e chamadores identificados).
+func updateCallerMemory(clientName, identity string, snap CallSnapshot) {
+	if identity == "" || !hasUserTurn(snap.Transcript) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
+	defer cancel()
+
+	policy := configCache.MemoryPolicy(ctx, clientName)
+	if !policy.Enabled {
+		return
+	}
+	previous, err := orchestrator.LoadCallerMemory(ctx, db, clientName, identity, policy)
+	if err != nil {
+		log.Printf("⚠️ Memória [%s]: erro ao ler memória anterior de %s: %v", snap.CallID, identity, err)
+		return
+	}
+	key, err := apiKeys.Acquire(clientAPIKey(ctx, clientName))
+	if err != nil {
+		log.Printf("❌ Memória [%s]: %v", snap.CallID, err)
+		return
+	}
+	mem, err := orchestrator.SummarizeCallerMemory(ctx, key.Secret, previous, snap.Transcript)
+	if err != nil {
+		log.Printf("❌ Memória [%s]: falha ao resumir a conversa: %v", snap.CallID, err)
+		return
+	}
+	mem.Identity = identity
+	mem.LastCallID = snap.CallID
+	if err := orchestrator.SaveCallerMemory(ctx, db, clientName, mem, policy); err != nil {
+		log.Printf("❌ Memória [%s]: erro ao salvar: %v", snap.CallID, err)
+		return
+	}
+	log.Printf("🧠 Memória [%s]: atualizada para %s (%d fatos, %d pendências)", snap.CallID, identity, len(mem.KeyFacts), len(mem.OpenIssues))
+}
+
+func hasUserTurn(transcript []map[string]interface{}) bool {
+	for _, m := range transcript {
+		if m["role"] == "user" {
+			return true
+		}
+	}
+	return false
+}
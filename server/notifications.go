@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"aivoice-v3/internal/notify"
+	"aivoice-v3/internal/protocol"
+)
+
+// senders disponíveis por canal ("email", "sms"), configurados via env no boot.
+var senders = map[string]notify.Sender{}
+
+func initSenders() {
+	if s := notify.NewSMTPSenderFromEnv(); s != nil {
+		senders[s.Channel()] = s
+		log.Printf("✉️ Sender de email habilitado (SMTP %s:%s)", s.Host, s.Port)
+	}
+	if s := notify.NewHTTPSMSSenderFromEnv(); s != nil {
+		senders[s.Channel()] = s
+		log.Println("📱 Sender de SMS habilitado (HTTP)")
+	}
+}
+
+// handleNotification atende enviar_email e enviar_sms: renderiza o template do cliente,
+// envia pelo sender do canal e registra o status de entrega na chamada.
+func (s *Session) handleNotification(fc protocol.FunctionCall) {
+	channel := "email"
+	if fc.Name == "enviar_sms" {
+		channel = "sms"
+	}
+
+	to, _ := fc.Args["to"].(string)
+	templateName, _ := fc.Args["template"].(string)
+	data := notify.TemplateData{ClientName: s.ClientName, CallID: s.ID}
+	data.Name, _ = fc.Args["name"].(string)
+	data.Message, _ = fc.Args["message"].(string)
+	data.Code, _ = fc.Args["code"].(string)
+	data.When, _ = fc.Args["when"].(string)
+
+	log.Printf("📨 Tool: %s [%s] -> %s (Sessão: %s)", fc.Name, templateName, to, s.ID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	err := s.deliverNotification(ctx, channel, templateName, to, data)
+	if logErr := notify.LogDelivery(ctx, db, s.ID, s.ClientName, channel, templateName, to, err); logErr != nil {
+		log.Printf("⚠️ Erro ao registrar entrega [%s]: %v", s.ID, logErr)
+	}
+
+	if err != nil {
+		log.Printf("❌ Falha no envio %s [%s]: %v", channel, s.ID, err)
+		s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Não foi possível enviar agora. Informe ao usuário e ofereça outra alternativa."})
+		return
+	}
+	s.respondTool(fc, map[string]interface{}{"status": "success", "message": "Mensagem enviada com sucesso."})
+}
+
+func (s *Session) deliverNotification(ctx context.Context, channel, templateName, to string, data notify.TemplateData) error {
+	sender, ok := senders[channel]
+	if !ok {
+		return errSenderNotConfigured
+	}
+	tpl, err := notify.LoadTemplate(ctx, db, s.ClientName, channel, templateName)
+	if err != nil {
+		return err
+	}
+	msg, err := tpl.Render(to, data)
+	if err != nil {
+		return err
+	}
+	return sender.Send(ctx, msg)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"aivoice-v3/internal/protocol"
+)
+
+// activitySignal traduz activity_start / activity_end do widget (modo push-to-talk) no sinal
+// realtimeInput correspondente e registra a fronteira do turno na transcrição.
+// Sem push-to-talk o Gemini faz a detecção automática e os sinais são ignorados.
+func (s *Session) activitySignal(start bool) *protocol.RealtimeInput {
+	s.TranscriptLock.Lock()
+	defer s.TranscriptLock.Unlock()
+
+	if !s.PushToTalk {
+		log.Printf("⚠️ Sinal de atividade ignorado [%s]: push-to-talk desativado para %s", s.ID, s.ClientName)
+		return nil
+	}
+	if s.ActivityOpen == start {
+		return nil // Sinal repetido (ex.: botão solto duas vezes)
+	}
+	s.ActivityOpen = start
+
+	event, text, input := "activity_end", "Usuário soltou o botão de fala", &protocol.RealtimeInput{ActivityEnd: &struct{}{}}
+	if start {
+		event, text, input = "activity_start", "Usuário pressionou o botão de fala", &protocol.RealtimeInput{ActivityStart: &struct{}{}}
+	}
+	s.Transcript = append(s.Transcript, map[string]interface{}{
+		"id":        uuid.New().String()[:8],
+		"role":      "system",
+		"event":     event,
+		"text":      text,
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+	return input
+}
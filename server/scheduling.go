@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"aivoice-v3/internal/protocol"
+)
+
+// handleScheduling atende consultar_horarios, agendar_horario, confirmar_reserva e cancelar_reserva
+// usando a agenda do dashboard-server.
+func (s *Session) handleScheduling(fc protocol.FunctionCall) {
+	client := http.Client{Timeout: 5 * time.Second}
+	resourceID := 0
+	if v, ok := fc.Args["resource_id"].(float64); ok {
+		resourceID = int(v)
+	}
+
+	switch fc.Name {
+	case "consultar_horarios":
+		date, _ := fc.Args["date"].(string)
+		log.Printf("📅 Tool: consultar_horarios [%s] (Sessão: %s)", date, s.ID)
+
+		q := url.Values{"client": {s.ClientName}, "date": {date}}
+		if resourceID > 0 {
+			q.Set("resourceId", fmt.Sprint(resourceID))
+		}
+		req, err := dashboardRequest("GET", "/api/schedule/availability?"+q.Encode(), nil)
+		if err != nil {
+			s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Agenda indisponível no momento."})
+			return
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Agenda indisponível no momento."})
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Data inválida. Use o formato AAAA-MM-DD."})
+			return
+		}
+
+		var availability []map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&availability)
+		s.respondTool(fc, map[string]interface{}{"status": "success", "availability": availability})
+		return
+
+	case "confirmar_reserva", "cancelar_reserva":
+		s.handleBookingAction(fc)
+		return
+	}
+
+	start, _ := fc.Args["start"].(string)
+	name, _ := fc.Args["customer_name"].(string)
+	phone, _ := fc.Args["customer_phone"].(string)
+	log.Printf("📅 Tool: agendar_horario [%d @ %s] (Sessão: %s)", resourceID, start, s.ID)
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"clientName":    s.ClientName,
+		"callId":        s.ID,
+		"resourceId":    resourceID,
+		"start":         start,
+		"customerName":  name,
+		"customerPhone": phone,
+	})
+	req, err := dashboardRequest("POST", "/api/schedule/bookings", payload)
+	if err != nil {
+		s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Agenda indisponível no momento."})
+		return
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Agenda indisponível no momento."})
+		return
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		var booking struct {
+			Code         string `json:"code"`
+			ResourceName string `json:"resourceName"`
+		}
+		json.NewDecoder(resp.Body).Decode(&booking)
+		s.respondTool(fc, map[string]interface{}{
+			"status":       "success",
+			"bookingCode":  booking.Code,
+			"resourceName": booking.ResourceName,
+			"start":        start,
+			"message":      "Horário reservado. Informe o código da reserva ao usuário.",
+		})
+	case http.StatusConflict:
+		s.respondTool(fc, map[string]interface{}{"status": "unavailable", "message": "Este horário não está mais disponível. Consulte os horários novamente e ofereça outra opção."})
+	default:
+		s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Não foi possível reservar. Verifique o recurso e o formato AAAA-MM-DDTHH:MM."})
+	}
+}
+
+// handleBookingAction confirma ou cancela uma reserva do cliente da sessão pelo código informado pelo usuário.
+func (s *Session) handleBookingAction(fc protocol.FunctionCall) {
+	code, _ := fc.Args["code"].(string)
+	code = strings.ToUpper(strings.TrimSpace(code))
+	action, done := "confirm", "confirmada"
+	if fc.Name == "cancelar_reserva" {
+		action, done = "cancel", "cancelada"
+	}
+	log.Printf("📅 Tool: %s [%s] (Sessão: %s)", fc.Name, code, s.ID)
+	if code == "" {
+		s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Peça ao usuário o código da reserva."})
+		return
+	}
+
+	q := url.Values{"client": {s.ClientName}, "code": {code}}
+	req, err := dashboardRequest("POST", "/api/schedule/bookings/"+action+"?"+q.Encode(), nil)
+	if err != nil {
+		s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Agenda indisponível no momento."})
+		return
+	}
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Agenda indisponível no momento."})
+		return
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		s.respondTool(fc, map[string]interface{}{"status": "success", "bookingCode": code, "message": "Reserva " + done + ". Avise o usuário."})
+	case http.StatusNotFound:
+		s.respondTool(fc, map[string]interface{}{"status": "not_found", "message": "Nenhuma reserva pendente com este código. Confirme o código com o usuário."})
+	default:
+		s.respondTool(fc, map[string]interface{}{"status": "error", "message": "Não foi possível atualizar a reserva agora."})
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"aivoice-v3/internal/protocol"
+)
+
+// SupervisorIntervention é o corpo aceito por /supervisor/intervene.
+// Mode "whisper" injeta uma instrução oculta; "takeover" faz o agente se despedir e encerrar.
+type SupervisorIntervention struct {
+	SessionID   string `json:"sessionId"`
+	Mode        string `json:"mode"`
+	Instruction string `json:"instruction"`
+}
+
+const takeoverGracePeriod = 20 * time.Second
+
+// supervisorAuth valida o JWT emitido pelo dashboard-server (mesmo JWT_SECRET)
+// e repassa a identidade do supervisor (email e id do usuário) ao handler.
+func supervisorAuth(next func(w http.ResponseWriter, r *http.Request, supervisor string, userID int)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		tokenString := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if tokenString == "" {
+			http.Error(w, "Authorization header required", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := parseDashboardToken(tokenString)
+		if err != nil {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		supervisor, _ := claims["email"].(string)
+		if supervisor == "" {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+		userID, _ := claims["user_id"].(float64)
+
+		next(w, r, supervisor, int(userID))
+	}
+}
+
+func handleSupervisorIntervene(w http.ResponseWriter, r *http.Request, supervisor string, userID int) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SupervisorIntervention
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.SessionID == "" {
+		req.SessionID = r.URL.Query().Get("sessionId")
+	}
+	if req.SessionID == "" {
+		http.Error(w, "Missing sessionId", http.StatusBadRequest)
+		return
+	}
+
+	val, ok := activeSessions.Load(req.SessionID)
+	if !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	s := val.(*Session)
+
+	// O supervisor só pode intervir em sessões dos clientes aos quais está vinculado
+	if !userHasClient(r.Context(), userID, s.ClientName) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	switch req.Mode {
+	case "whisper", "":
+		if strings.TrimSpace(req.Instruction) == "" {
+			http.Error(w, "instruction is required", http.StatusBadRequest)
+			return
+		}
+		s.Whisper(supervisor, req.Instruction)
+	case "takeover":
+		s.TakeOver(supervisor, req.Instruction)
+	default:
+		http.Error(w, "Invalid mode", http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "OK")
+}
+
+// Whisper envia uma instrução oculta ao modelo. Ela vai direto para o Gemini
+// (não passa pelo loop do cliente), portanto não é persistida como fala do usuário.
+func (s *Session) Whisper(supervisor, instruction string) {
+	log.Printf("🕵️ Whisper do supervisor %s na sessão %s", supervisor, s.ID)
+	s.recordIntervention(supervisor, "whisper", instruction)
+	s.sendSupervisorTurn(fmt.Sprintf("SUPERVISOR (instrução interna, não mencione ao usuário): %s", instruction))
+}
+
+// TakeOver faz o agente se despedir educadamente e encerra a chamada com status "TakenOver".
+func (s *Session) TakeOver(supervisor, instruction string) {
+	log.Printf("🙋 Supervisor %s assumindo a sessão %s", supervisor, s.ID)
+	if instruction == "" {
+		instruction = "SISTEMA: Um atendente humano assumirá este atendimento. Avise o usuário educadamente, despeça-se e não faça novas perguntas."
+	}
+
+	s.TranscriptLock.Lock()
+	s.Status = "TakenOver"
+	s.ShouldTerm = true
+	s.TranscriptLock.Unlock()
+
+	s.recordIntervention(supervisor, "takeover", instruction)
+	s.sendSupervisorTurn(instruction)
+
+	// Garantia: se o modelo não concluir o turno de despedida, encerra mesmo assim.
+	go func() {
+		select {
+		case <-time.After(takeoverGracePeriod):
+			log.Printf("⏱️ Takeover sem TurnComplete, encerrando sessão: %s", s.ID)
+			s.Cancel()
+		case <-s.Context.Done():
+		}
+	}()
+}
+
+func (s *Session) sendSupervisorTurn(text string) {
+	msg := protocol.ClientMessage{
+		ClientContent: &protocol.ClientContent{
+			Turns:        []protocol.Turn{{Role: "user", Parts: []protocol.Part{{Text: text}}}},
+			TurnComplete: true,
+		},
+	}
+	b, _ := json.Marshal(msg)
+	select {
+	case s.ToGemini <- b:
+	case <-s.Context.Done():
+	}
+}
+
+func (s *Session) recordIntervention(supervisor, mode, instruction string) {
+	s.TranscriptLock.Lock()
+	defer s.TranscriptLock.Unlock()
+	s.Transcript = append(s.Transcript, map[string]interface{}{
+		"id":         uuid.New().String()[:8],
+		"role":       "supervisor",
+		"mode":       mode,
+		"supervisor": supervisor,
+		"text":       instruction,
+		"timestamp":  time.Now().Format(time.RFC3339),
+	})
+}
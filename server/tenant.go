@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+
+	"aivoice-v3/internal/orchestrator"
+)
+
+var errInvalidClientKey = errors.New("invalid client key")
+
+// defaultClientName é o cliente da instância em implantações single-tenant.
+func defaultClientName() string {
+	if name := os.Getenv("INSTANCE_CLIENT_NAME"); name != "" {
+		return name
+	}
+	return "aiVoice"
+}
+
+// parseDashboardToken valida um JWT emitido pelo dashboard-server (mesmo JWT_SECRET).
+func parseDashboardToken(tokenString string) (jwt.MapClaims, error) {
+	secret := []byte(os.Getenv("JWT_SECRET"))
+	if len(secret) == 0 {
+		secret = []byte("default-secret-change-me-in-production")
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return secret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// userHasClient verifica o vínculo entre um usuário do dashboard e um cliente.
+func userHasClient(ctx context.Context, userID int, clientName string) bool {
+	if db == nil {
+		return false
+	}
+	var ok bool
+	db.QueryRow(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM dashboard_user_clients uc
+			JOIN aiVoice_clients cl ON uc.client_id = cl.id
+			WHERE uc.user_id = $1 AND cl.name = $2
+		)`, userID, clientName).Scan(&ok)
+	return ok
+}
+
+// resolveClient identifica o cliente (tenant) da conexão:
+//   - ?key= : chave do widget emitida no dashboard (armazenada como hash SHA-256)
+//   - ?token= + ?client= : JWT de um usuário do dashboard vinculado ao cliente (testes pelo painel)
+//   - sem credenciais: cliente padrão da instância (INSTANCE_CLIENT_NAME)
+func resolveClient(ctx context.Context, r *http.Request) (string, error) {
+	q := r.URL.Query()
+
+	if key := q.Get("key"); key != "" {
+		return lookupClientKey(ctx, key, "widget")
+	}
+
+	if token := q.Get("token"); token != "" {
+		claims, err := parseDashboardToken(token)
+		if err != nil {
+			return "", errInvalidClientKey
+		}
+		userID, _ := claims["user_id"].(float64)
+		clientName := q.Get("client")
+		if clientName == "" || !userHasClient(ctx, int(userID), clientName) {
+			return "", errInvalidClientKey
+		}
+		return clientName, nil
+	}
+
+	return defaultClientName(), nil
+}
+
+// resolveServerKey identifica o cliente da API de chat pela chave de servidor (Authorization: Bearer sk_...).
+// Chaves de widget não são aceitas: elas ficam expostas no navegador.
+func resolveServerKey(ctx context.Context, r *http.Request) (string, error) {
+	key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || key == "" {
+		return "", errInvalidClientKey
+	}
+	return lookupClientKey(ctx, key, "server")
+}
+
+// lookupClientKey busca o cliente dono de uma chave ativa do tipo informado (armazenada como hash SHA-256).
+func lookupClientKey(ctx context.Context, key, kind string) (string, error) {
+	if db == nil {
+		return "", errInvalidClientKey
+	}
+	sum := sha256.Sum256([]byte(key))
+	var name string
+	err := db.QueryRow(ctx, `
+		SELECT cl.name
+		FROM aiVoice_client_keys k
+		JOIN aiVoice_clients cl ON k.client_id = cl.id
+		WHERE k.key_hash = $1 AND k.revoked_at IS NULL AND COALESCE(k.kind, 'widget') = $2`, hex.EncodeToString(sum[:]), kind).Scan(&name)
+	if err != nil {
+		return "", errInvalidClientKey
+	}
+	return name, nil
+}
+
+// clientErrorInfo traduz os erros de status do cliente em código e mensagem para o widget.
+func clientErrorInfo(err error) (string, string) {
+	switch {
+	case errors.Is(err, orchestrator.ErrClientSuspended):
+		return "client_suspended", "Este atendimento está temporariamente indisponível."
+	case errors.Is(err, orchestrator.ErrClientNotFound):
+		return "client_not_found", "Cliente não encontrado."
+	default:
+		return "client_unavailable", "Não foi possível iniciar o atendimento."
+	}
+}
+
+// errorFrame monta o frame {"type":"error"} entendido pelo widget.
+func errorFrame(code, message string) []byte {
+	frame, _ := json.Marshal(map[string]interface{}{
+		"type":    "error",
+		"payload": map[string]interface{}{"code": code, "message": message},
+	})
+	return frame
+}
+
+func rejectClientError(conn *websocket.Conn, err error) {
+	code, message := clientErrorInfo(err)
+	rejectConnection(conn, code, message)
+}
+
+// rejectConnection envia um frame de erro legível ao widget antes de fechar o WebSocket.
+func rejectConnection(conn *websocket.Conn, code, message string) {
+	conn.WriteMessage(websocket.TextMessage, errorFrame(code, message))
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, code))
+	conn.Close()
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"aivoice-v3/internal/orchestrator"
+	"aivoice-v3/internal/protocol"
+	"aivoice-v3/internal/storage"
+)
+
+// Envio de arquivos durante a sessão (boleto, comprovante, print de erro): o widget faz POST em
+// /upload?sessionId=<callId> com as mesmas credenciais do /ws. O arquivo vai para o Gemini como
+// inlineData em um clientContent com uma legenda curta, fica guardado no armazenamento de uploads
+// (storage.Store) e a transcrição recebe a referência. Os arquivos expiram pelo prazo de retenção do cliente.
+
+const (
+	maxUploadCaptionLen  = 500
+	maxUploadFileNameLen = 120
+	// uploadPruneInterval e uploadPruneBatch controlam a limpeza dos arquivos vencidos
+	uploadPruneInterval = time.Hour
+	uploadPruneBatch    = 500
+)
+
+// uploadStore guarda os arquivos enviados (nil = armazenamento indisponível; uploads recusados).
+var uploadStore storage.Store
+
+type UploadResponse struct {
+	ID        string `json:"id"`
+	FileName  string `json:"fileName"`
+	MimeType  string `json:"mimeType"`
+	SizeBytes int    `json:"sizeBytes"`
+}
+
+func initUploadStore() {
+	store, err := storage.FromEnv()
+	if err != nil {
+		log.Printf("⚠️ Armazenamento de uploads indisponível: %v", err)
+		return
+	}
+	uploadStore = store
+	go pruneExpiredUploads()
+}
+
+func handleUpload(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// A sessão precisa estar ativa e pertencer ao cliente da credencial
+	clientName, err := resolveClient(r.Context(), r)
+	if err != nil {
+		http.Error(w, "Invalid client key", http.StatusUnauthorized)
+		return
+	}
+	val, ok := activeSessions.Load(r.URL.Query().Get("sessionId"))
+	if !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	s := val.(*Session)
+	if s.ClientName != clientName {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if !configCache.UploadPolicy(r.Context(), clientName).Enabled {
+		http.Error(w, "Uploads disabled for this client", http.StatusForbidden)
+		return
+	}
+	if uploadStore == nil {
+		http.Error(w, "Upload storage unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	select {
+	case <-s.geminiReady:
+	default:
+		http.Error(w, "Session not ready", http.StatusConflict)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, orchestrator.MaxUploadBytes+64<<10)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "File too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, orchestrator.MaxUploadBytes+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(data) > orchestrator.MaxUploadBytes {
+		http.Error(w, "File too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	// O tipo vem do conteúdo, não da extensão nem do Content-Type informado pelo navegador
+	mimeType, ext, ok := orchestrator.DetectUploadType(data)
+	if !ok {
+		log.Printf("🚫 Upload recusado [%s]: tipo %s fora da lista permitida", s.ID, mimeType)
+		http.Error(w, "Unsupported file type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	up := orchestrator.Upload{
+		ID:         uuid.New().String(),
+		ClientName: clientName,
+		CallID:     s.ID,
+		FileName:   uploadFileName(header.Filename, ext),
+		MimeType:   mimeType,
+		SizeBytes:  len(data),
+		Caption:    truncateRunes(strings.TrimSpace(r.FormValue("caption")), maxUploadCaptionLen),
+	}
+	up.StorageKey = clientName + "/" + s.ID + "/" + up.ID + ext
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	if err := uploadStore.Put(ctx, up.StorageKey, data); err != nil {
+		log.Printf("❌ Erro ao guardar upload [%s]: %v", s.ID, err)
+		http.Error(w, "Upload storage unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if err := orchestrator.RecordUpload(ctx, db, up); err != nil {
+		// Sem a referência no banco o arquivo nunca expiraria: desfaz o envio
+		log.Printf("❌ Erro ao registrar upload [%s]: %v", s.ID, err)
+		uploadStore.Delete(ctx, up.StorageKey)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// Legenda curta + arquivo em um único turno do usuário
+	msg, _ := json.Marshal(protocol.ClientMessage{
+		ClientContent: &protocol.ClientContent{
+			Turns: []protocol.Turn{{Role: "user", Parts: []protocol.Part{
+				{Text: uploadCaption(up)},
+				{InlineData: &protocol.InlineData{MimeType: mimeType, Data: base64.StdEncoding.EncodeToString(data)}},
+			}}},
+			TurnComplete: true,
+		},
+	})
+	select {
+	case s.ToGemini <- msg:
+	case <-s.Context.Done():
+		http.Error(w, "Session ended", http.StatusGone)
+		return
+	}
+
+	text := "📎 " + up.FileName
+	if up.Caption != "" {
+		text += " — " + up.Caption
+	}
+	s.TranscriptLock.Lock()
+	s.Transcript = append(s.Transcript, map[string]interface{}{
+		"id":    uuid.New().String()[:8],
+		"role":  "user",
+		"event": "upload",
+		"text":  text,
+		"upload": map[string]interface{}{
+			"id":         up.ID,
+			"fileName":   up.FileName,
+			"mimeType":   up.MimeType,
+			"sizeBytes":  up.SizeBytes,
+			"storageKey": up.StorageKey,
+		},
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+	s.TranscriptLock.Unlock()
+
+	log.Printf("📎 Upload [%s]: %s (%s, %d bytes) enviado ao agente", s.ID, up.FileName, mimeType, len(data))
+	json.NewEncoder(w).Encode(UploadResponse{ID: up.ID, FileName: up.FileName, MimeType: mimeType, SizeBytes: len(data)})
+}
+
+// uploadCaption apresenta o arquivo ao agente, com a legenda digitada pelo usuário quando houver.
+func uploadCaption(up orchestrator.Upload) string {
+	text := "O usuário enviou o arquivo \"" + up.FileName + "\"."
+	if up.Caption != "" {
+		text += " Mensagem do usuário: " + up.Caption
+	} else {
+		text += " Analise o conteúdo e ajude o usuário com o que ele mostra."
+	}
+	return text
+}
+
+// uploadFileName mantém só o nome do arquivo (sem diretórios), com a extensão do tipo detectado.
+func uploadFileName(name, ext string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		name = "arquivo" + ext
+	}
+	return truncateRunes(name, maxUploadFileNameLen)
+}
+
+func truncateRunes(s string, max int) string {
+	if r := []rune(s); len(r) > max {
+		return string(r[:max])
+	}
+	return s
+}
+
+// pruneExpiredUploads remove periodicamente os arquivos que passaram do prazo de retenção do cliente
+// e os de clientes excluídos pelo dashboard (aiVoice_upload_deletions), que não acessa o armazenamento.
+func pruneExpiredUploads() {
+	ticker := time.NewTicker(uploadPruneInterval)
+	defer ticker.Stop()
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		removed := pruneUploads(ctx, "vencidos", orchestrator.ExpiredUploads, orchestrator.DeleteUploadRecord)
+		orphaned := pruneUploads(ctx, "de clientes excluídos", orchestrator.PendingUploadDeletions, orchestrator.DeletePendingUpload)
+		cancel()
+		if removed > 0 {
+			log.Printf("🧹 %d upload(s) vencido(s) removido(s)", removed)
+		}
+		if orphaned > 0 {
+			log.Printf("🧹 %d upload(s) de clientes excluídos removido(s)", orphaned)
+		}
+		<-ticker.C
+	}
+}
+
+// pruneUploads apaga do armazenamento os arquivos listados por fetch, em lotes, e remove a referência
+// de cada um com forget. Devolve quantos foram removidos.
+func pruneUploads(ctx context.Context, kind string,
+	fetch func(context.Context, *pgxpool.Pool, int) ([]orchestrator.Upload, error),
+	forget func(context.Context, *pgxpool.Pool, string) error) int {
+	removed := 0
+	for {
+		uploads, err := fetch(ctx, db, uploadPruneBatch)
+		if err != nil {
+			log.Printf("⚠️ Erro ao buscar uploads %s: %v", kind, err)
+			return removed
+		}
+		batchRemoved := 0
+		for _, up := range uploads {
+			if err := uploadStore.Delete(ctx, up.StorageKey); err != nil {
+				log.Printf("⚠️ Erro ao apagar upload %s: %v", up.StorageKey, err)
+				continue
+			}
+			if err := forget(ctx, db, up.ID); err == nil {
+				batchRemoved++
+			}
+		}
+		removed += batchRemoved
+		// Lote incompleto (acabou) ou sem progresso (falhas de remoção): tenta de novo no próximo ciclo
+		if len(uploads) < uploadPruneBatch || batchRemoved == 0 {
+			return removed
+		}
+	}
+}
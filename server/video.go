@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/base64"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"aivoice-v3/internal/protocol"
+)
+
+// videoFrame valida um quadro JPEG enviado pelo widget (compartilhamento de tela ou câmera) e o
+// converte no realtimeInput.video do Gemini. O servidor aplica o limite de tamanho e a taxa máxima
+// do cliente: quadros acima do limite ou adiantados em relação ao intervalo são descartados.
+func (s *Session) videoFrame(mimeType, data, source string) *protocol.RealtimeInput {
+	s.TranscriptLock.Lock()
+	defer s.TranscriptLock.Unlock()
+
+	if !s.Video.Enabled {
+		if s.droppedFrames == 0 {
+			log.Printf("⚠️ Quadro de vídeo ignorado [%s]: vídeo desativado para %s", s.ID, s.ClientName)
+		}
+		s.droppedFrames++
+		return nil
+	}
+	if mimeType != "image/jpeg" || data == "" {
+		s.droppedFrames++
+		return nil
+	}
+	if size := base64.StdEncoding.DecodedLen(len(data)); size > s.Video.MaxFrameBytes {
+		if s.droppedFrames == 0 {
+			log.Printf("⚠️ Quadro de vídeo descartado [%s]: %d bytes (limite %d)", s.ID, size, s.Video.MaxFrameBytes)
+		}
+		s.droppedFrames++
+		return nil
+	}
+	now := time.Now()
+	minInterval := time.Duration(float64(time.Second) / s.Video.MaxFPS)
+	if !s.lastVideoFrame.IsZero() && now.Sub(s.lastVideoFrame) < minInterval {
+		s.droppedFrames++
+		return nil
+	}
+	s.lastVideoFrame = now
+
+	if !s.VideoUsed {
+		s.VideoUsed = true
+		text := "Usuário começou a compartilhar a tela"
+		if source == "camera" {
+			text = "Usuário ligou a câmera"
+		}
+		log.Printf("🖥️ Vídeo iniciado [%s]: %s (%s, até %.1f qps)", s.ID, s.ClientName, source, s.Video.MaxFPS)
+		s.Transcript = append(s.Transcript, map[string]interface{}{
+			"id":        uuid.New().String()[:8],
+			"role":      "system",
+			"event":     "video_start",
+			"text":      text,
+			"timestamp": now.Format(time.RFC3339),
+		})
+	}
+	return &protocol.RealtimeInput{Video: &protocol.InlineData{MimeType: mimeType, Data: data}}
+}
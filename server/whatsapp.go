@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"aivoice-shared/secrets"
+	"aivoice-v3/internal/channel"
+	"aivoice-v3/internal/keypool"
+	"aivoice-v3/internal/orchestrator"
+)
+
+// Canal WhatsApp (Cloud API): o webhook do app da Meta chega em /webhooks/whatsapp, cada número
+// do WhatsApp Business pertence a um cliente (aiVoice_whatsapp_channels) e as mensagens seguem pelo
+// mesmo fluxo da API de chat (chat.go). Notas de voz são transcritas pelo Gemini antes de chegar ao agente.
+
+const (
+	maxWhatsAppWebhookBytes = 1 << 20
+	// whatsappDedupTTL cobre as reentregas do webhook (a Meta reenvia o que não recebeu 200 a tempo)
+	whatsappDedupTTL = 15 * time.Minute
+	// whatsappBusyPoll é o intervalo de espera quando a conversa ainda responde a mensagem anterior
+	whatsappBusyPoll = 500 * time.Millisecond
+)
+
+const (
+	whatsappUnsupportedReply = "Por enquanto só consigo ler mensagens de texto e áudio. Pode me escrever ou mandar um áudio?"
+	whatsappAudioErrorReply  = "Não consegui entender o seu áudio. Pode repetir ou escrever a mensagem?"
+	whatsappFailureReply     = "Não consegui responder agora. Tente novamente em instantes."
+)
+
+// whatsappSeen guarda os IDs de mensagens já recebidas (descarta reentregas do webhook).
+var whatsappSeen sync.Map
+
+// whatsappAccount é o número de WhatsApp Business de um cliente.
+type whatsappAccount struct {
+	ClientName string
+	Token      string
+}
+
+// whatsappGraphURL permite apontar o canal para um Graph API falso em testes locais.
+func whatsappGraphURL() string {
+	return os.Getenv("WHATSAPP_GRAPH_URL")
+}
+
+func handleWhatsAppWebhook(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		// Verificação da assinatura do webhook no painel da Meta
+		challenge, ok := channel.VerifyChallenge(r.URL.Query(), os.Getenv("WHATSAPP_VERIFY_TOKEN"))
+		if !ok {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, challenge)
+	case "POST":
+		appSecret := os.Getenv("WHATSAPP_APP_SECRET")
+		if appSecret == "" {
+			log.Println("⚠️ WhatsApp: webhook recebido sem WHATSAPP_APP_SECRET configurado")
+			http.Error(w, "WhatsApp channel not configured", http.StatusServiceUnavailable)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxWhatsAppWebhookBytes))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !channel.VerifySignature(appSecret, body, r.Header.Get(channel.SignatureHeader)) {
+			log.Println("🚫 WhatsApp: assinatura do webhook inválida")
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+		msgs, err := channel.ParseWhatsAppWebhook(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// A Meta exige resposta rápida: o processamento segue em segundo plano, em ordem
+		w.WriteHeader(http.StatusOK)
+		pruneWhatsAppSeen()
+		var fresh []channel.Message
+		for _, m := range msgs {
+			if _, dup := whatsappSeen.LoadOrStore(m.ID, time.Now()); !dup {
+				fresh = append(fresh, m)
+			}
+		}
+		if len(fresh) > 0 {
+			go func() {
+				for _, m := range fresh {
+					handleWhatsAppMessage(m)
+				}
+			}()
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func pruneWhatsAppSeen() {
+	whatsappSeen.Range(func(k, v interface{}) bool {
+		if time.Since(v.(time.Time)) > whatsappDedupTTL {
+			whatsappSeen.Delete(k)
+		}
+		return true
+	})
+}
+
+// lookupWhatsAppAccount encontra o cliente dono do número que recebeu a mensagem.
+func lookupWhatsAppAccount(ctx context.Context, phoneNumberID string) (whatsappAccount, error) {
+	if db == nil {
+		return whatsappAccount{}, errors.New("database unavailable")
+	}
+	var acct whatsappAccount
+	var enc string
+	err := db.QueryRow(ctx, `
+		SELECT cl.name, w.access_token
+		FROM aiVoice_whatsapp_channels w
+		JOIN aiVoice_clients cl ON w.client_id = cl.id
+		WHERE w.phone_number_id = $1 AND w.enabled`, phoneNumberID).Scan(&acct.ClientName, &enc)
+	if err != nil {
+		return acct, err
+	}
+	if acct.Token, err = secrets.Decrypt(enc); err != nil {
+		return acct, fmt.Errorf("decrypting access token: %w", err)
+	}
+	return acct, nil
+}
+
+// handleWhatsAppMessage responde uma mensagem recebida: transcreve áudios, executa o turno do agente
+// na conversa do número e envia a resposta pelo Graph API.
+func handleWhatsAppMessage(m channel.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*chatTurnTimeout)
+	defer cancel()
+
+	acct, err := lookupWhatsAppAccount(ctx, m.AccountID)
+	if err != nil {
+		log.Printf("⚠️ WhatsApp: número %s sem cliente vinculado: %v", m.AccountID, err)
+		return
+	}
+	if err := configCache.CheckClientActive(ctx, acct.ClientName); err != nil {
+		log.Printf("🚫 WhatsApp: mensagem ignorada para %s: %v", acct.ClientName, err)
+		return
+	}
+	wa := channel.NewGraphClient(whatsappGraphURL(), acct.Token)
+
+	if m.Type != channel.TypeText && m.Type != channel.TypeAudio {
+		sendWhatsAppReply(ctx, wa, m, whatsappUnsupportedReply)
+		return
+	}
+
+	req := ChatRequest{
+		ConversationID: channel.ConversationID(m.AccountID, m.From),
+		Caller:         orchestrator.CallerInfo{Name: m.Name, ID: m.From},
+	}
+	// Mensagens em sequência esperam a resposta da anterior na mesma conversa
+	s, release, err := newChatSession(ctx, acct.ClientName, req)
+	for errors.Is(err, errChatBusy) {
+		select {
+		case <-ctx.Done():
+			log.Printf("⚠️ WhatsApp [%s]: conversa com %s ocupada; mensagem descartada", acct.ClientName, m.From)
+			return
+		case <-time.After(whatsappBusyPoll):
+		}
+		s, release, err = newChatSession(ctx, acct.ClientName, req)
+	}
+	if err != nil {
+		log.Printf("❌ WhatsApp [%s]: erro carregando a conversa com %s: %v", acct.ClientName, m.From, err)
+		return
+	}
+
+	// A transcrição roda com a conversa aberta para o consumo entrar na chamada
+	text := m.Text
+	if m.Type == channel.TypeAudio {
+		text, err = s.transcribeWhatsAppAudio(ctx, wa, m)
+		if err != nil {
+			log.Printf("⚠️ WhatsApp [%s]: falha ao transcrever áudio de %s: %v", acct.ClientName, m.From, err)
+			if errors.Is(err, orchestrator.ErrEmptyTranscription) {
+				s.persistChatTurn() // O modelo respondeu: grava os tokens consumidos
+			}
+			release()
+			sendWhatsAppReply(ctx, wa, m, whatsappAudioErrorReply)
+			return
+		}
+		log.Printf("🎤 WhatsApp [%s]: áudio de %s transcrito (%d caracteres)", acct.ClientName, m.From, len(text))
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		release()
+		return
+	}
+	if len(text) > maxChatMessageLen {
+		text = strings.ToValidUTF8(text[:maxChatMessageLen], "")
+	}
+
+	var reply strings.Builder
+	var links []string
+	err = s.runChatTurn(text, func(event string, data interface{}) {
+		switch event {
+		case "delta":
+			if d, ok := data.(map[string]string); ok {
+				reply.WriteString(d["text"])
+			}
+		case "link":
+			var link struct {
+				URL   string `json:"url"`
+				Alias string `json:"alias"`
+			}
+			if raw, ok := data.(json.RawMessage); ok && json.Unmarshal(raw, &link) == nil && link.URL != "" {
+				links = append(links, strings.TrimSpace(link.Alias+": "+link.URL))
+			}
+		}
+	})
+	release()
+	if err != nil {
+		log.Printf("⚠️ WhatsApp [%s]: erro no turno da conversa %s: %v", acct.ClientName, s.ID, err)
+		if reply.Len() == 0 {
+			sendWhatsAppReply(ctx, wa, m, whatsappFailureReply)
+			return
+		}
+	}
+
+	// O bubble de link do widget vira uma linha com o link no fim da resposta
+	out := reply.String()
+	if len(links) > 0 {
+		out = strings.TrimSpace(out) + "\n\n" + strings.Join(links, "\n")
+	}
+	sendWhatsAppReply(ctx, wa, m, out)
+}
+
+// transcribeWhatsAppAudio baixa a nota de voz e a transcreve com os modelos de transcrição do cliente,
+// trocando de chave e de modelo como connectGemini (cooldown por cota ou permissão). O consumo de cada
+// tentativa é atribuído à chave e à conversa.
+func (s *Session) transcribeWhatsAppAudio(ctx context.Context, media channel.MediaFetcher, m channel.Message) (string, error) {
+	audio, mimeType, err := media.FetchMedia(ctx, m.MediaID)
+	if err != nil {
+		return "", err
+	}
+	if mimeType == "" {
+		mimeType = m.MimeType
+	}
+	clientKey := clientAPIKey(ctx, s.ClientName)
+	err = keypool.ErrNoKey
+	for _, model := range configCache.TranscriptionModels(ctx, s.ClientName) {
+		for {
+			key, keyErr := apiKeys.AcquireFor(clientKey, model)
+			if keyErr != nil {
+				log.Printf("⚠️ Nenhuma chave Gemini disponível [%s] para %s com o modelo %s", s.ID, s.ClientName, model)
+				break
+			}
+			text, usage, callErr := orchestrator.TranscribeAudio(ctx, key.Secret, model, mimeType, audio)
+			s.addRequestUsage(key, usage.InputTokens, usage.OutputTokens)
+			if callErr == nil || errors.Is(callErr, orchestrator.ErrEmptyTranscription) {
+				return text, callErr
+			}
+			err = callErr
+			reason, cooldown, perModel := classifyGeminiError(callErr)
+			log.Printf("⚠️ Transcrição com o modelo %s falhou [%s] com a chave %s: %s", model, s.ID, key.ID, reason)
+			if cooldown == 0 {
+				break
+			}
+			coolDownKey(key, model, cooldown, perModel)
+		}
+	}
+	return "", err
+}
+
+// sendWhatsAppReply envia a resposta ao usuário, dividida no limite de tamanho do WhatsApp.
+func sendWhatsAppReply(ctx context.Context, sender channel.Sender, m channel.Message, text string) {
+	for _, chunk := range channel.SplitText(text, channel.WhatsAppTextLimit) {
+		if err := sender.SendText(ctx, m.AccountID, m.From, chunk); err != nil {
+			log.Printf("❌ WhatsApp: erro enviando resposta para %s: %v", m.From, err)
+			return
+		}
+	}
+}
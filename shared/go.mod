@@ -0,0 +1,3 @@
+module aivoice-shared
+
+go 1.24.0
@@ -0,0 +1,226 @@
+// Package secrets encrypts secrets stored in the database (client API keys and the like) with
+// envelope encryption: each value gets its own data key, which is wrapped by a master key.
+// Master keys carry an ID so they can be rotated. Both the orchestrator and the dashboard-server
+// use this package, so values written by one are read by the other.
+//
+// Master keys come from SECRETS_MASTER_KEYS ("id=base64,..." with 32-byte keys, the first one
+// encrypts and all of them decrypt), from the file named by SECRETS_MASTER_KEYS_FILE (same format,
+// commas or newlines), or from the legacy SECRETS_KEY (a single key with ID "default").
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// legacyPrefix marks values encrypted directly with SECRETS_KEY (no key ID).
+	legacyPrefix = "v1:"
+	// envelopePrefix marks "v2:<key id>:<wrapped data key>:<ciphertext>".
+	envelopePrefix = "v2:"
+)
+
+// ErrNoKey is returned when no master key is configured.
+var ErrNoKey = errors.New("secrets: no master key configured (SECRETS_MASTER_KEYS, SECRETS_MASTER_KEYS_FILE or SECRETS_KEY)")
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Keyring holds the master keys by ID.
+type Keyring struct {
+	active string
+	keys   map[string][]byte
+}
+
+// ParseKeyring parses "id=base64" entries separated by commas or newlines; the first is active.
+func ParseKeyring(spec string) (*Keyring, error) {
+	k := &Keyring{keys: map[string][]byte{}}
+	for _, entry := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		id, b64, ok := strings.Cut(entry, "=")
+		if !ok || !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("secrets: invalid master key entry %q (use id=base64)", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b64))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("secrets: master key %q must be base64 of 32 bytes", id)
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("secrets: duplicate master key %q", id)
+		}
+		k.keys[id] = key
+		if k.active == "" {
+			k.active = id
+		}
+	}
+	if k.active == "" {
+		return nil, ErrNoKey
+	}
+	return k, nil
+}
+
+// LoadKeyring reads the master keys from the environment.
+func LoadKeyring() (*Keyring, error) {
+	if spec := os.Getenv("SECRETS_MASTER_KEYS"); spec != "" {
+		return ParseKeyring(spec)
+	}
+	if path := os.Getenv("SECRETS_MASTER_KEYS_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("secrets: reading %s: %w", path, err)
+		}
+		return ParseKeyring(string(b))
+	}
+	if legacy := os.Getenv("SECRETS_KEY"); legacy != "" {
+		return ParseKeyring("default=" + legacy)
+	}
+	return nil, ErrNoKey
+}
+
+// ActiveID returns the ID of the master key used for new values.
+func (k *Keyring) ActiveID() string {
+	return k.active
+}
+
+// Encrypt seals plain with a fresh data key wrapped by the active master key.
+func (k *Keyring) Encrypt(plain string) (string, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keys[k.active], dek, []byte(k.active))
+	if err != nil {
+		return "", err
+	}
+	ct, err := seal(dek, []byte(plain), nil)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.StdEncoding
+	return envelopePrefix + k.active + ":" + enc.EncodeToString(wrapped) + ":" + enc.EncodeToString(ct), nil
+}
+
+// Decrypt opens a value produced by Encrypt (or a legacy v1 value).
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if raw, ok := strings.CutPrefix(value, legacyPrefix); ok {
+		return k.decryptLegacy(raw)
+	}
+	raw, ok := strings.CutPrefix(value, envelopePrefix)
+	if !ok {
+		return "", errors.New("secrets: unknown format")
+	}
+	parts := strings.Split(raw, ":")
+	if len(parts) != 3 {
+		return "", errors.New("secrets: malformed envelope")
+	}
+	master, ok := k.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("secrets: master key %q not configured", parts[0])
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", err
+	}
+	ct, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", err
+	}
+	dek, err := open(master, wrapped, []byte(parts[0]))
+	if err != nil {
+		return "", fmt.Errorf("secrets: unwrapping data key: %w", err)
+	}
+	plain, err := open(dek, ct, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// NeedsRotation reports whether value is not yet sealed under the active master key.
+func (k *Keyring) NeedsRotation(value string) bool {
+	return !strings.HasPrefix(value, envelopePrefix+k.active+":")
+}
+
+func (k *Keyring) decryptLegacy(raw string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return "", err
+	}
+	for _, key := range k.keys {
+		if plain, err := open(key, data, nil); err == nil {
+			return string(plain), nil
+		}
+	}
+	return "", errors.New("secrets: no master key opens this legacy value")
+}
+
+func seal(key, plain, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, aad), nil
+}
+
+func open(key, data, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("secrets: ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+var (
+	defaultOnce    sync.Once
+	defaultKeyring *Keyring
+	defaultErr     error
+)
+
+// Default returns the keyring loaded from the environment on first use.
+func Default() (*Keyring, error) {
+	defaultOnce.Do(func() { defaultKeyring, defaultErr = LoadKeyring() })
+	return defaultKeyring, defaultErr
+}
+
+// Encrypt seals plain with the default keyring.
+func Encrypt(plain string) (string, error) {
+	k, err := Default()
+	if err != nil {
+		return "", err
+	}
+	return k.Encrypt(plain)
+}
+
+// Decrypt opens value with the default keyring.
+func Decrypt(value string) (string, error) {
+	k, err := Default()
+	if err != nil {
+		return "", err
+	}
+	return k.Decrypt(value)
+}